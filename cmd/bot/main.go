@@ -7,6 +7,7 @@ import (
 	"tgbot-skeleton/internal/bot"
 	"tgbot-skeleton/internal/config"
 	"tgbot-skeleton/internal/logger"
+	"tgbot-skeleton/internal/tracing"
 
 	"go.uber.org/zap"
 )
@@ -38,6 +39,23 @@ func main() {
 		zap.String("commit", commit),
 	)
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(),
+		cfg.Tracing.Exporter,
+		cfg.Tracing.Endpoint,
+		cfg.Tracing.ServiceName,
+		version,
+		cfg.Tracing.SampleRatio,
+	)
+	if err != nil {
+		log.Fatal("failed to initialize tracing", zap.Error(err))
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error("failed to shut down tracing", zap.Error(err))
+		}
+	}()
+
 	// Create bot instance
 	telegramBot, err := bot.New(cfg, log)
 	if err != nil {
@@ -46,7 +64,11 @@ func main() {
 
 	// Start bot
 	ctx := context.Background()
-	if err := telegramBot.Start(ctx); err != nil {
+	err = telegramBot.Start(ctx)
+	if closeErr := telegramBot.Close(); closeErr != nil {
+		log.Error("failed to close bot", zap.Error(closeErr))
+	}
+	if err != nil {
 		log.Fatal("bot error", zap.Error(err))
 	}
 }
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Embedder computes embeddings with an OpenAI-compatible /embeddings endpoint
+type Embedder struct {
+	client *http.Client
+	url    string
+	model  string
+	apiKey string
+}
+
+// NewEmbedder creates an embedder for the provider at url
+func NewEmbedder(url, model, apiKey string) *Embedder {
+	return &Embedder{
+		client: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		url:    url,
+		model:  model,
+		apiKey: apiKey,
+	}
+}
+
+// EmbeddingRequest represents the OpenAI-compatible embeddings request
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse represents the OpenAI-compatible embeddings response
+type EmbeddingResponse struct {
+	Data  []Embedding `json:"data"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+// Embedding is the vector of the input at Index
+type Embedding struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// Embed returns the embeddings of texts, in the same order
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody, err := json.Marshal(EmbeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url+"/embeddings", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings provider returned status %d: %s", resp.StatusCode, errorBody(respBody))
+	}
+
+	var embResp EmbeddingResponse
+	if err := json.Unmarshal(respBody, &embResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if embResp.Error != nil {
+		return nil, fmt.Errorf("embeddings provider error: %s", embResp.Error.Message)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range embResp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("no embedding received for input %d", i)
+		}
+	}
+	return vectors, nil
+}
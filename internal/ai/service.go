@@ -7,9 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"tgbot-skeleton/internal/logger"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/prompt"
+	"tgbot-skeleton/internal/redact"
+	"tgbot-skeleton/internal/tracing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -19,50 +30,195 @@ type Service struct {
 	url    string
 	model  string
 	apiKey string
-	prompt string
 	logger *zap.Logger
+
+	// prompt is swapped as a whole when prompts are reloaded
+	prompt atomic.Pointer[defaultPrompt]
+	// vars and examples hold the options for the initial prompt
+	vars     map[string]string
+	examples []Message
+
+	// temperature and maxTokens are the default sampling parameters
+	temperature float64
+	maxTokens   int
+
+	// logContent enables logging of user messages and model responses
+	logContent bool
+	// redactRequests masks personal data in messages sent to the provider
+	redactRequests bool
+
+	// tools are the tools the model can call, by name, described to the model
+	// by toolDefinitions; maxToolIterations limits the tool-calling rounds
+	tools             map[string]Tool
+	toolDefinitions   []ToolDefinition
+	maxToolIterations int
+
+	// lastSuccess holds the Unix nanoseconds of the last successful completion
+	lastSuccess atomic.Int64
 }
 
-// NewService creates a new AI service
-func NewService(url, model, apiKey, prompt string, logger *zap.Logger) *Service {
-	// Process prompt to handle escaped newlines
-	processedPrompt := strings.ReplaceAll(prompt, "\\n", "\n")
+// defaultPrompt is the system prompt with the values and examples sent along with it
+type defaultPrompt struct {
+	tmpl *prompt.Template
+	// vars are custom values available to the template as {{.Vars.name}}
+	vars map[string]string
+	// examples are few-shot turns sent between the system prompt and the history
+	examples []Message
+}
+
+// Option configures optional Service behavior
+type Option func(*Service)
 
-	return &Service{
+// WithContentLogging enables or disables logging of message content
+func WithContentLogging(enabled bool) Option {
+	return func(s *Service) {
+		s.logContent = enabled
+	}
+}
+
+// WithRequestRedaction masks personal data in outgoing messages with reversible
+// placeholders that are restored in the response
+func WithRequestRedaction(enabled bool) Option {
+	return func(s *Service) {
+		s.redactRequests = enabled
+	}
+}
+
+// WithSampling sets the default temperature and completion token limit
+func WithSampling(temperature float64, maxTokens int) Option {
+	return func(s *Service) {
+		s.temperature = temperature
+		s.maxTokens = maxTokens
+	}
+}
+
+// WithExamples sets few-shot turns sent before the conversation history
+func WithExamples(examples []Message) Option {
+	return func(s *Service) {
+		s.examples = examples
+	}
+}
+
+// WithPromptVars sets custom values available to prompt templates
+func WithPromptVars(vars map[string]string) Option {
+	return func(s *Service) {
+		s.vars = vars
+	}
+}
+
+// NewService creates a new AI service; the system prompt may be a text/template
+// and fails here if it does not render
+func NewService(url, model, apiKey, systemPrompt string, logger *zap.Logger, opts ...Option) (*Service, error) {
+	s := &Service{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
+			// Creates client spans and propagates W3C trace context to the provider
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
-		url:    url,
-		model:  model,
-		apiKey: apiKey,
-		prompt: processedPrompt,
-		logger: logger,
+		url:         url,
+		model:       model,
+		apiKey:      apiKey,
+		logger:      logger,
+		temperature: 0.7,
+		maxTokens:   1000,
+		logContent:  true,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.SetPrompt(systemPrompt, s.vars, s.examples); err != nil {
+		return nil, err
 	}
+	return s, nil
+}
+
+// SetPrompt validates a new default system prompt and replaces the current one;
+// requests in flight keep the prompt they started with
+func (s *Service) SetPrompt(systemPrompt string, vars map[string]string, examples []Message) error {
+	// Process prompt to handle escaped newlines
+	processedPrompt := strings.ReplaceAll(systemPrompt, "\\n", "\n")
+	tmpl, err := prompt.Compile("system", processedPrompt, vars)
+	if err != nil {
+		return fmt.Errorf("invalid system prompt: %w", err)
+	}
+	s.prompt.Store(&defaultPrompt{tmpl: tmpl, vars: vars, examples: examples})
+	return nil
 }
 
 // ChatRequest represents the OpenAI-compatible chat request
 type ChatRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Model       string           `json:"model"`
+	Messages    []Message        `json:"messages"`
+	MaxTokens   int              `json:"max_tokens,omitempty"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	Tools       []ToolDefinition `json:"tools,omitempty"`
+	// ToolChoice is "auto" to let the model decide or "none" to forbid tool calls
+	ToolChoice     string          `json:"tool_choice,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls are the tools an assistant message asks to call
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call a "tool" message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 // ChatResponse represents the OpenAI-compatible chat response
 type ChatResponse struct {
 	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
 	Error   *Error   `json:"error,omitempty"`
 }
 
+// Usage represents token usage reported by the provider
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Request describes a completion request
+type Request struct {
+	// Prompt overrides the service's system prompt when set
+	Prompt *prompt.Template
+	// PromptData describes the user and chat for prompt templates; the date,
+	// time and custom variables are filled in by the service
+	PromptData prompt.Data
+	// Instructions are appended to the rendered system prompt
+	Instructions string
+	// Model, Temperature, MaxTokens and Examples override the service defaults when set
+	Model       string
+	Temperature *float64
+	MaxTokens   int
+	Examples    []Message
+	History     []Message
+	Message     string
+	// ResponseFormat asks for a structured answer; such requests are sent
+	// without tools
+	ResponseFormat *ResponseFormat
+}
+
+// Completion is the result of a chat completion along with its token usage
+type Completion struct {
+	Content string
+	Model   string
+	// Usage adds up the tokens of all requests, including tool-calling rounds
+	Usage Usage
+	// Latency is the time the provider took to answer, in all rounds
+	Latency time.Duration
+	// ToolCalls are the tools the model called before answering
+	ToolCalls []ToolResult
+}
+
 // Choice represents a response choice
 type Choice struct {
-	Message Message `json:"message"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
 }
 
 // Error represents an API error
@@ -71,92 +227,305 @@ type Error struct {
 	Type    string `json:"type"`
 }
 
+// Model returns the model used for completions
+func (s *Service) Model() string {
+	return s.model
+}
+
+// LastSuccess returns the time of the last successful completion, or zero time
+func (s *Service) LastSuccess() time.Time {
+	nanos := s.lastSuccess.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Ping checks that the AI provider is reachable with a lightweight /models request
+func (s *Service) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AI provider returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // GenerateResponse sends a message to the AI provider and returns the response
 func (s *Service) GenerateResponse(ctx context.Context, userMessage string) (string, error) {
-	// Prepare messages with system prompt
-	messages := []Message{
-		{
-			Role:    "system",
-			Content: s.prompt,
-		},
-		{
-			Role:    "user",
-			Content: userMessage,
-		},
+	return s.GenerateResponseWithHistory(ctx, nil, userMessage)
+}
+
+// GenerateResponseWithHistory sends a message along with previous conversation
+// turns to the AI provider and returns the response
+func (s *Service) GenerateResponseWithHistory(ctx context.Context, history []Message, userMessage string) (string, error) {
+	completion, err := s.Complete(ctx, Request{History: history, Message: userMessage})
+	if err != nil {
+		return "", err
 	}
+	return completion.Content, nil
+}
+
+// Complete sends a request to the AI provider and returns the response with
+// the tokens it used
+func (s *Service) Complete(ctx context.Context, req Request) (*Completion, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ai.GenerateResponse", trace.WithAttributes(
+		attribute.String("ai.model", s.requestModel(req)),
+		attribute.Int("ai.history_messages", len(req.History)),
+	))
+
+	completion, err := s.complete(ctx, req)
+	tracing.End(span, err)
+	return completion, err
+}
+
+// complete performs the chat completion request
+func (s *Service) complete(ctx context.Context, req Request) (*Completion, error) {
+	history, userMessage := req.History, req.Message
+	model := s.requestModel(req)
+	defaults := s.prompt.Load()
+	tmpl := defaults.tmpl
+	if req.Prompt != nil {
+		tmpl = req.Prompt
+	}
+	data := req.PromptData
+	data.Vars = defaults.vars
+	data.SetTime(time.Now())
+	systemPrompt, err := tmpl.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	if req.Instructions != "" {
+		systemPrompt += "\n\n" + req.Instructions
+	}
+	examples := defaults.examples
+	if req.Examples != nil {
+		examples = req.Examples
+	}
+	temperature := s.temperature
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+	maxTokens := s.maxTokens
+	if req.MaxTokens > 0 {
+		maxTokens = req.MaxTokens
+	}
+
+	// Mask personal data before it leaves the process
+	var masker *redact.Masker
+	if s.redactRequests {
+		masker = redact.NewMasker()
+		masked := make([]Message, len(history))
+		for i, msg := range history {
+			masked[i] = Message{Role: msg.Role, Content: masker.Mask(msg.Content)}
+		}
+		history = masked
+		userMessage = masker.Mask(userMessage)
+	}
+
+	// Prepare messages with system prompt, examples, history and the new user message
+	messages := make([]Message, 0, len(examples)+len(history)+2)
+	messages = append(messages, Message{
+		Role:    "system",
+		Content: systemPrompt,
+	})
+	messages = append(messages, examples...)
+	messages = append(messages, history...)
+	messages = append(messages, Message{
+		Role:    "user",
+		Content: userMessage,
+	})
 
 	// Create request
-	req := ChatRequest{
-		Model:       s.model,
-		Messages:    messages,
-		MaxTokens:   1000,
-		Temperature: 0.7,
+	chatReq := ChatRequest{
+		Model:          model,
+		Messages:       messages,
+		MaxTokens:      maxTokens,
+		Temperature:    &temperature,
+		ResponseFormat: req.ResponseFormat,
+	}
+	tools := s.toolDefinitions
+	if req.ResponseFormat != nil {
+		tools = nil
 	}
 
+	logger.FromContext(ctx, s.logger).Debug("sending request to AI provider",
+		zap.String("url", s.url),
+		zap.String("model", model),
+		s.contentField("user_message", userMessage),
+		zap.Int("history_messages", len(history)),
+	)
+
+	// The model may call tools before answering; their results are sent back
+	// until it answers, and the last round forbids further calls
+	completion := &Completion{Model: model}
+	var response string
+	for round := 0; ; round++ {
+		if len(tools) > 0 {
+			chatReq.Tools, chatReq.ToolChoice = tools, "auto"
+			if round == s.maxToolIterations {
+				chatReq.ToolChoice = "none"
+			}
+		}
+
+		reply, err := s.send(ctx, chatReq, completion)
+		if err != nil {
+			return nil, err
+		}
+		if len(reply.ToolCalls) == 0 || len(tools) == 0 {
+			response = reply.Content
+			break
+		}
+		if round == s.maxToolIterations {
+			return nil, fmt.Errorf("model kept calling tools after %d rounds", s.maxToolIterations)
+		}
+
+		chatReq.Messages = append(chatReq.Messages, Message{Role: "assistant", Content: reply.Content, ToolCalls: reply.ToolCalls})
+		for _, call := range reply.ToolCalls {
+			// Tools get the real values, the provider only placeholders
+			if masker != nil {
+				call.Function.Arguments = masker.Restore(call.Function.Arguments)
+			}
+			result, record := s.callTool(ctx, call)
+			if masker != nil {
+				result.Content = masker.Mask(result.Content)
+			}
+			chatReq.Messages = append(chatReq.Messages, result)
+			completion.ToolCalls = append(completion.ToolCalls, record)
+		}
+	}
+
+	logger.FromContext(ctx, s.logger).Debug("received response from AI provider",
+		s.contentField("response", response),
+	)
+
+	if masker != nil {
+		response = masker.Restore(response)
+	}
+
+	s.lastSuccess.Store(time.Now().UnixNano())
+	completion.Content = response
+	return completion, nil
+}
+
+// send performs one chat completion request and returns the model's message,
+// adding the tokens and latency to completion
+func (s *Service) send(ctx context.Context, chatReq ChatRequest, completion *Completion) (*Message, error) {
+	model := chatReq.Model
+
 	// Marshal request
-	reqBody, err := json.Marshal(req)
+	reqBody, err := json.Marshal(chatReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.url+"/chat/completions", bytes.NewBuffer(reqBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
 
-	s.logger.Debug("sending request to AI provider",
-		zap.String("url", s.url),
-		zap.String("model", s.model),
-		zap.String("user_message", userMessage),
-	)
-
 	// Send request
+	start := time.Now()
 	resp, err := s.client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		metrics.AIRequestDuration.WithLabelValues(model, "error").Observe(time.Since(start).Seconds())
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
 	respBody, err := io.ReadAll(resp.Body)
+	latency := time.Since(start)
+	completion.Latency += latency
+	metrics.AIRequestDuration.WithLabelValues(model, strconv.Itoa(resp.StatusCode)).Observe(latency.Seconds())
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		s.logger.Error("AI provider returned error",
+		body := errorBody(respBody)
+		logger.FromContext(ctx, s.logger).Error("AI provider returned error",
 			zap.Int("status_code", resp.StatusCode),
-			zap.String("response", string(respBody)),
+			zap.String("response", body),
 		)
-		return "", fmt.Errorf("AI provider returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, fmt.Errorf("AI provider returned status %d: %s", resp.StatusCode, body)
 	}
 
 	// Parse response
 	var chatResp ChatResponse
 	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Record token usage
+	if chatResp.Usage != nil {
+		completion.Usage.PromptTokens += chatResp.Usage.PromptTokens
+		completion.Usage.CompletionTokens += chatResp.Usage.CompletionTokens
+		completion.Usage.TotalTokens += chatResp.Usage.TotalTokens
+		metrics.AITokens.WithLabelValues(model, "in").Add(float64(chatResp.Usage.PromptTokens))
+		metrics.AITokens.WithLabelValues(model, "out").Add(float64(chatResp.Usage.CompletionTokens))
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int("ai.prompt_tokens", completion.Usage.PromptTokens),
+			attribute.Int("ai.completion_tokens", completion.Usage.CompletionTokens),
+		)
 	}
 
 	// Check for API error
 	if chatResp.Error != nil {
-		return "", fmt.Errorf("AI provider error: %s", chatResp.Error.Message)
+		return nil, fmt.Errorf("AI provider error: %s", chatResp.Error.Message)
 	}
 
 	// Check if we have choices
 	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices received")
+		return nil, fmt.Errorf("no response choices received")
 	}
 
-	response := chatResp.Choices[0].Message.Content
-	s.logger.Debug("received response from AI provider",
-		zap.String("response", response),
-	)
+	return &chatResp.Choices[0].Message, nil
+}
 
-	return response, nil
+// requestModel returns the model a request is sent to
+func (s *Service) requestModel(req Request) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return s.model
+}
+
+// contentField logs redacted message content, or only its length when content
+// logging is disabled
+func (s *Service) contentField(key, text string) zap.Field {
+	if !s.logContent {
+		return zap.Int(key+"_length", len(text))
+	}
+	return zap.String(key, redact.Redact(text))
+}
+
+// maxErrorBody is the longest provider error response quoted in errors and logs
+const maxErrorBody = 500
+
+// errorBody returns an error response of the provider with sensitive data
+// redacted and truncated, so it can be quoted in errors and logs
+func errorBody(body []byte) string {
+	text := []rune(strings.TrimSpace(redact.Redact(string(body))))
+	if len(text) > maxErrorBody {
+		return string(text[:maxErrorBody]) + "…"
+	}
+	return string(text)
 }
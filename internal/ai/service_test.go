@@ -0,0 +1,227 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tgbot-skeleton/internal/prompt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+)
+
+// newTestProvider starts a fake OpenAI-compatible provider that records the
+// last request and answers with reply
+func newTestProvider(t *testing.T, reply func(req ChatRequest) string) (*httptest.Server, *ChatRequest, *http.Header) {
+	t.Helper()
+
+	var lastReq ChatRequest
+	var lastHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastHeader = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&lastReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := ChatResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: reply(lastReq)}}},
+			Usage:   &Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &lastReq, &lastHeader
+}
+
+// newTestService creates a service for the fake provider
+func newTestService(t *testing.T, url, systemPrompt string, opts ...Option) *Service {
+	t.Helper()
+
+	service, err := NewService(url, "test-model", "key", systemPrompt, zap.NewNop(), opts...)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return service
+}
+
+func TestService_GenerateResponseWithHistory(t *testing.T) {
+	server, lastReq, _ := newTestProvider(t, func(ChatRequest) string { return "pong" })
+	service := newTestService(t, server.URL, "system prompt")
+
+	history := []Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply"},
+	}
+	response, err := service.GenerateResponseWithHistory(context.Background(), history, "ping")
+	if err != nil {
+		t.Fatalf("GenerateResponseWithHistory() error = %v", err)
+	}
+	if response != "pong" {
+		t.Errorf("response = %q, want %q", response, "pong")
+	}
+
+	roles := make([]string, 0, len(lastReq.Messages))
+	for _, msg := range lastReq.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user" {
+		t.Errorf("request roles = %s, want system,user,assistant,user", got)
+	}
+	if service.LastSuccess().IsZero() {
+		t.Errorf("LastSuccess() is zero after a successful completion")
+	}
+}
+
+func TestService_RequestRedaction(t *testing.T) {
+	server, lastReq, _ := newTestProvider(t, func(req ChatRequest) string {
+		return "I will email " + req.Messages[len(req.Messages)-1].Content
+	})
+	service := newTestService(t, server.URL, "prompt", WithRequestRedaction(true))
+
+	response, err := service.GenerateResponse(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatalf("GenerateResponse() error = %v", err)
+	}
+
+	if sent := lastReq.Messages[len(lastReq.Messages)-1].Content; sent != "[EMAIL_1]" {
+		t.Errorf("provider received %q, want placeholder", sent)
+	}
+	if response != "I will email jane@example.com" {
+		t.Errorf("response = %q, want restored email", response)
+	}
+}
+
+func TestService_ProviderErrorRedaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid key sk-abcdefghijklmnopqrstuvwxyz for jane@example.com","prompt":"`+strings.Repeat("x", 2000)+`"}`, http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+	service := newTestService(t, server.URL, "prompt")
+
+	_, err := service.GenerateResponse(context.Background(), "hi")
+	if err == nil {
+		t.Fatal("GenerateResponse() error = nil, want the provider error")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "status 401") || !strings.Contains(msg, "invalid key [SECRET] for [EMAIL]") {
+		t.Errorf("GenerateResponse() error = %q, want the status and the redacted body", msg)
+	}
+	if strings.Contains(msg, "sk-abc") || strings.Contains(msg, "jane@") || len(msg) > 600 {
+		t.Errorf("GenerateResponse() error = %q, want a redacted, truncated body", msg)
+	}
+}
+
+func TestService_PropagatesTraceContext(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	server, _, lastHeader := newTestProvider(t, func(ChatRequest) string { return "ok" })
+	service := newTestService(t, server.URL, "prompt")
+
+	if _, err := service.GenerateResponse(context.Background(), "hi"); err != nil {
+		t.Fatalf("GenerateResponse() error = %v", err)
+	}
+	if lastHeader.Get("traceparent") == "" {
+		t.Errorf("request is missing the traceparent header")
+	}
+}
+
+func TestService_PromptTemplate(t *testing.T) {
+	server, lastReq, _ := newTestProvider(t, func(ChatRequest) string { return "ok" })
+	service := newTestService(t, server.URL, "Greet {{.UserFirstName}} from {{.Vars.company}}.",
+		WithPromptVars(map[string]string{"company": "Acme"}))
+
+	req := Request{Message: "hi", PromptData: prompt.Data{UserFirstName: "Jane"}}
+	if _, err := service.Complete(context.Background(), req); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if got := lastReq.Messages[0].Content; got != "Greet Jane from Acme." {
+		t.Errorf("system prompt = %q, want rendered template", got)
+	}
+}
+
+func TestNewService_InvalidPromptTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt string
+	}{
+		{name: "Syntax error", prompt: "Hello {{.UserFirstName"},
+		{name: "Unknown field", prompt: "Hello {{.FirstName}}"},
+		{name: "Missing custom variable", prompt: "Welcome to {{.Vars.company}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewService("http://localhost", "model", "key", tt.prompt, zap.NewNop()); err == nil {
+				t.Errorf("NewService() error = nil, want template error")
+			}
+		})
+	}
+}
+
+func TestService_SetPrompt(t *testing.T) {
+	server, lastReq, _ := newTestProvider(t, func(ChatRequest) string { return "ok" })
+	service := newTestService(t, server.URL, "old prompt")
+
+	if err := service.SetPrompt("Hello {{.Unknown}}", nil, nil); err == nil {
+		t.Fatalf("SetPrompt() error = nil, want template error")
+	}
+	if _, err := service.GenerateResponse(context.Background(), "hi"); err != nil {
+		t.Fatalf("GenerateResponse() error = %v", err)
+	}
+	if got := lastReq.Messages[0].Content; got != "old prompt" {
+		t.Errorf("system prompt = %q, want the previous prompt after a failed swap", got)
+	}
+
+	examples := []Message{{Role: "user", Content: "q"}, {Role: "assistant", Content: "a"}}
+	if err := service.SetPrompt("new prompt", nil, examples); err != nil {
+		t.Fatalf("SetPrompt() error = %v", err)
+	}
+	if _, err := service.GenerateResponse(context.Background(), "hi"); err != nil {
+		t.Fatalf("GenerateResponse() error = %v", err)
+	}
+	if got := lastReq.Messages[0].Content; got != "new prompt" || len(lastReq.Messages) != 4 {
+		t.Errorf("request = %+v, want new prompt with examples", lastReq.Messages)
+	}
+}
+
+func TestEmbedder_Embed(t *testing.T) {
+	var lastReq EmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&lastReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Providers may return the embeddings in any order
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	vectors, err := NewEmbedder(server.URL, "embed-model", "key").Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if lastReq.Model != "embed-model" || strings.Join(lastReq.Input, ",") != "a,b" {
+		t.Errorf("request = %+v, want model embed-model with inputs a,b", lastReq)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("Embed() = %v, want [[1 0] [0 1]]", vectors)
+	}
+
+	if _, err := NewEmbedder(server.URL, "embed-model", "wrong").Embed(context.Background(), []string{"a"}); err == nil {
+		t.Error("Embed() with a rejected request succeeded")
+	}
+}
@@ -0,0 +1,42 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResponseFormat asks the model for a JSON answer matching a schema
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema names the schema of a structured answer
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+// JSONSchemaFormat returns a response format requiring answers that match schema
+func JSONSchemaFormat(name string, schema json.RawMessage) *ResponseFormat {
+	return &ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &JSONSchema{Name: name, Schema: schema, Strict: true},
+	}
+}
+
+// DecodeJSON unmarshals a structured answer into v. Providers that ignore the
+// response format may wrap the JSON in a Markdown code block, which is removed
+func DecodeJSON(content string, v any) error {
+	content = strings.TrimSpace(content)
+	if body, ok := strings.CutPrefix(content, "```"); ok {
+		body = strings.TrimPrefix(body, "json")
+		content = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+	}
+	if err := json.Unmarshal([]byte(content), v); err != nil {
+		return fmt.Errorf("failed to decode structured answer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package ai
+
+import "testing"
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "Plain JSON", content: `{"text": "call Bob"}`, want: "call Bob"},
+		{name: "Code block", content: "```json\n{\"text\": \"call Bob\"}\n```", want: "call Bob"},
+		{name: "Code block without language", content: "```\n{\"text\": \"call Bob\"}\n```", want: "call Bob"},
+		{name: "Not JSON", content: "Sure! I'll remind you.", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Text string `json:"text"`
+			}
+			err := DecodeJSON(tt.content, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Text != tt.want {
+				t.Errorf("DecodeJSON() text = %q, want %q", got.Text, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tgbot-skeleton/internal/logger"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// defaultMaxToolIterations is the number of tool-calling rounds allowed per
+// completion unless WithTools sets another limit
+const defaultMaxToolIterations = 5
+
+// Tool is a function the model can call while answering
+type Tool interface {
+	// Name identifies the tool to the model, e.g. "current_time"
+	Name() string
+	// Description tells the model what the tool does and when to use it
+	Description() string
+	// Parameters is the JSON Schema of the arguments object
+	Parameters() json.RawMessage
+	// Call runs the tool with the arguments chosen by the model; the result
+	// and errors are passed back to the model as text
+	Call(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+// ToolDefinition describes a tool in a chat request
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a function the model may call
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a call of a tool requested by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the function name and its JSON-encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolResult records a tool call made while answering a request
+type ToolResult struct {
+	Name      string
+	Arguments string
+	Result    string
+	// Err is set when the tool failed; its message was passed to the model
+	Err      error
+	Duration time.Duration
+}
+
+// WithTools lets the model call tools, in at most maxIterations rounds per
+// completion; a limit of zero or less uses the default
+func WithTools(tools []Tool, maxIterations int) Option {
+	return func(s *Service) {
+		s.tools = make(map[string]Tool, len(tools))
+		s.toolDefinitions = make([]ToolDefinition, 0, len(tools))
+		for _, tool := range tools {
+			s.tools[tool.Name()] = tool
+			s.toolDefinitions = append(s.toolDefinitions, ToolDefinition{
+				Type: "function",
+				Function: FunctionDefinition{
+					Name:        tool.Name(),
+					Description: tool.Description(),
+					Parameters:  tool.Parameters(),
+				},
+			})
+		}
+		s.maxToolIterations = maxIterations
+		if s.maxToolIterations <= 0 {
+			s.maxToolIterations = defaultMaxToolIterations
+		}
+	}
+}
+
+// Tools returns the names of the tools the model can call
+func (s *Service) Tools() []string {
+	names := make([]string, len(s.toolDefinitions))
+	for i, def := range s.toolDefinitions {
+		names[i] = def.Function.Name
+	}
+	return names
+}
+
+// callTool runs a tool call of the model and returns the message with its
+// result; failures are reported to the model, which may recover from them
+func (s *Service) callTool(ctx context.Context, call ToolCall) (Message, ToolResult) {
+	ctx, span := tracing.Tracer().Start(ctx, "ai.CallTool", trace.WithAttributes(
+		attribute.String("ai.tool", call.Function.Name),
+	))
+	record := ToolResult{Name: call.Function.Name, Arguments: call.Function.Arguments}
+
+	start := time.Now()
+	label := call.Function.Name
+	tool, ok := s.tools[call.Function.Name]
+	if !ok {
+		// Names made up by the model must not create new metric series
+		label = "unknown"
+		record.Err = fmt.Errorf("unknown tool %q", call.Function.Name)
+	} else {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		record.Result, record.Err = tool.Call(ctx, arguments)
+	}
+	record.Duration = time.Since(start)
+	tracing.End(span, record.Err)
+
+	result, content := "success", record.Result
+	if record.Err != nil {
+		result, content = "error", "Error: "+record.Err.Error()
+	}
+	metrics.AIToolCalls.WithLabelValues(label, result).Inc()
+	logger.FromContext(ctx, s.logger).Info("called tool",
+		zap.String("tool", call.Function.Name),
+		s.contentField("arguments", call.Function.Arguments),
+		zap.Duration("duration", record.Duration),
+		zap.Error(record.Err),
+	)
+
+	return Message{Role: "tool", Content: content, ToolCallID: call.ID}, record
+}
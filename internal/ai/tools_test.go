@@ -0,0 +1,210 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoTool returns its arguments, or fails when asked to
+type echoTool struct{}
+
+func (echoTool) Name() string        { return "echo" }
+func (echoTool) Description() string { return "Echoes the arguments" }
+func (echoTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{"type": "object", "properties": {"text": {"type": "string"}}}`)
+}
+func (echoTool) Call(_ context.Context, arguments json.RawMessage) (string, error) {
+	if strings.Contains(string(arguments), "fail") {
+		return "", errors.New("echo failed")
+	}
+	return string(arguments), nil
+}
+
+// newToolProvider starts a fake provider answering each request with the next
+// of replies, repeating the last one, and records all requests
+func newToolProvider(t *testing.T, replies ...Message) (*httptest.Server, func() []ChatRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		reply := replies[min(len(requests), len(replies))-1]
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: reply}},
+			Usage:   &Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []ChatRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]ChatRequest(nil), requests...)
+	}
+}
+
+// toolCallMessage is an assistant message calling tools
+func toolCallMessage(calls ...ToolCall) Message {
+	return Message{Role: "assistant", ToolCalls: calls}
+}
+
+func TestService_ToolLoop(t *testing.T) {
+	server, requests := newToolProvider(t,
+		toolCallMessage(
+			ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "echo", Arguments: `{"text":"hi"}`}},
+			ToolCall{ID: "call_2", Type: "function", Function: FunctionCall{Name: "echo", Arguments: `{"text":"fail"}`}},
+			ToolCall{ID: "call_3", Type: "function", Function: FunctionCall{Name: "rm", Arguments: `{}`}},
+		),
+		Message{Role: "assistant", Content: "done"},
+	)
+	service := newTestService(t, server.URL, "system prompt", WithTools([]Tool{echoTool{}}, 3))
+
+	completion, err := service.Complete(context.Background(), Request{Message: "go"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if completion.Content != "done" {
+		t.Errorf("Content = %q, want %q", completion.Content, "done")
+	}
+	if completion.Usage.TotalTokens != 24 {
+		t.Errorf("Usage.TotalTokens = %d, want the sum of both rounds, 24", completion.Usage.TotalTokens)
+	}
+	if len(completion.ToolCalls) != 3 {
+		t.Fatalf("ToolCalls = %+v, want 3 calls", completion.ToolCalls)
+	}
+	if call := completion.ToolCalls[0]; call.Name != "echo" || call.Result != `{"text":"hi"}` || call.Err != nil {
+		t.Errorf("ToolCalls[0] = %+v, want a successful echo", call)
+	}
+	if completion.ToolCalls[1].Err == nil || completion.ToolCalls[2].Err == nil {
+		t.Errorf("ToolCalls = %+v, want the failed and the unknown call to record errors", completion.ToolCalls)
+	}
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(reqs))
+	}
+	if len(reqs[0].Tools) != 1 || reqs[0].Tools[0].Function.Name != "echo" || reqs[0].ToolChoice != "auto" {
+		t.Errorf("first request tools = %+v, choice %q, want echo with auto", reqs[0].Tools, reqs[0].ToolChoice)
+	}
+	messages := reqs[1].Messages
+	if len(messages) != 6 {
+		t.Fatalf("second request has %d messages, want system, user, assistant and three tool results", len(messages))
+	}
+	if len(messages[2].ToolCalls) != 3 {
+		t.Errorf("assistant message = %+v, want the tool calls", messages[2])
+	}
+	want := []Message{
+		{Role: "tool", Content: `{"text":"hi"}`, ToolCallID: "call_1"},
+		{Role: "tool", Content: "Error: echo failed", ToolCallID: "call_2"},
+		{Role: "tool", Content: `Error: unknown tool "rm"`, ToolCallID: "call_3"},
+	}
+	for i, msg := range messages[3:] {
+		if msg.Role != want[i].Role || msg.Content != want[i].Content || msg.ToolCallID != want[i].ToolCallID {
+			t.Errorf("tool message %d = %+v, want %+v", i, msg, want[i])
+		}
+	}
+}
+
+func TestService_ToolLoopRedaction(t *testing.T) {
+	server, requests := newToolProvider(t,
+		toolCallMessage(
+			ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "echo", Arguments: `{"text":"[EMAIL_1]"}`}},
+			ToolCall{ID: "call_2", Type: "function", Function: FunctionCall{Name: "echo", Arguments: `{"text":"bob@example.com"}`}},
+		),
+		Message{Role: "assistant", Content: "done"},
+	)
+	service := newTestService(t, server.URL, "system prompt", WithTools([]Tool{echoTool{}}, 3), WithRequestRedaction(true))
+
+	completion, err := service.Complete(context.Background(), Request{Message: "write to jane@example.com"})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if len(completion.ToolCalls) != 2 {
+		t.Fatalf("ToolCalls = %+v, want 2 calls", completion.ToolCalls)
+	}
+	if got := completion.ToolCalls[0].Result; got != `{"text":"jane@example.com"}` {
+		t.Errorf("ToolCalls[0].Result = %q, want the tool to get the restored email", got)
+	}
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(reqs))
+	}
+	messages := reqs[1].Messages
+	if len(messages) != 5 {
+		t.Fatalf("second request has %d messages, want system, user, assistant and two tool results", len(messages))
+	}
+	want := []string{`{"text":"[EMAIL_1]"}`, `{"text":"[EMAIL_2]"}`}
+	for i, msg := range messages[3:] {
+		if msg.Content != want[i] {
+			t.Errorf("tool message %d = %q, want %q", i, msg.Content, want[i])
+		}
+	}
+}
+
+func TestService_ToolLoopLimit(t *testing.T) {
+	server, requests := newToolProvider(t, toolCallMessage(
+		ToolCall{ID: "call", Type: "function", Function: FunctionCall{Name: "echo", Arguments: `{}`}},
+	))
+	service := newTestService(t, server.URL, "system prompt", WithTools([]Tool{echoTool{}}, 2))
+
+	if _, err := service.Complete(context.Background(), Request{Message: "go"}); err == nil {
+		t.Fatal("Complete() error = nil, want an error when the model never stops calling tools")
+	}
+	reqs := requests()
+	if len(reqs) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(reqs))
+	}
+	for i, req := range reqs {
+		want := "auto"
+		if i == len(reqs)-1 {
+			want = "none"
+		}
+		if req.ToolChoice != want {
+			t.Errorf("request %d tool_choice = %q, want %q", i, req.ToolChoice, want)
+		}
+	}
+}
+
+func TestService_WithoutTools(t *testing.T) {
+	server, requests := newToolProvider(t, Message{Role: "assistant", Content: "plain"})
+	service := newTestService(t, server.URL, "system prompt")
+
+	if _, err := service.Complete(context.Background(), Request{Message: "go"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if req := requests()[0]; req.Tools != nil || req.ToolChoice != "" {
+		t.Errorf("request tools = %+v, choice %q, want none", req.Tools, req.ToolChoice)
+	}
+}
+
+func TestService_StructuredRequestWithoutTools(t *testing.T) {
+	server, requests := newToolProvider(t, Message{Role: "assistant", Content: `{"ok": true}`})
+	service := newTestService(t, server.URL, "system prompt", WithTools([]Tool{echoTool{}}, 3))
+
+	format := JSONSchemaFormat("result", json.RawMessage(`{"type": "object"}`))
+	if _, err := service.Complete(context.Background(), Request{Message: "go", ResponseFormat: format}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	req := requests()[0]
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" || req.ResponseFormat.JSONSchema.Name != "result" {
+		t.Errorf("request response_format = %+v, want the result schema", req.ResponseFormat)
+	}
+	if req.Tools != nil || req.ToolChoice != "" {
+		t.Errorf("request tools = %+v, choice %q, want none for a structured request", req.Tools, req.ToolChoice)
+	}
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/usage"
+
+	"go.uber.org/zap"
+)
+
+// statsWindows are the periods, in days, reported by /stats
+var statsWindows = []int{1, 7, 30}
+
+// statsTopModels caps the number of models listed by /stats
+const statsTopModels = 5
+
+// windowStats summarizes activity within one stats window
+type windowStats struct {
+	Days        int
+	ActiveUsers int
+	Messages    int
+	Errors      int64
+	AvgLatency  time.Duration
+}
+
+// botStats holds everything reported by /stats
+type botStats struct {
+	Windows      []windowStats
+	TopModels    []usage.Group
+	Chats        int
+	PrivateChats int
+	BlockedChats int
+}
+
+// isAdmin reports whether a user may run administrative commands
+func (h *Handler) isAdmin(userID int64) bool {
+	// Settings are the same in every language, so no user context is needed
+	return slices.Contains(h.botConfig(context.Background()).AdminIDs, userID)
+}
+
+// handleStats reports active users, messages, errors, latency and top models
+func (h *Handler) handleStats(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+
+	stats, err := h.collectStats(ctx, time.Now())
+	if err != nil {
+		h.log(ctx).Error("failed to collect stats", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	h.sendMessage(ctx, chatID, formatStats(stats, h.config.Usage.Currency))
+}
+
+// collectStats loads usage, error counters and known chats for the stats windows
+func (h *Handler) collectStats(ctx context.Context, now time.Time) (*botStats, error) {
+	longest := statsWindows[len(statsWindows)-1]
+	now = now.UTC()
+	from := now.AddDate(0, 0, 1-longest).Format(usage.DayFormat)
+	to := now.Format(usage.DayFormat)
+
+	records, err := h.store.ListUsage(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage: %w", err)
+	}
+
+	errorsByDay := make(map[string]int64, longest)
+	for i := 0; i < longest; i++ {
+		day := now.AddDate(0, 0, -i).Format(usage.DayFormat)
+		value, err := h.store.GetCounter(ctx, dailyCounter(counterAIErrors, day))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load error counter: %w", err)
+		}
+		errorsByDay[day] = value
+	}
+
+	chats, err := h.store.ListChats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chats: %w", err)
+	}
+
+	return computeStats(records, errorsByDay, chats, now), nil
+}
+
+// computeStats aggregates usage records and error counts into stats windows
+// ending on now's day
+func computeStats(records []storage.UsageRecord, errorsByDay map[string]int64, chats []storage.ChatInfo, now time.Time) *botStats {
+	stats := &botStats{}
+
+	for _, days := range statsWindows {
+		from := now.AddDate(0, 0, 1-days).Format(usage.DayFormat)
+		window := windowStats{Days: days}
+
+		users := make(map[int64]bool)
+		var latencyMs int64
+		for _, record := range records {
+			if record.Day < from {
+				continue
+			}
+			users[record.UserID] = true
+			window.Messages += record.Requests
+			latencyMs += record.LatencyMs
+		}
+		for day, value := range errorsByDay {
+			if day >= from {
+				window.Errors += value
+			}
+		}
+
+		window.ActiveUsers = len(users)
+		if window.Messages > 0 {
+			window.AvgLatency = time.Duration(latencyMs/int64(window.Messages)) * time.Millisecond
+		}
+		stats.Windows = append(stats.Windows, window)
+	}
+
+	stats.TopModels = usage.GroupBy(records, func(r storage.UsageRecord) string { return r.Model })
+	if len(stats.TopModels) > statsTopModels {
+		stats.TopModels = stats.TopModels[:statsTopModels]
+	}
+
+	stats.Chats = len(chats)
+	for _, chat := range chats {
+		if chat.Type == "private" {
+			stats.PrivateChats++
+		}
+		if chat.Blocked {
+			stats.BlockedChats++
+		}
+	}
+
+	return stats
+}
+
+// formatStats renders stats as a chat message
+func formatStats(stats *botStats, currency string) string {
+	var b strings.Builder
+	b.WriteString("📈 Bot statistics\n\n")
+
+	writeWindows := func(label string, value func(w windowStats) string) {
+		parts := make([]string, 0, len(stats.Windows))
+		for _, w := range stats.Windows {
+			if w.Days == 1 {
+				parts = append(parts, value(w)+" today")
+			} else {
+				parts = append(parts, fmt.Sprintf("%s (%dd)", value(w), w.Days))
+			}
+		}
+		fmt.Fprintf(&b, "%s: %s\n", label, strings.Join(parts, " · "))
+	}
+
+	writeWindows("👥 Active users", func(w windowStats) string { return fmt.Sprint(w.ActiveUsers) })
+	writeWindows("💬 Messages", func(w windowStats) string { return fmt.Sprint(w.Messages) })
+	writeWindows("⚠️ AI errors", func(w windowStats) string { return fmt.Sprint(w.Errors) })
+	writeWindows("⏱ Avg AI latency", func(w windowStats) string {
+		return w.AvgLatency.Round(100 * time.Millisecond).String()
+	})
+	fmt.Fprintf(&b, "💾 Known chats: %d (%d private, %d groups), %d blocked\n",
+		stats.Chats, stats.PrivateChats, stats.Chats-stats.PrivateChats, stats.BlockedChats)
+
+	if len(stats.TopModels) > 0 {
+		last := stats.Windows[len(stats.Windows)-1].Days
+		fmt.Fprintf(&b, "\n🏆 Top models (%dd):\n", last)
+		for _, model := range stats.TopModels {
+			fmt.Fprintf(&b, "• %s: %d req, %s\n", model.Key, model.Requests, formatCost(model.Cost, currency))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func TestComputeStats(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	records := []storage.UsageRecord{
+		{Day: "2024-05-31", UserID: 1, Model: "a", Requests: 2, LatencyMs: 2000, Cost: 0.1},
+		{Day: "2024-05-28", UserID: 2, Model: "b", Requests: 1, LatencyMs: 4000, Cost: 0.5},
+		{Day: "2024-05-10", UserID: 3, Model: "a", Requests: 1, LatencyMs: 1000, Cost: 0.1},
+	}
+	errorsByDay := map[string]int64{"2024-05-31": 1, "2024-05-20": 2}
+	chats := []storage.ChatInfo{
+		{ChatID: 1, Type: "private"},
+		{ChatID: 2, Type: "private", Blocked: true},
+		{ChatID: -100, Type: "supergroup"},
+	}
+
+	stats := computeStats(records, errorsByDay, chats, now)
+
+	expected := []windowStats{
+		{Days: 1, ActiveUsers: 1, Messages: 2, Errors: 1, AvgLatency: time.Second},
+		{Days: 7, ActiveUsers: 2, Messages: 3, Errors: 1, AvgLatency: 2 * time.Second},
+		{Days: 30, ActiveUsers: 3, Messages: 4, Errors: 3, AvgLatency: 1750 * time.Millisecond},
+	}
+	for i, want := range expected {
+		if stats.Windows[i] != want {
+			t.Errorf("window %d = %+v, want %+v", i, stats.Windows[i], want)
+		}
+	}
+	if len(stats.TopModels) != 2 || stats.TopModels[0].Key != "b" {
+		t.Errorf("TopModels = %+v, want model b first", stats.TopModels)
+	}
+	if stats.Chats != 3 || stats.PrivateChats != 2 || stats.BlockedChats != 1 {
+		t.Errorf("chat stats = %d/%d/%d, want 3/2/1", stats.Chats, stats.PrivateChats, stats.BlockedChats)
+	}
+}
+
+func TestBroadcaster_Run(t *testing.T) {
+	var blocked []int64
+	var final broadcastResult
+	attempts := map[int64]int{}
+
+	b := &broadcaster{
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		send: func(_ context.Context, chatID int64) error {
+			attempts[chatID]++
+			switch chatID {
+			case 2:
+				return &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}
+			case 3:
+				return &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}
+			case 4:
+				if attempts[chatID] == 1 {
+					return &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 0}}
+				}
+			}
+			return nil
+		},
+		blocked: func(_ context.Context, chatID int64) {
+			blocked = append(blocked, chatID)
+		},
+		progress: func(result broadcastResult, done bool) {
+			if done {
+				final = result
+			}
+		},
+		interval: time.Hour,
+	}
+
+	result := b.run(context.Background(), []int64{1, 2, 3, 4})
+
+	want := broadcastResult{Total: 4, Sent: 2, Blocked: 1, Failed: 1}
+	if result != want || final != want {
+		t.Errorf("run() = %+v, final progress %+v, want %+v", result, final, want)
+	}
+	if len(blocked) != 1 || blocked[0] != 2 {
+		t.Errorf("blocked chats = %v, want [2]", blocked)
+	}
+	if attempts[4] != 2 {
+		t.Errorf("chat 4 attempts = %d, want retry after 429", attempts[4])
+	}
+}
+
+func TestBroadcastTargets(t *testing.T) {
+	cfg := &config.Config{Bot: config.BotConfig{OperatorChatID: -100}}
+	handler := &Handler{config: cfg, logger: zap.NewNop()}
+	handler.botSettings.Store(&cfg.Bot)
+
+	targets, blocked := handler.broadcastTargets([]storage.ChatInfo{
+		{ChatID: -100, Type: "supergroup"},
+		{ChatID: 1, Type: "private"},
+		{ChatID: 2, Type: "private", Blocked: true},
+		{ChatID: -200, Type: "group"},
+	})
+	if len(targets) != 2 || targets[0] != 1 || targets[1] != -200 || blocked != 1 {
+		t.Errorf("broadcastTargets() = %v, %d, want [1 -200] without the operator chat and 1 blocked", targets, blocked)
+	}
+}
+
+func TestParseBroadcastArgs(t *testing.T) {
+	tests := []struct {
+		args   string
+		dryRun bool
+		text   string
+	}{
+		{args: "Hello everyone", text: "Hello everyone"},
+		{args: "--dry-run New feature!\nTry it", dryRun: true, text: "New feature!\nTry it"},
+		{args: "  ", text: ""},
+	}
+
+	for _, tt := range tests {
+		dryRun, text := parseBroadcastArgs(tt.args)
+		if dryRun != tt.dryRun || text != tt.text {
+			t.Errorf("parseBroadcastArgs(%q) = %v, %q, want %v, %q", tt.args, dryRun, text, tt.dryRun, tt.text)
+		}
+	}
+}
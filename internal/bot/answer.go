@@ -0,0 +1,249 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+	"tgbot-skeleton/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// answerCallbackPrefix starts the callback data of the buttons under AI answers
+const answerCallbackPrefix = "answer:"
+
+// maxMessageLength is the longest text Telegram accepts in a message
+const maxMessageLength = 4096
+
+// Actions of the buttons under AI answers
+const (
+	answerRegenerate = "regenerate"
+	answerContinue   = "continue"
+	answerShorter    = "shorter"
+	answerLonger     = "longer"
+)
+
+// answerActions lists the answer actions in keyboard order
+var answerActions = []string{answerRegenerate, answerContinue, answerShorter, answerLonger}
+
+// answerLabels are the button labels used when the user's catalog has none
+var answerLabels = map[string]string{
+	answerRegenerate: "🔄 Regenerate",
+	answerContinue:   "➡️ Continue",
+	answerShorter:    "✂️ Shorter",
+	answerLonger:     "📝 Longer",
+}
+
+// answerInstructions are sent to the AI after the previous answer; regenerate
+// asks the original question again instead
+var answerInstructions = map[string]string{
+	answerContinue: "Continue your previous answer exactly where it stopped. Do not repeat what you already wrote.",
+	answerShorter:  "Rewrite your previous answer to be much more concise. Keep the key points.",
+	answerLonger:   "Rewrite your previous answer in more detail, with explanations and examples.",
+}
+
+// answerKeyboard returns the buttons shown under AI answers, labelled in the
+// language of catalog when it translates them
+func answerKeyboard(catalog *i18n.Catalog) tgbotapi.InlineKeyboardMarkup {
+	button := func(action string) tgbotapi.InlineKeyboardButton {
+		label := answerLabels[action]
+		if catalog != nil && catalog.Buttons[action] != "" {
+			label = catalog.Buttons[action]
+		}
+		return tgbotapi.NewInlineKeyboardButtonData(label, answerCallbackPrefix+action)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(button(answerRegenerate), button(answerContinue)),
+		tgbotapi.NewInlineKeyboardRow(button(answerShorter), button(answerLonger)),
+	)
+}
+
+// validateCatalogButtons checks that catalogs only translate known buttons
+func validateCatalogButtons(bundle *i18n.Bundle) error {
+	for _, catalog := range bundle.List() {
+		for name, label := range catalog.Buttons {
+			if _, ok := answerLabels[name]; !ok {
+				return fmt.Errorf("catalog %q labels unknown button %q", catalog.Code, name)
+			}
+			if strings.TrimSpace(label) == "" {
+				return fmt.Errorf("catalog %q: label of button %q must not be empty", catalog.Code, name)
+			}
+		}
+	}
+	return nil
+}
+
+// answerMarkup returns the buttons for an AI answer to the current user, or nil
+// when BOT_ANSWER_ACTIONS is disabled
+func (h *Handler) answerMarkup(ctx context.Context) *tgbotapi.InlineKeyboardMarkup {
+	if !h.botConfig(ctx).AnswerActions {
+		return nil
+	}
+	var catalog *i18n.Catalog
+	if lang := languageFrom(ctx); lang != nil {
+		catalog = lang.catalog
+	}
+	markup := answerKeyboard(catalog)
+	return &markup
+}
+
+// deliverAnswer sends an AI answer converted to Telegram Markdown, with the
+// answer buttons when they are enabled
+func (h *Handler) deliverAnswer(ctx context.Context, chatID int64, content string) (tgbotapi.Message, error) {
+	msg := tgbotapi.NewMessage(chatID, utils.ConvertMarkdownToTelegram(content))
+	if markup := h.answerMarkup(ctx); markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	return h.deliver(ctx, msg)
+}
+
+// editAnswer replaces the text of an AI answer in place, keeping its buttons and
+// falling back to plain text if Telegram cannot parse its Markdown
+func (h *Handler) editAnswer(ctx context.Context, chatID int64, messageID int, content string) error {
+	span := startTelegramSpan(ctx, "editMessageText", chatID)
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, utils.ConvertMarkdownToTelegram(content))
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	edit.ReplyMarkup = h.answerMarkup(ctx)
+
+	_, err := h.bot.Request(edit)
+	if err != nil && isMarkdownError(err) {
+		h.log(ctx).Warn("telegram rejected markdown, editing as plain text", zap.Error(err))
+		span.AddEvent("markdown_fallback")
+		edit.ParseMode = ""
+		_, err = h.bot.Request(edit)
+	}
+	// Regenerating can produce the same text, which Telegram refuses as an edit
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		err = nil
+	}
+	tracing.End(span, err)
+	return err
+}
+
+// answerRequest returns the history and message of the AI request for an
+// action on the answer at index answer to the question at index question
+func answerRequest(conv *storage.Conversation, question, answer int, action string, historyLimit int) ([]ai.Message, string) {
+	if action == answerRegenerate {
+		before := &storage.Conversation{Messages: conv.Messages[:question]}
+		return aiMessages(before.Tail(historyLimit)), conv.Messages[question].Content
+	}
+	upTo := &storage.Conversation{Messages: conv.Messages[:answer+1]}
+	return aiMessages(upTo.Tail(historyLimit)), answerInstructions[action]
+}
+
+// handleAnswerCallback regenerates, continues, shortens or lengthens the AI
+// answer whose button was pressed; only the person who asked may do so
+func (h *Handler) handleAnswerCallback(ctx context.Context, query *tgbotapi.CallbackQuery, action string) {
+	if _, ok := answerLabels[action]; !ok || query.Message == nil {
+		h.answerCallback(ctx, query.ID, "")
+		return
+	}
+	chatID, messageID, userID := query.Message.Chat.ID, query.Message.MessageID, query.From.ID
+	bot := h.botConfig(ctx)
+
+	conv, err := h.store.GetConversation(ctx, chatID)
+	if err != nil {
+		h.log(ctx).Warn("failed to load conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.answerCallback(ctx, query.ID, bot.ErrorMessage)
+		return
+	}
+	answer := conv.AnswerIndex(messageID)
+	question := conv.QuestionIndex(answer)
+	if answer < 0 || question < 0 {
+		h.answerCallback(ctx, query.ID, bot.AnswerExpiredMessage)
+		return
+	}
+	if asker := conv.Messages[question].UserID; asker != 0 && asker != userID {
+		h.answerCallback(ctx, query.ID, bot.AnswerNotYoursMessage)
+		return
+	}
+	// AI answers are paused while an operator handles the chat's ticket
+	if h.operatorChatID() != 0 && h.chatTicket(ctx, chatID) != nil {
+		h.answerCallback(ctx, query.ID, bot.OperatorWaitingMessage)
+		return
+	}
+
+	period := time.Now().UTC().Format("2006-01-02")
+	if h.quotaExceeded(ctx, userID, period) {
+		h.answerCallback(ctx, query.ID, bot.QuotaExceededMessage)
+		return
+	}
+	h.answerCallback(ctx, query.ID, "")
+	h.sendTyping(ctx, chatID)
+
+	h.log(ctx).Info("processing answer action",
+		zap.Int64("chat_id", chatID),
+		zap.Int("message_id", messageID),
+		zap.String("action", action),
+	)
+
+	message := &tgbotapi.Message{From: query.From, Chat: query.Message.Chat}
+	history, text := answerRequest(conv, question, answer, action, h.config.Storage.HistoryLimit)
+	request := h.newRequest(ctx, message, history, text)
+	// Rewrites need the same knowledge as the answer, so search with the question
+	h.addKnowledge(ctx, &request, conv.Messages[question].Content)
+	completion, err := h.aiService.Complete(ctx, request)
+	if err != nil {
+		h.log(ctx).Error("failed to get AI response", zap.Error(err))
+		h.incrementCounter(ctx, counterAIErrors)
+		h.sendMessage(ctx, chatID, bot.ErrorMessage)
+		return
+	}
+
+	// Reload the conversation, which may have grown while the AI was answering
+	conv, err = h.store.GetConversation(ctx, chatID)
+	if err != nil {
+		h.log(ctx).Warn("failed to load conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+		return
+	}
+	if answer = conv.AnswerIndex(messageID); answer < 0 {
+		h.log(ctx).Warn("answer disappeared while processing action", zap.Int("message_id", messageID))
+		return
+	}
+
+	// Rewritten answers do not hand off again, but must not show the marker
+	content, _ := cutHandoffMarker(completion.Content, bot.HandoffMarker)
+	h.applyAnswerAction(ctx, conv, answer, action, content, completion.Model)
+	if err := h.store.SaveConversation(ctx, conv); err != nil {
+		h.log(ctx).Warn("failed to save conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+	}
+	h.accountCompletion(ctx, chatID, userID, period, completion)
+}
+
+// applyAnswerAction shows the new content of an answer and records it in the
+// conversation as an answer of model. A continuation is appended to the answer,
+// or sent as a new message when the joined answer would not fit into one
+func (h *Handler) applyAnswerAction(ctx context.Context, conv *storage.Conversation, answer int, action, content, model string) {
+	chatID := conv.ChatID
+	stored := &conv.Messages[answer]
+	now := time.Now()
+
+	if action == answerContinue {
+		joined := stored.Content + "\n\n" + content
+		if utf8.RuneCountInString(utils.ConvertMarkdownToTelegram(joined)) > maxMessageLength {
+			sent, err := h.deliverAnswer(ctx, chatID, content)
+			if err != nil {
+				h.log(ctx).Error("failed to send message", zap.Error(err))
+			}
+			next := storage.ConversationMessage{Role: "assistant", Content: content, Model: model, MessageID: sent.MessageID, Timestamp: now}
+			conv.Messages = slices.Insert(conv.Messages, answer+1, next)
+			return
+		}
+		content = joined
+	}
+
+	if err := h.editAnswer(ctx, chatID, stored.MessageID, content); err != nil {
+		h.log(ctx).Error("failed to edit answer", zap.Error(err))
+		return
+	}
+	stored.Content, stored.Model, stored.Timestamp = content, model, now
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/storage"
+)
+
+func TestAnswerKeyboard(t *testing.T) {
+	ru := &i18n.Catalog{Code: "ru", Buttons: map[string]string{answerRegenerate: "🔄 Заново"}}
+
+	tests := []struct {
+		name     string
+		catalog  *i18n.Catalog
+		expected []string
+	}{
+		{name: "Default labels", expected: []string{"🔄 Regenerate", "➡️ Continue", "✂️ Shorter", "📝 Longer"}},
+		{name: "Translated labels", catalog: ru, expected: []string{"🔄 Заново", "➡️ Continue", "✂️ Shorter", "📝 Longer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels, data []string
+			for _, row := range answerKeyboard(tt.catalog).InlineKeyboard {
+				for _, button := range row {
+					labels = append(labels, button.Text)
+					data = append(data, *button.CallbackData)
+				}
+			}
+			if len(labels) != len(tt.expected) {
+				t.Fatalf("labels = %v, want %v", labels, tt.expected)
+			}
+			for i := range labels {
+				if labels[i] != tt.expected[i] || data[i] != answerCallbackPrefix+answerActions[i] {
+					t.Errorf("button %d = %q (%q), want %q (%q)", i, labels[i], data[i], tt.expected[i], answerCallbackPrefix+answerActions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAnswerRequest(t *testing.T) {
+	conv := &storage.Conversation{Messages: []storage.ConversationMessage{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "a1", MessageID: 10},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "a2", MessageID: 20},
+	}}
+
+	tests := []struct {
+		name         string
+		action       string
+		historyLimit int
+		history      []string
+		message      string
+	}{
+		{name: "Regenerate asks again", action: answerRegenerate, history: []string{"q1", "a1"}, message: "q2"},
+		{name: "Continue follows the answer", action: answerContinue, history: []string{"q1", "a1", "q2", "a2"}, message: answerInstructions[answerContinue]},
+		{name: "Shorter follows the answer", action: answerShorter, history: []string{"q1", "a1", "q2", "a2"}, message: answerInstructions[answerShorter]},
+		{name: "History limit", action: answerLonger, historyLimit: 2, history: []string{"q2", "a2"}, message: answerInstructions[answerLonger]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, message := answerRequest(conv, 2, 3, tt.action, tt.historyLimit)
+			if message != tt.message {
+				t.Errorf("message = %q, want %q", message, tt.message)
+			}
+			if len(history) != len(tt.history) {
+				t.Fatalf("history = %+v, want %v", history, tt.history)
+			}
+			for i, msg := range history {
+				if msg.Content != tt.history[i] {
+					t.Errorf("history[%d] = %q, want %q", i, msg.Content, tt.history[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCatalogButtons(t *testing.T) {
+	tests := []struct {
+		name    string
+		buttons string
+		wantErr bool
+	}{
+		{name: "Known buttons", buttons: "regenerate: Заново\n  longer: Подробнее"},
+		{name: "Unknown button", buttons: "retry: Ещё раз", wantErr: true},
+		{name: "Empty label", buttons: `shorter: " "`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "ru.yaml"), []byte("buttons:\n  "+tt.buttons+"\n"), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			bundle, err := i18n.Load(dir, &config.BotConfig{}, "")
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if err := validateCatalogButtons(bundle); (err != nil) != tt.wantErr {
+				t.Errorf("validateCatalogButtons() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
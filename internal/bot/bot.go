@@ -12,6 +12,9 @@ import (
 
 	"tgbot-skeleton/internal/ai"
 	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/persona"
+	"tgbot-skeleton/internal/storage"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"go.uber.org/zap"
@@ -23,6 +26,10 @@ type Bot struct {
 	config  *config.Config
 	logger  *zap.Logger
 	handler *Handler
+	store   storage.Store
+
+	aiService *ai.Service
+	readiness *readinessChecker
 }
 
 // New creates a new bot instance
@@ -45,24 +52,112 @@ func New(cfg *config.Config, log *zap.Logger) (*Bot, error) {
 
 	log.Info("authorized on account", zap.String("username", bot.Self.UserName))
 
+	// Create the tools the model may call
+	tools, err := loadTools(&cfg.AI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AI tools: %w", err)
+	}
+
 	// Create AI service
-	aiService := ai.NewService(
+	aiService, err := ai.NewService(
 		cfg.AI.URL,
 		cfg.AI.Model,
 		cfg.AI.APIKey,
 		cfg.AI.Prompt,
 		log,
+		ai.WithContentLogging(cfg.Logging.LogMessageContent),
+		ai.WithRequestRedaction(cfg.AI.RedactPII),
+		ai.WithSampling(cfg.AI.Temperature, cfg.AI.MaxTokens),
+		ai.WithExamples(exampleMessages(cfg.AI.Examples)),
+		ai.WithPromptVars(cfg.AI.Vars),
+		ai.WithTools(tools, cfg.AI.ToolMaxIterations),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize AI service: %w", err)
+	}
+	if len(tools) > 0 {
+		log.Info("enabled AI tools", zap.Strings("tools", aiService.Tools()))
+	}
+
+	// Open storage
+	store, err := storage.New(cfg.Storage.Backend, cfg.Storage.Path, cfg.Storage.MaxMessages, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	// Load personas
+	personas, err := loadPersonas(cfg)
+	if err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to load personas: %w", err)
+	}
+	if personas != nil {
+		log.Info("loaded personas", zap.String("dir", cfg.AI.PromptsDir), zap.Int("count", personas.Len()))
+	}
+	warnUnpricedModels(log, cfg, personas)
+	if err := validateSchedules(cfg.Bot.Schedules, personas, cfg.Bot.Timezone, cfg.Bot.ScheduleMinInterval); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+
+	// Load message catalogs
+	locales, err := loadLocales(cfg)
+	if err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to load locales: %w", err)
+	}
+	if locales != nil {
+		log.Info("loaded locales", zap.String("dir", cfg.Bot.LocalesDir), zap.Int("count", locales.Len()))
+	}
+
+	// Index the knowledge base; embeddings are computed once the bot has started
+	index, err := loadKnowledge(cfg)
+	if err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to load knowledge base: %w", err)
+	}
+	if index != nil {
+		log.Info("indexed knowledge base",
+			zap.String("dir", cfg.Knowledge.Dir),
+			zap.Int("files", index.Files()),
+			zap.Int("chunks", index.Chunks()),
+		)
+	}
 
 	// Create handler
-	handler := NewHandler(bot, log, aiService, cfg)
-
-	return &Bot{
-		api:     bot,
-		config:  cfg,
-		logger:  log,
-		handler: handler,
-	}, nil
+	handler := NewHandler(bot, log, aiService, store, personas, locales, index, cfg)
+
+	b := &Bot{
+		api:       bot,
+		config:    cfg,
+		logger:    log,
+		handler:   handler,
+		store:     store,
+		aiService: aiService,
+	}
+	b.readiness = b.newReadinessChecker()
+
+	return b, nil
+}
+
+// warnUnpricedModels logs models that have no price, so their cost is recorded as zero
+func warnUnpricedModels(log *zap.Logger, cfg *config.Config, personas *persona.Library) {
+	models := []string{cfg.AI.Model}
+	for _, p := range personas.List() {
+		if p.Model != "" {
+			models = append(models, p.Model)
+		}
+	}
+	for _, model := range models {
+		if !cfg.Usage.PriceTable.Has(model) {
+			log.Warn("no price configured for model, usage cost will be recorded as zero", zap.String("model", model))
+		}
+	}
+}
+
+// Close releases resources held by the bot
+func (b *Bot) Close() error {
+	return b.store.Close()
 }
 
 // Start starts the bot
@@ -71,6 +166,26 @@ func (b *Bot) Start(ctx context.Context) error {
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// Purge data past the retention period
+	go b.runJanitor(ctx)
+
+	// Send reminders when they are due
+	go b.runReminders(ctx)
+
+	// Post the AI answers of scheduled jobs
+	go b.runSchedules(ctx)
+
+	// Reload prompts and bot messages on SIGHUP and file changes
+	go b.watchReloads(ctx)
+
+	// Embed the knowledge base without delaying startup
+	if b.handler.knowledgeIndex() != nil && b.handler.embedder != nil {
+		go func() { _, _ = b.handler.reindex(ctx) }()
+	}
+
+	// Show the commands in Telegram's command menu
+	b.handler.registerCommands(ctx)
+
 	// Webhook mode vs long polling
 	if b.config.Telegram.WebhookEnable {
 		return b.startWebhook(ctx)
@@ -101,8 +216,9 @@ func (b *Bot) startWebhook(ctx context.Context) error {
 
 	b.logger.Info("webhook set successfully")
 
-	// Serve webhook
-	http.HandleFunc(b.config.Telegram.WebhookPath, func(w http.ResponseWriter, r *http.Request) {
+	// Serve webhook along with health and metrics endpoints
+	mux := b.newServeMux()
+	mux.HandleFunc(b.config.Telegram.WebhookPath, func(w http.ResponseWriter, r *http.Request) {
 		update, err := b.api.HandleUpdate(r)
 		if err != nil {
 			b.logger.Warn("webhook handle error", zap.Error(err))
@@ -115,20 +231,8 @@ func (b *Bot) startWebhook(ctx context.Context) error {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	// Health endpoint
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			b.logger.Error("failed to write health response", zap.Error(err))
-		}
-	})
-
 	b.logger.Info("starting HTTP server for webhook", zap.String("address", b.config.Server.Address))
-	go func() {
-		if err := http.ListenAndServe(b.config.Server.Address, nil); err != nil {
-			b.logger.Error("HTTP server error", zap.Error(err))
-		}
-	}()
+	go b.serveHTTP(mux)
 
 	// Wait for shutdown signal
 	<-ctx.Done()
@@ -147,18 +251,8 @@ func (b *Bot) startWebhook(ctx context.Context) error {
 
 // startLongPolling starts the bot in long polling mode
 func (b *Bot) startLongPolling(ctx context.Context) error {
-	// Health endpoint
-	go func() {
-		http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			if _, err := w.Write([]byte("OK")); err != nil {
-				b.logger.Error("failed to write health response", zap.Error(err))
-			}
-		})
-		if err := http.ListenAndServe(b.config.Server.Address, nil); err != nil {
-			b.logger.Error("HTTP server error", zap.Error(err))
-		}
-	}()
+	// Health and metrics endpoints
+	go b.serveHTTP(b.newServeMux())
 
 	// Long polling loop
 	u := tgbotapi.NewUpdate(0)
@@ -171,6 +265,12 @@ func (b *Bot) startLongPolling(ctx context.Context) error {
 			b.logger.Info("shutting down")
 			return nil
 		case update := <-updates:
+			metrics.QueueDepth.Set(float64(len(updates)))
+			// Inline queries wait for the user to stop typing, which must not hold up other updates
+			if update.InlineQuery != nil {
+				go b.handler.HandleUpdate(ctx, update)
+				continue
+			}
 			b.handler.HandleUpdate(ctx, update)
 		}
 	}
@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// broadcastProgressInterval is how often the progress message is updated
+const broadcastProgressInterval = 5 * time.Second
+
+// broadcastMaxAttempts limits retries of a message rejected with 429 Too Many Requests
+const broadcastMaxAttempts = 3
+
+// broadcastResult counts the outcome of a broadcast
+type broadcastResult struct {
+	Total   int
+	Sent    int
+	Blocked int
+	Failed  int
+}
+
+// format renders the result with a message taking the sent, total, blocked
+// and failed counts
+func (r broadcastResult) format(message string) string {
+	return fmt.Sprintf(message, r.Sent, r.Total, r.Blocked, r.Failed)
+}
+
+// broadcaster delivers a message to many chats within Telegram's rate limits
+type broadcaster struct {
+	limiter *rate.Limiter
+	// send delivers the message to a single chat
+	send func(ctx context.Context, chatID int64) error
+	// blocked is called for chats where the bot was blocked or removed
+	blocked func(ctx context.Context, chatID int64)
+	// progress is called at most every interval and once at the end
+	progress func(result broadcastResult, done bool)
+	interval time.Duration
+}
+
+// run sends the message to every chat and returns the outcome; it stops early
+// when ctx is cancelled
+func (b *broadcaster) run(ctx context.Context, chatIDs []int64) broadcastResult {
+	result := broadcastResult{Total: len(chatIDs)}
+	lastProgress := time.Now()
+
+	for _, chatID := range chatIDs {
+		err := b.deliver(ctx, chatID)
+		switch {
+		case err == nil:
+			result.Sent++
+		case ctx.Err() != nil:
+			b.progress(result, true)
+			return result
+		case isBlockedError(err):
+			result.Blocked++
+			b.blocked(ctx, chatID)
+		default:
+			result.Failed++
+		}
+
+		if time.Since(lastProgress) >= b.interval {
+			b.progress(result, false)
+			lastProgress = time.Now()
+		}
+	}
+
+	b.progress(result, true)
+	return result
+}
+
+// deliver sends to one chat, waiting for the rate limiter and retrying when
+// Telegram asks to slow down
+func (b *broadcaster) deliver(ctx context.Context, chatID int64) error {
+	var err error
+	for attempt := 0; attempt < broadcastMaxAttempts; attempt++ {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err = b.send(ctx, chatID)
+		wait, ok := retryAfter(err)
+		if !ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return err
+}
+
+// isBlockedError reports whether Telegram refused delivery because the user
+// blocked the bot or the bot was removed from the chat
+func isBlockedError(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+// retryAfter returns how long Telegram asked to wait after a 429 response
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second, true
+}
+
+// parseBroadcastArgs splits "/broadcast [--dry-run] <text>" arguments
+func parseBroadcastArgs(args string) (dryRun bool, text string) {
+	text = strings.TrimSpace(args)
+	if rest, ok := strings.CutPrefix(text, "--dry-run"); ok {
+		return true, strings.TrimSpace(rest)
+	}
+	return false, text
+}
+
+// handleBroadcast sends an announcement to every known chat that has not blocked the bot
+func (h *Handler) handleBroadcast(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+
+	dryRun, text := parseBroadcastArgs(call.argText)
+	if text == "" {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).BroadcastSyntaxMessage)
+		return
+	}
+
+	chats, err := h.store.ListChats(ctx)
+	if err != nil {
+		h.log(ctx).Error("failed to load chats", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	targets, blocked := h.broadcastTargets(chats)
+
+	if dryRun {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).BroadcastDryRunMessage, len(targets), blocked))
+		h.sendMessage(ctx, chatID, text)
+		return
+	}
+
+	if !h.broadcasting.CompareAndSwap(false, true) {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).BroadcastBusyMessage)
+		return
+	}
+
+	status, err := h.deliverMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).BroadcastStartedMessage, len(targets)))
+	if err != nil {
+		h.broadcasting.Store(false)
+		h.log(ctx).Error("failed to send broadcast status", zap.Error(err))
+		return
+	}
+
+	h.log(ctx).Info("starting broadcast",
+		zap.Int64("admin_id", call.message.From.ID),
+		zap.Int("chats", len(targets)),
+	)
+
+	b := &broadcaster{
+		limiter: rate.NewLimiter(rate.Limit(h.botConfig(ctx).BroadcastRate), 1),
+		send: func(ctx context.Context, target int64) error {
+			_, err := h.deliverMessage(ctx, target, text)
+			return err
+		},
+		blocked: func(ctx context.Context, target int64) {
+			if err := h.store.SetChatBlocked(ctx, target, true); err != nil {
+				h.log(ctx).Warn("failed to mark chat as blocked", zap.Int64("chat_id", target), zap.Error(err))
+			}
+		},
+		progress: func(result broadcastResult, done bool) {
+			message := h.botConfig(ctx).BroadcastProgressMessage
+			if done {
+				message = h.botConfig(ctx).BroadcastFinishedMessage
+			}
+			h.editMessage(ctx, chatID, status.MessageID, result.format(message))
+		},
+		interval: broadcastProgressInterval,
+	}
+
+	// Broadcasting takes a while, so it must not block the update loop
+	go func() {
+		defer h.broadcasting.Store(false)
+		result := b.run(ctx, targets)
+		h.log(ctx).Info("broadcast finished",
+			zap.Int("sent", result.Sent),
+			zap.Int("blocked", result.Blocked),
+			zap.Int("failed", result.Failed),
+		)
+	}()
+}
+
+// broadcastTargets returns the chats a broadcast is sent to and the number of
+// blocked chats skipped; the operator chat never receives broadcasts
+func (h *Handler) broadcastTargets(chats []storage.ChatInfo) ([]int64, int) {
+	targets := make([]int64, 0, len(chats))
+	blocked := 0
+	for _, chat := range chats {
+		switch {
+		case chat.Blocked:
+			blocked++
+		case chat.ChatID != h.operatorChatID():
+			targets = append(targets, chat.ChatID)
+		}
+	}
+	return targets, blocked
+}
+
+// editMessage replaces the text of a message sent by the bot
+func (h *Handler) editMessage(ctx context.Context, chatID int64, messageID int, text string) {
+	span := startTelegramSpan(ctx, "editMessageText", chatID)
+	_, err := h.bot.Request(tgbotapi.NewEditMessageText(chatID, messageID, text))
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Warn("failed to edit message", zap.Error(err))
+	}
+}
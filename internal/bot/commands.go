@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// maxCommandDescription is the longest command description Telegram accepts, in characters
+const maxCommandDescription = 256
+
+// commandScope is the set of chat types a command is offered in
+type commandScope uint8
+
+const (
+	scopePrivate commandScope = 1 << iota
+	scopeGroup
+
+	scopeAll = scopePrivate | scopeGroup
+)
+
+// command describes a bot command for dispatching, the Telegram command menu and /help
+type command struct {
+	name string
+	// aliases also run the command but are not listed, e.g. /lang for /language
+	aliases []string
+	// description is the default description; catalogs may translate it
+	description string
+	handler     func(h *Handler, ctx context.Context, call *commandCall)
+	// middleware runs for this command only, after the router middleware
+	middleware []commandMiddleware
+	// adminOnly commands are run and listed for administrators only
+	adminOnly bool
+	scopes    commandScope
+	// enabled reports whether the feature behind the command is configured; nil means always
+	enabled func(h *Handler) bool
+}
+
+// available reports whether the command is enabled
+func (c *command) available(h *Handler) bool {
+	return c.enabled == nil || c.enabled(h)
+}
+
+// builtinCommands returns the commands the bot implements. Forks add their
+// commands here, or register them on Handler.commands
+func builtinCommands() []*command {
+	return []*command{
+		{name: "start", description: "Start working with the bot", scopes: scopeAll, handler: (*Handler).handleStart},
+		{name: "help", description: "Show this help", scopes: scopeAll, handler: (*Handler).handleHelp},
+		{name: "export", description: "Download the conversation (md or json)", scopes: scopeAll, handler: (*Handler).handleExport},
+		{name: "import", description: "Restore a conversation from a JSON export", scopes: scopeAll,
+			handler: func(h *Handler, ctx context.Context, call *commandCall) {
+				h.handleImport(ctx, call.message, importDocument(call.message))
+			}},
+		{name: "forget", description: "Erase all data stored about you", scopes: scopeAll, handler: (*Handler).handleForget},
+		{name: "persona", description: "Choose the assistant persona", scopes: scopeAll, handler: (*Handler).handlePersona,
+			enabled: func(h *Handler) bool { return h.personaLibrary().Len() > 0 }},
+		{name: "language", aliases: []string{"lang"}, description: "Choose your language", scopes: scopeAll, handler: (*Handler).handleLanguage,
+			enabled: func(h *Handler) bool { return h.localeBundle().Len() > 0 }},
+		{name: "remind", description: "Set a reminder, e.g. /remind 2h call Bob", scopes: scopeAll, handler: (*Handler).handleRemind},
+		{name: "reminders", description: "List and cancel your reminders", scopes: scopeAll, handler: (*Handler).handleReminders},
+		{name: "timezone", description: "Set your timezone for reminders", scopes: scopeAll, handler: (*Handler).handleTimezone},
+		{name: "operator", description: "Talk to a human operator", scopes: scopePrivate, handler: (*Handler).handleOperator,
+			enabled: func(h *Handler) bool { return h.operatorChatID() != 0 }},
+		{name: "usage", description: "Token usage and cost report", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleUsage},
+		{name: "stats", description: "Bot statistics", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleStats},
+		{name: "broadcast", description: "Send an announcement to all chats", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleBroadcast},
+		{name: "schedule", description: "Manage scheduled AI posts", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleSchedule},
+		{name: "reload", description: "Reload prompts and messages", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleReload},
+		{name: "reindex", description: "Rebuild the knowledge base index", adminOnly: true, scopes: scopeAll, handler: (*Handler).handleReindex,
+			enabled: func(h *Handler) bool { return h.config.Knowledge.Dir != "" }},
+	}
+}
+
+// newCommands returns the router for the built-in commands with logging,
+// authorization, chat type restrictions and rate limiting
+func (h *Handler) newCommands() *commandRouter {
+	return newCommandRouter(
+		h.logCommands,
+		h.authorizeCommands,
+		h.restrictChatTypes,
+		h.limitCommands(newRateLimiter(commandRateWindow)),
+	).mustRegister(builtinCommands()...)
+}
+
+// handleStart greets the user with the welcome text of the chat's persona
+func (h *Handler) handleStart(ctx context.Context, call *commandCall) {
+	h.sendMessage(ctx, call.message.Chat.ID, h.startMessage(ctx, call.message.Chat.ID))
+}
+
+// handleHelp sends the help message with the commands available in the chat
+func (h *Handler) handleHelp(ctx context.Context, call *commandCall) {
+	message := call.message
+
+	var available []*command
+	for _, c := range h.menuCommands(chatScope(message.Chat), h.isAdmin(message.From.ID)) {
+		if h.commandAllowed(ctx, message.Chat.ID, message.From.ID, c.name) {
+			available = append(available, c)
+		}
+	}
+
+	lang := languageFrom(ctx)
+	var catalog *i18n.Catalog
+	if lang != nil {
+		catalog = lang.catalog
+	}
+	h.sendMessage(ctx, message.Chat.ID, helpText(h.botConfig(ctx).HelpMessage, available, catalog))
+}
+
+// helpText puts the command list in place of "%s" in the help message, or
+// appends it when the message has no placeholder
+func helpText(message string, commands []*command, catalog *i18n.Catalog) string {
+	lines := make([]string, 0, len(commands))
+	for _, c := range commands {
+		lines = append(lines, fmt.Sprintf("• /%s - %s", c.name, commandDescription(c, catalog)))
+	}
+	list := strings.Join(lines, "\n")
+
+	if strings.Contains(message, "%s") {
+		return strings.Replace(message, "%s", list, 1)
+	}
+	if list == "" {
+		return message
+	}
+	return message + "\n\n" + list
+}
+
+// commandDescription returns the translated description of a command
+func commandDescription(c *command, catalog *i18n.Catalog) string {
+	if catalog != nil {
+		if description := catalog.Commands[c.name]; description != "" {
+			return description
+		}
+	}
+	return c.description
+}
+
+// menuCommands returns the enabled commands offered in a scope, including
+// administrative ones for administrators. Commands outside BOT_ALLOWED_COMMANDS
+// are left out for everyone else
+func (h *Handler) menuCommands(scope commandScope, admin bool) []*command {
+	if h.commands == nil {
+		return nil
+	}
+
+	allowed := h.botConfig(context.Background()).AllowedCommands
+	var list []*command
+	for _, c := range h.commands.commands {
+		if c.scopes&scope != scope || (c.adminOnly && !admin) {
+			continue
+		}
+		if !c.available(h) {
+			continue
+		}
+		if !admin && len(allowed) > 0 && c.name != "start" && c.name != "help" && !slices.Contains(allowed, c.name) {
+			continue
+		}
+		list = append(list, c)
+	}
+	return list
+}
+
+// registerCommands publishes the command menu with setMyCommands: for all chats,
+// private chats, groups and the private chats of administrators, in the default
+// language and in every catalog language
+func (h *Handler) registerCommands(ctx context.Context) {
+	catalogs := []*i18n.Catalog{nil}
+	for _, catalog := range h.localeBundle().List() {
+		// Telegram only accepts two-letter ISO 639-1 codes for command menus
+		if len(catalog.Code) == 2 {
+			catalogs = append(catalogs, catalog)
+		}
+	}
+
+	for _, catalog := range catalogs {
+		code := ""
+		if catalog != nil {
+			code = catalog.Code
+		}
+
+		h.setCommands(ctx, tgbotapi.NewBotCommandScopeDefault(), code, h.menuCommands(scopeAll, false), catalog)
+		h.setCommands(ctx, tgbotapi.NewBotCommandScopeAllPrivateChats(), code, h.menuCommands(scopePrivate, false), catalog)
+		h.setCommands(ctx, tgbotapi.NewBotCommandScopeAllGroupChats(), code, h.menuCommands(scopeGroup, false), catalog)
+		for _, adminID := range h.botConfig(ctx).AdminIDs {
+			h.setCommands(ctx, tgbotapi.NewBotCommandScopeChat(adminID), code, h.menuCommands(scopePrivate, true), catalog)
+		}
+	}
+}
+
+// setCommands sets the command menu of one scope and language, or deletes it when empty
+func (h *Handler) setCommands(ctx context.Context, scope tgbotapi.BotCommandScope, languageCode string, commands []*command, catalog *i18n.Catalog) {
+	var req tgbotapi.Chattable
+	if len(commands) == 0 {
+		req = tgbotapi.NewDeleteMyCommandsWithScopeAndLanguage(scope, languageCode)
+	} else {
+		botCommands := make([]tgbotapi.BotCommand, 0, len(commands))
+		for _, c := range commands {
+			botCommands = append(botCommands, tgbotapi.BotCommand{Command: c.name, Description: commandDescription(c, catalog)})
+		}
+		req = tgbotapi.NewSetMyCommandsWithScopeAndLanguage(scope, languageCode, botCommands...)
+	}
+
+	span := startTelegramSpan(ctx, "setMyCommands", scope.ChatID)
+	_, err := h.bot.Request(req)
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Warn("failed to set bot commands",
+			zap.String("scope", scope.Type),
+			zap.Int64("chat_id", scope.ChatID),
+			zap.String("language", languageCode),
+			zap.Error(err),
+		)
+	}
+}
+
+// validateCatalogCommands checks that catalogs only describe known commands
+// with descriptions Telegram accepts
+func validateCatalogCommands(commands *commandRouter, bundle *i18n.Bundle) error {
+	for _, catalog := range bundle.List() {
+		for name, description := range catalog.Commands {
+			if c, ok := commands.lookup(name); !ok || c.name != name {
+				return fmt.Errorf("catalog %q describes unknown command %q", catalog.Code, name)
+			}
+			if description == "" || utf8.RuneCountInString(description) > maxCommandDescription {
+				return fmt.Errorf("catalog %q: description of /%s must be 1-%d characters", catalog.Code, name, maxCommandDescription)
+			}
+		}
+	}
+	return nil
+}
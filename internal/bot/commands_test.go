@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/i18n"
+)
+
+// noopCommand is the handler of test commands
+func noopCommand(h *Handler, ctx context.Context, call *commandCall) {}
+
+// testCommands returns a router with public, private, group-only, disabled and admin commands
+func testCommands() *commandRouter {
+	return newCommandRouter().mustRegister(
+		&command{name: "start", description: "Start", scopes: scopeAll, handler: noopCommand},
+		&command{name: "help", description: "Help", scopes: scopeAll, handler: noopCommand},
+		&command{name: "export", description: "Export", scopes: scopePrivate, handler: noopCommand},
+		&command{name: "summary", aliases: []string{"sum"}, description: "Summary", scopes: scopeGroup, handler: noopCommand},
+		&command{name: "persona", description: "Persona", scopes: scopeAll, handler: noopCommand,
+			enabled: func(h *Handler) bool { return false }},
+		&command{name: "stats", description: "Stats", adminOnly: true, scopes: scopeAll, handler: noopCommand},
+	)
+}
+
+// commandNames returns the names of commands in order
+func commandNames(commands []*command) string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestHandler_MenuCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []string
+		scope    commandScope
+		admin    bool
+		expected string
+	}{
+		{name: "All chats", scope: scopeAll, expected: "start,help"},
+		{name: "Private chats", scope: scopePrivate, expected: "start,help,export"},
+		{name: "Groups", scope: scopeGroup, expected: "start,help,summary"},
+		{name: "Administrators", scope: scopePrivate, admin: true, expected: "start,help,export,stats"},
+		{name: "Allowed commands", allowed: []string{"summary"}, scope: scopeGroup, expected: "start,help,summary"},
+		{name: "Allowed commands exclude others", allowed: []string{"summary"}, scope: scopePrivate, expected: "start,help"},
+		{name: "Administrators are not restricted", allowed: []string{"summary"}, scope: scopePrivate, admin: true, expected: "start,help,export,stats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{config: &config.Config{Bot: config.BotConfig{AllowedCommands: tt.allowed}}, commands: testCommands()}
+			if got := commandNames(handler.menuCommands(tt.scope, tt.admin)); got != tt.expected {
+				t.Errorf("menuCommands() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHelpText(t *testing.T) {
+	commands := testCommands().commands[:2]
+	ru := &i18n.Catalog{Code: "ru", Commands: map[string]string{"help": "Справка"}}
+
+	tests := []struct {
+		name     string
+		message  string
+		catalog  *i18n.Catalog
+		expected string
+	}{
+		{name: "Placeholder", message: "Commands:\n%s\nBye", expected: "Commands:\n• /start - Start\n• /help - Help\nBye"},
+		{name: "Appended", message: "Help", expected: "Help\n\n• /start - Start\n• /help - Help"},
+		{name: "Translated", message: "%s", catalog: ru, expected: "• /start - Start\n• /help - Справка"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := helpText(tt.message, commands, tt.catalog); got != tt.expected {
+				t.Errorf("helpText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got := helpText("Help", nil, nil); got != "Help" {
+		t.Errorf("helpText() without commands = %q, want message", got)
+	}
+}
+
+func TestValidateCatalogCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands string
+		wantErr  bool
+	}{
+		{name: "Known command", commands: "help: Справка"},
+		{name: "Unknown command", commands: "weather: Погода", wantErr: true},
+		{name: "Empty description", commands: `help: ""`, wantErr: true},
+		{name: "Long description", commands: "help: " + strings.Repeat("я", maxCommandDescription+1), wantErr: true},
+		{name: "Longest description", commands: "help: " + strings.Repeat("я", maxCommandDescription)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "ru.yaml"), []byte("commands:\n  "+tt.commands+"\n"), 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			bundle, err := i18n.Load(dir, &config.BotConfig{}, "")
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if err := validateCatalogCommands(testCommands(), bundle); (err != nil) != tt.wantErr {
+				t.Errorf("validateCatalogCommands() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShippedCatalogCommands(t *testing.T) {
+	cfg := &config.Config{Bot: config.BotConfig{LocalesDir: "../../locales"}}
+	if _, err := loadLocales(cfg); err != nil {
+		t.Fatalf("loadLocales() error = %v", err)
+	}
+}
+
+func TestBuiltinCommands(t *testing.T) {
+	if err := newCommandRouter().register(builtinCommands()...); err != nil {
+		t.Fatalf("register() error = %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// maxImportSize limits the size of transcript files accepted by /import
+const maxImportSize = 5 << 20
+
+// handleExport sends the chat's conversation as a Markdown or JSON document
+func (h *Handler) handleExport(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+
+	format := strings.ToLower(call.argText)
+	if format == "" || format == "markdown" {
+		format = "md"
+	}
+	if format != "md" && format != "json" {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ExportUsageMessage)
+		return
+	}
+
+	conv, err := h.store.GetConversation(ctx, chatID)
+	if err != nil {
+		h.log(ctx).Error("failed to load conversation for export", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	if len(conv.Messages) == 0 {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ExportEmptyMessage)
+		return
+	}
+
+	now := time.Now()
+	var data []byte
+	if format == "json" {
+		data, err = buildJSONTranscript(conv, now)
+		if err != nil {
+			h.log(ctx).Error("failed to build transcript", zap.Error(err))
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+	} else {
+		data = buildMarkdownTranscript(conv, now)
+	}
+
+	name := fmt.Sprintf("chat-%d-%s.%s", chatID, now.UTC().Format("20060102-150405"), format)
+	h.log(ctx).Info("exporting conversation",
+		zap.Int64("chat_id", chatID),
+		zap.String("format", format),
+		zap.Int("messages", len(conv.Messages)),
+	)
+	h.sendDocument(ctx, chatID, name, data)
+}
+
+// handleImport restores the chat's conversation from a JSON transcript document
+func (h *Handler) handleImport(ctx context.Context, message *tgbotapi.Message, document *tgbotapi.Document) {
+	chatID := message.Chat.ID
+
+	if document == nil {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportUsageMessage)
+		return
+	}
+	if document.FileSize > maxImportSize {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportFailedMessage)
+		return
+	}
+	// In groups only admins may replace a conversation others take part in
+	if !message.Chat.IsPrivate() && !h.isAdmin(message.From.ID) {
+		current, err := h.store.GetConversation(ctx, chatID)
+		if err != nil {
+			h.log(ctx).Error("failed to load conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+		if len(current.Messages) > 0 {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportRestrictedMessage)
+			return
+		}
+	}
+
+	data, err := h.downloadFile(ctx, document.FileID)
+	if err != nil {
+		h.log(ctx).Error("failed to download transcript", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportFailedMessage)
+		return
+	}
+
+	transcript, err := parseJSONTranscript(data)
+	if err != nil {
+		h.log(ctx).Warn("rejected transcript import", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportFailedMessage)
+		return
+	}
+
+	// Buttons under answers shown before the import do not refer to these
+	// messages, and the user messages are attributed to the importer
+	for i := range transcript.Messages {
+		transcript.Messages[i].MessageID = 0
+		transcript.Messages[i].UserID = 0
+		if transcript.Messages[i].Role == "user" {
+			transcript.Messages[i].UserID = message.From.ID
+		}
+	}
+	conv := &storage.Conversation{
+		ChatID:    chatID,
+		Messages:  transcript.Messages,
+		UpdatedAt: time.Now(),
+	}
+	if err := h.store.SaveConversation(ctx, conv); err != nil {
+		h.log(ctx).Error("failed to save imported conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	h.log(ctx).Info("imported conversation",
+		zap.Int64("chat_id", chatID),
+		zap.Int("messages", len(conv.Messages)),
+	)
+	h.sendMessage(ctx, chatID, h.botConfig(ctx).ImportSuccessMessage)
+}
+
+// importDocument returns the document targeted by an /import command: either
+// attached with /import as its caption or the message the command replies to
+func importDocument(message *tgbotapi.Message) *tgbotapi.Document {
+	if message.Document != nil {
+		return message.Document
+	}
+	if message.ReplyToMessage != nil {
+		return message.ReplyToMessage.Document
+	}
+	return nil
+}
+
+// handleImportCaption runs a document sent with an /import caption through the
+// command router, so the command middleware applies as to a typed /import
+func (h *Handler) handleImportCaption(ctx context.Context, message *tgbotapi.Message) {
+	caption := strings.TrimSpace(message.Caption)
+	command := strings.Fields(caption)[0]
+	if _, mention, ok := strings.Cut(command, "@"); ok && h.bot != nil && !strings.EqualFold(mention, h.bot.Self.UserName) {
+		return
+	}
+
+	c, ok := h.commands.lookup("import")
+	if !ok || !c.available(h) {
+		h.sendMessage(ctx, message.Chat.ID, h.botConfig(ctx).UnknownCommandMessage)
+		return
+	}
+	argText := strings.TrimSpace(strings.TrimPrefix(caption, command))
+	h.commands.dispatch(ctx, h, &commandCall{
+		message: message,
+		command: c,
+		name:    "import",
+		argText: argText,
+		args:    parseArgs(argText),
+	})
+}
+
+// isImportCaption reports whether a document was sent with an /import caption
+func isImportCaption(message *tgbotapi.Message) bool {
+	if message.Document == nil {
+		return false
+	}
+	fields := strings.Fields(message.Caption)
+	if len(fields) == 0 {
+		return false
+	}
+	command, _, _ := strings.Cut(fields[0], "@")
+	return command == "/import"
+}
+
+// downloadFile fetches a file uploaded to Telegram
+func (h *Handler) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	fileURL, err := h.bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file URL: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("file download returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxImportSize)
+	}
+	return data, nil
+}
+
+// sendDocument sends an in-memory file to the specified chat
+func (h *Handler) sendDocument(ctx context.Context, chatID int64, name string, data []byte) {
+	span := startTelegramSpan(ctx, "sendDocument", chatID)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	_, err := h.bot.Send(doc)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.TelegramSendErrors.WithLabelValues("sendDocument").Inc()
+		h.log(ctx).Error("failed to send document", zap.Error(err))
+	}
+}
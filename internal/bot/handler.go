@@ -2,12 +2,26 @@ package bot
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"tgbot-skeleton/internal/ai"
 	"tgbot-skeleton/internal/config"
-	"tgbot-skeleton/internal/utils"
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/knowledge"
+	"tgbot-skeleton/internal/logger"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/persona"
+	"tgbot-skeleton/internal/redact"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -16,57 +30,178 @@ type Handler struct {
 	bot       *tgbotapi.BotAPI
 	logger    *zap.Logger
 	aiService *ai.Service
+	store     storage.Store
 	config    *config.Config
+
+	// personas, botSettings and locales are replaced when prompts and messages are reloaded
+	personas    atomic.Pointer[persona.Library]
+	botSettings atomic.Pointer[config.BotConfig]
+	locales     atomic.Pointer[i18n.Bundle]
+	// commands are the bot commands for dispatching, the menu and /help
+	commands *commandRouter
+	// middleware wraps update processing in pipeline, outermost first
+	middleware []UpdateMiddleware
+	pipeline   UpdateHandler
+	// reloadMu serializes reloads
+	reloadMu sync.Mutex
+
+	// broadcasting is set while a /broadcast is in progress
+	broadcasting atomic.Bool
+
+	// inlineDebouncer, inlineCache and inlineLimiter shape the AI requests of inline queries
+	inlineDebouncer *inlineDebouncer
+	inlineCache     *inlineCache
+	inlineLimiter   *rateLimiter
+
+	// knowledge is the knowledge base index, replaced by /reindex; nil when not configured
+	knowledge atomic.Pointer[knowledge.Index]
+	// embedder ranks knowledge by meaning, nil when no embeddings model is configured
+	embedder knowledge.Embedder
+	// reindexMu serializes rebuilds of the knowledge base index
+	reindexMu sync.Mutex
+
+	// cron runs the jobs of config.yaml and /schedule; scheduleEntries are their
+	// cron entries and scheduleCtx is cancelled on shutdown
+	cron            *cron.Cron
+	scheduleMu      sync.Mutex
+	scheduleEntries []cron.EntryID
+	scheduleCtx     context.Context
 }
 
 // NewHandler creates a new handler
-func NewHandler(bot *tgbotapi.BotAPI, logger *zap.Logger, aiService *ai.Service, config *config.Config) *Handler {
-	return &Handler{
+func NewHandler(bot *tgbotapi.BotAPI, logger *zap.Logger, aiService *ai.Service, store storage.Store, personas *persona.Library, locales *i18n.Bundle, index *knowledge.Index, config *config.Config) *Handler {
+	h := &Handler{
 		bot:       bot,
 		logger:    logger,
 		aiService: aiService,
+		store:     store,
 		config:    config,
+
+		inlineDebouncer: newInlineDebouncer(),
+		inlineCache:     newInlineCache(inlineCacheCapacity),
+		inlineLimiter:   newRateLimiter(inlineRateWindow),
+		embedder:        newEmbedder(config),
+		cron:            newScheduleCron(logger),
 	}
+	h.commands = h.newCommands()
+	h.Use(h.defaultMiddleware()...)
+	h.personas.Store(personas)
+	h.botSettings.Store(&config.Bot)
+	h.locales.Store(locales)
+	h.knowledge.Store(index)
+	return h
+}
+
+// personaLibrary returns the current personas, nil when they are not configured
+func (h *Handler) personaLibrary() *persona.Library {
+	return h.personas.Load()
 }
 
-// HandleUpdate handles incoming Telegram updates
+// HandleUpdate handles incoming Telegram updates through the middleware pipeline
 func (h *Handler) HandleUpdate(ctx context.Context, update tgbotapi.Update) {
+	if h.pipeline == nil {
+		h.routeUpdate(ctx, update)
+		return
+	}
+	h.pipeline(ctx, update)
+}
+
+// routeUpdate passes an update that went through the middleware to the
+// callback, command, import or message handler
+func (h *Handler) routeUpdate(ctx context.Context, update tgbotapi.Update) {
+	ctx, span := tracing.Tracer().Start(ctx, "telegram.HandleUpdate", trace.WithAttributes(
+		attribute.Int("telegram.update_id", update.UpdateID),
+		attribute.String("telegram.update_type", updateType(update)),
+		attribute.String("correlation_id", correlationIDFrom(ctx)),
+	))
+	defer span.End()
+
+	if update.CallbackQuery != nil {
+		h.handleCallback(ctx, update.CallbackQuery)
+		return
+	}
+
+	if update.InlineQuery != nil {
+		h.handleInlineQuery(ctx, update.InlineQuery)
+		return
+	}
+
 	if update.Message == nil {
 		return
 	}
 
 	message := update.Message
-	h.logger.Info("received message",
+	span.SetAttributes(
+		attribute.Int64("telegram.chat_id", message.Chat.ID),
+		attribute.Int64("telegram.user_id", message.From.ID),
+	)
+	h.log(ctx).Info("received message",
 		zap.Int64("chat_id", message.Chat.ID),
-		zap.String("text", message.Text),
+		h.contentField("text", message.Text),
 		zap.String("username", message.From.UserName),
 	)
 
+	settings := h.rememberUser(ctx, message.From)
+	ctx = h.withLanguage(ctx, message.From, settings)
+	h.trackChat(ctx, message.Chat)
+
+	// Messages in the operator chat answer tickets rather than ask the AI
+	if operatorChat := h.operatorChatID(); operatorChat != 0 && message.Chat.ID == operatorChat {
+		h.handleOperatorMessage(ctx, message)
+		return
+	}
+
 	// Handle commands
 	if message.IsCommand() {
 		h.handleCommand(ctx, message)
 		return
 	}
 
+	// Handle transcript files sent with an /import caption
+	if isImportCaption(message) {
+		h.handleImportCaption(ctx, message)
+		return
+	}
+
+	// AI answers are paused while an operator handles the chat's ticket
+	if h.operatorChatID() != 0 {
+		if ticket := h.chatTicket(ctx, message.Chat.ID); ticket != nil {
+			h.relayToOperators(ctx, ticket, message)
+			return
+		}
+	}
+
 	// Handle regular messages
 	h.handleMessage(ctx, message)
 }
 
-// handleCommand handles bot commands
-func (h *Handler) handleCommand(ctx context.Context, message *tgbotapi.Message) {
-	command := message.Command()
-	chatID := message.Chat.ID
+// handleCallback handles presses of inline keyboard buttons
+func (h *Handler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	h.log(ctx).Info("received callback query",
+		zap.Int64("user_id", query.From.ID),
+		zap.String("data", query.Data),
+	)
 
-	h.logger.Info("handling command", zap.String("command", command))
+	ctx = h.withLanguage(ctx, query.From, h.userSettings(ctx, query.From.ID))
 
-	switch command {
-	case "start":
-		h.sendMessage(chatID, h.config.Bot.StartMessage)
-	case "help":
-		h.sendMessage(chatID, h.config.Bot.HelpMessage)
-	default:
-		h.sendMessage(chatID, h.config.Bot.UnknownCommandMessage)
+	if name, ok := strings.CutPrefix(query.Data, personaCallbackPrefix); ok {
+		h.handlePersonaCallback(ctx, query, name)
+		return
+	}
+	if code, ok := strings.CutPrefix(query.Data, languageCallbackPrefix); ok {
+		h.handleLanguageCallback(ctx, query, code)
+		return
 	}
+	if action, ok := strings.CutPrefix(query.Data, answerCallbackPrefix); ok {
+		h.handleAnswerCallback(ctx, query, action)
+		return
+	}
+	if id, ok := strings.CutPrefix(query.Data, reminderCallbackPrefix); ok {
+		h.handleReminderCallback(ctx, query, id)
+		return
+	}
+
+	h.answerCallback(ctx, query.ID, "")
 }
 
 // handleMessage handles regular text messages
@@ -75,45 +210,186 @@ func (h *Handler) handleMessage(ctx context.Context, message *tgbotapi.Message)
 	text := message.Text
 
 	if text == "" {
-		h.sendMessage(chatID, h.config.Bot.EmptyMessage)
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).EmptyMessage)
 		return
 	}
 
-	h.logger.Info("processing user message",
+	h.log(ctx).Info("processing user message",
 		zap.Int64("chat_id", chatID),
-		zap.String("text", text),
+		h.contentField("text", text),
 	)
 
+	// Messages like "remind me tomorrow at 9 to call Bob" set reminders
+	if spec, ok := cutReminderTrigger(text, h.botConfig(ctx).ReminderTriggers); ok {
+		h.setReminder(ctx, message, text, spec)
+		return
+	}
+
+	userID := message.From.ID
+	period := time.Now().UTC().Format("2006-01-02")
+
+	// Enforce daily message limit
+	if h.quotaExceeded(ctx, userID, period) {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).QuotaExceededMessage)
+		return
+	}
+
 	// Send typing indicator
-	h.sendTyping(chatID)
+	h.sendTyping(ctx, chatID)
 
-	// Get AI response
-	response, err := h.aiService.GenerateResponse(ctx, text)
+	// Get AI response with conversation history
+	history := h.loadHistory(ctx, chatID)
+	request := h.newRequest(ctx, message, history, text)
+	h.addKnowledge(ctx, &request, text)
+	completion, err := h.aiService.Complete(ctx, request)
 	if err != nil {
-		h.logger.Error("failed to get AI response", zap.Error(err))
-		h.sendMessage(chatID, h.config.Bot.ErrorMessage)
+		h.log(ctx).Error("failed to get AI response", zap.Error(err))
+		h.incrementCounter(ctx, counterAIErrors)
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
 		return
 	}
 
-	// Convert Markdown to Telegram format and send AI response
-	telegramResponse := utils.ConvertMarkdownToTelegram(response)
-	h.sendMessage(chatID, telegramResponse)
+	// The model asks for a human operator with the handoff marker
+	content, escalate := cutHandoffMarker(completion.Content, h.botConfig(ctx).HandoffMarker)
+	escalate = escalate && h.operatorChatID() != 0 && message.Chat.IsPrivate()
+
+	// Send the AI response first, so the conversation remembers the message showing it
+	var sent tgbotapi.Message
+	if content != "" {
+		display := content
+		if h.config.AI.ShowToolCalls {
+			display = withToolCalls(content, completion.ToolCalls)
+		}
+		if sent, err = h.deliverAnswer(ctx, chatID, display); err != nil {
+			h.log(ctx).Error("failed to send message", zap.Error(err))
+		}
+	}
+	h.saveExchange(ctx, chatID, userID, sent.MessageID, text, content, completion.Model)
+	h.accountCompletion(ctx, chatID, userID, period, completion)
+
+	if escalate && h.chatTicket(ctx, chatID) == nil {
+		h.handOff(ctx, message.Chat, message.From, ticketReasonEscalation, "")
+	}
+}
+
+// newRequest builds the AI request for a message with the chat's persona and
+// the user's language
+func (h *Handler) newRequest(ctx context.Context, message *tgbotapi.Message, history []ai.Message, text string) ai.Request {
+	request := ai.Request{History: history, Message: text, PromptData: h.promptData(ctx, message)}
+	if lang := languageFrom(ctx); h.config.AI.AnswerInUserLanguage && lang != nil && lang.code != "" {
+		request.Instructions = answerLanguageInstruction(lang)
+	}
+	if p := h.chatPersona(ctx, message.Chat.ID); p != nil {
+		applyPersona(&request, p)
+	}
+	return request
+}
+
+// accountCompletion counts an AI answer towards the user's quota, usage and statistics
+func (h *Handler) accountCompletion(ctx context.Context, chatID, userID int64, period string, completion *ai.Completion) {
+	if _, err := h.store.IncrementQuota(ctx, userID, period, 1, completion.Usage.TotalTokens); err != nil {
+		h.log(ctx).Warn("failed to update quota", zap.Error(err))
+	}
+	h.recordUsage(ctx, chatID, userID, period, completion)
+	h.incrementCounter(ctx, counterMessages)
+}
+
+// sendMessage sends a message to the specified chat, logging failures
+func (h *Handler) sendMessage(ctx context.Context, chatID int64, text string) {
+	if _, err := h.deliverMessage(ctx, chatID, text); err != nil {
+		h.log(ctx).Error("failed to send message", zap.Error(err))
+	}
+}
+
+// deliverMessage sends a message to the specified chat, falling back to plain
+// text if Telegram cannot parse its Markdown
+func (h *Handler) deliverMessage(ctx context.Context, chatID int64, text string) (tgbotapi.Message, error) {
+	return h.deliver(ctx, tgbotapi.NewMessage(chatID, text))
 }
 
-// sendMessage sends a message to the specified chat
-func (h *Handler) sendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
+// deliver sends a message as Markdown, resending it as plain text if Telegram
+// cannot parse its Markdown
+func (h *Handler) deliver(ctx context.Context, msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	span := startTelegramSpan(ctx, "sendMessage", msg.ChatID)
 	msg.ParseMode = tgbotapi.ModeMarkdown
 
-	if _, err := h.bot.Send(msg); err != nil {
-		h.logger.Error("failed to send message", zap.Error(err))
+	sent, err := h.bot.Send(msg)
+	if err != nil && isMarkdownError(err) {
+		h.log(ctx).Warn("telegram rejected markdown, resending as plain text", zap.Error(err))
+		metrics.MarkdownFallbacks.Inc()
+		span.AddEvent("markdown_fallback")
+		msg.ParseMode = ""
+		sent, err = h.bot.Send(msg)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		metrics.TelegramSendErrors.WithLabelValues("sendMessage").Inc()
+	}
+	return sent, err
+}
+
+// contentField returns a log field with redacted message content, or only its
+// length when content logging is disabled
+func (h *Handler) contentField(key, text string) zap.Field {
+	if !h.config.Logging.LogMessageContent {
+		return zap.Int(key+"_length", len(text))
 	}
+	return zap.String(key, redact.Redact(text))
 }
 
 // sendTyping sends a typing indicator to the specified chat
-func (h *Handler) sendTyping(chatID int64) {
+func (h *Handler) sendTyping(ctx context.Context, chatID int64) {
+	span := startTelegramSpan(ctx, "sendChatAction", chatID)
 	action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
-	if _, err := h.bot.Request(action); err != nil {
-		h.logger.Error("failed to send typing indicator", zap.Error(err))
+	_, err := h.bot.Request(action)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.TelegramSendErrors.WithLabelValues("sendChatAction").Inc()
+		h.log(ctx).Error("failed to send typing indicator", zap.Error(err))
+	}
+}
+
+// startTelegramSpan starts a client span for a Telegram Bot API call
+func startTelegramSpan(ctx context.Context, method string, chatID int64) trace.Span {
+	_, span := tracing.Tracer().Start(ctx, "telegram."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int64("telegram.chat_id", chatID)),
+	)
+	return span
+}
+
+// isMarkdownError reports whether Telegram rejected a message because of its formatting
+func isMarkdownError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities")
+}
+
+// updateType returns a metrics label describing the kind of update
+func updateType(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.EditedMessage != nil:
+		return "edited_message"
+	case update.ChannelPost != nil:
+		return "channel_post"
+	case update.EditedChannelPost != nil:
+		return "edited_channel_post"
+	case update.CallbackQuery != nil:
+		return "callback_query"
+	case update.InlineQuery != nil:
+		return "inline_query"
+	case update.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case update.MyChatMember != nil:
+		return "my_chat_member"
+	case update.ChatMember != nil:
+		return "chat_member"
+	default:
+		return "other"
 	}
 }
+
+// log returns the logger of the current update, which carries its correlation ID
+func (h *Handler) log(ctx context.Context) *zap.Logger {
+	return logger.FromContext(ctx, h.logger)
+}
@@ -0,0 +1,377 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Reasons a ticket was opened
+const (
+	ticketReasonCommand    = "command"
+	ticketReasonEscalation = "escalation"
+)
+
+// operatorModel is the model recorded for operator replies in the conversation
+const operatorModel = "operator"
+
+// closeCommand closes the ticket it is sent in; it only exists in the operator chat
+const closeCommand = "close"
+
+// Limits of ticket topics and of messages quoted in ticket headers
+const (
+	maxTopicName     = 128
+	maxQuotedMessage = 500
+)
+
+// operatorChatID returns the chat conversations are handed off to, 0 when handoff is disabled
+func (h *Handler) operatorChatID() int64 {
+	// Settings are the same in every language, so no user context is needed
+	return h.botConfig(context.Background()).OperatorChatID
+}
+
+// cutHandoffMarker removes the handoff marker from an AI answer and reports
+// whether it was there
+func cutHandoffMarker(content, marker string) (string, bool) {
+	if marker == "" || !strings.Contains(content, marker) {
+		return content, false
+	}
+	return strings.TrimSpace(strings.ReplaceAll(content, marker, "")), true
+}
+
+// chatTicket returns the open ticket of a chat, or nil
+func (h *Handler) chatTicket(ctx context.Context, chatID int64) *storage.Ticket {
+	ticket, err := h.store.OpenTicket(ctx, chatID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.log(ctx).Warn("failed to load ticket", zap.Int64("chat_id", chatID), zap.Error(err))
+		}
+		return nil
+	}
+	return ticket
+}
+
+// operatorTicket returns the open ticket an operator message replies to, or
+// nil. Messages in a forum topic reply to the message that created the topic
+func (h *Handler) operatorTicket(ctx context.Context, message *tgbotapi.Message) *storage.Ticket {
+	if message.ReplyToMessage == nil {
+		return nil
+	}
+	replyTo := message.ReplyToMessage.MessageID
+	return h.findOpenTicket(ctx, func(t *storage.Ticket) bool { return t.HasMessage(replyTo) })
+}
+
+// findOpenTicket returns the first open ticket matching match, or nil. Only
+// open tickets are read, so closed ones kept for retention cost nothing
+func (h *Handler) findOpenTicket(ctx context.Context, match func(t *storage.Ticket) bool) *storage.Ticket {
+	tickets, err := h.store.ListTickets(ctx, storage.TicketOpen)
+	if err != nil {
+		h.log(ctx).Warn("failed to list tickets", zap.Error(err))
+		return nil
+	}
+	for i := range tickets {
+		if match(&tickets[i]) {
+			return &tickets[i]
+		}
+	}
+	return nil
+}
+
+// handleOperator hands the conversation off to a human operator; text after
+// the command is passed on to the operators
+func (h *Handler) handleOperator(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+	if h.chatTicket(ctx, chatID) != nil {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).OperatorWaitingMessage)
+		return
+	}
+	h.handOff(ctx, call.message.Chat, call.message.From, ticketReasonCommand, call.argText)
+}
+
+// handOff opens a ticket for a chat and tells the user an operator will answer
+func (h *Handler) handOff(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User, reason, note string) {
+	ticket, err := h.openTicket(ctx, chat, user, reason, note)
+	if err != nil {
+		h.log(ctx).Error("failed to open ticket", zap.Int64("chat_id", chat.ID), zap.Error(err))
+		h.sendMessage(ctx, chat.ID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	h.log(ctx).Info("opened ticket",
+		zap.Int64("ticket_id", ticket.ID),
+		zap.Int64("chat_id", chat.ID),
+		zap.String("reason", reason),
+	)
+	h.sendMessage(ctx, chat.ID, h.botConfig(ctx).OperatorConnectedMessage)
+}
+
+// openTicket records a ticket and starts its thread in the operator chat: a
+// forum topic when the chat has topics, otherwise a header message operators
+// reply to. The header shows the user and the recent conversation
+func (h *Handler) openTicket(ctx context.Context, chat *tgbotapi.Chat, user *tgbotapi.User, reason, note string) (*storage.Ticket, error) {
+	now := time.Now()
+	ticket := &storage.Ticket{
+		ChatID:    chat.ID,
+		UserID:    user.ID,
+		Status:    storage.TicketOpen,
+		Reason:    reason,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	// Saving first numbers the ticket for its topic and header
+	if err := h.store.SaveTicket(ctx, ticket); err != nil {
+		return nil, fmt.Errorf("failed to save ticket: %w", err)
+	}
+
+	operatorChat := h.operatorChatID()
+	params := tgbotapi.Params{"name": truncate(fmt.Sprintf("#%d %s", ticket.ID, userLabel(user)), maxTopicName)}
+	params.AddNonZero64("chat_id", operatorChat)
+	var topic struct {
+		MessageThreadID int `json:"message_thread_id"`
+	}
+	if err := h.callAPI(ctx, "createForumTopic", operatorChat, params, &topic); err == nil {
+		ticket.ThreadID, ticket.Forum = topic.MessageThreadID, true
+	} else {
+		h.log(ctx).Debug("operator chat has no topics, using a reply thread", zap.Error(err))
+	}
+
+	var history []storage.ConversationMessage
+	if conv, err := h.store.GetConversation(ctx, chat.ID); err != nil {
+		h.log(ctx).Warn("failed to load conversation", zap.Int64("chat_id", chat.ID), zap.Error(err))
+	} else {
+		history = conv.Tail(h.botConfig(ctx).HandoffHistory)
+	}
+
+	header, err := h.sendToTicket(ctx, ticket, ticketHeader(ticket, user, note, history, h.botConfig(ctx).TicketUsageMessage))
+	if err != nil {
+		ticket.Status, ticket.ClosedAt = storage.TicketClosed, time.Now()
+		if err := h.store.SaveTicket(ctx, ticket); err != nil {
+			h.log(ctx).Warn("failed to close ticket", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+		}
+		return nil, fmt.Errorf("failed to notify operators: %w", err)
+	}
+	if ticket.Forum {
+		ticket.MessageIDs = append(ticket.MessageIDs, header)
+	} else {
+		ticket.ThreadID = header
+	}
+	if err := h.store.SaveTicket(ctx, ticket); err != nil {
+		return nil, fmt.Errorf("failed to save ticket: %w", err)
+	}
+	return ticket, nil
+}
+
+// ticketHeader describes a new ticket to operators; the oldest messages of the
+// conversation are left out when it does not fit into one message; usage ends the header
+func ticketHeader(ticket *storage.Ticket, user *tgbotapi.User, note string, history []storage.ConversationMessage, usage string) string {
+	var head strings.Builder
+	fmt.Fprintf(&head, "🎫 Ticket #%d", ticket.ID)
+	if ticket.Reason == ticketReasonEscalation {
+		head.WriteString(" (escalated by the AI)")
+	}
+	fmt.Fprintf(&head, "\n👤 %s, ID %d\n", userLabel(user), user.ID)
+	if note != "" {
+		fmt.Fprintf(&head, "💬 %s\n", truncate(note, maxQuotedMessage))
+	}
+
+	lines := make([]string, 0, len(history))
+	for _, msg := range history {
+		speaker := "User"
+		if msg.Role == "assistant" {
+			speaker = "Bot"
+		}
+		lines = append(lines, speaker+": "+truncate(msg.Content, maxQuotedMessage))
+	}
+
+	for {
+		text := head.String()
+		if len(lines) > 0 {
+			text += "\nRecent conversation:\n" + strings.Join(lines, "\n") + "\n"
+		}
+		text += "\n" + usage
+		if len(lines) == 0 || utf8.RuneCountInString(text) <= maxMessageLength {
+			return text
+		}
+		lines = lines[1:]
+	}
+}
+
+// userLabel names a user for operators, e.g. "Jane Doe (@jane)"
+func userLabel(user *tgbotapi.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = fmt.Sprintf("User %d", user.ID)
+	}
+	if user.UserName != "" {
+		name += " (@" + user.UserName + ")"
+	}
+	return name
+}
+
+// relayToOperators copies a user's message into their ticket's thread while
+// AI answers are paused, and keeps its text in the conversation
+func (h *Handler) relayToOperators(ctx context.Context, ticket *storage.Ticket, message *tgbotapi.Message) {
+	params := h.ticketParams(ticket)
+	params.AddNonZero64("from_chat_id", message.Chat.ID)
+	params.AddNonZero("message_id", message.MessageID)
+	var copied tgbotapi.MessageID
+	if err := h.callAPI(ctx, "copyMessage", h.operatorChatID(), params, &copied); err != nil {
+		h.log(ctx).Error("failed to relay message to operators", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+		h.sendMessage(ctx, message.Chat.ID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	ticket.MessageIDs = append(ticket.MessageIDs, copied.MessageID)
+	h.saveTicket(ctx, ticket)
+	if text := messageText(message); text != "" {
+		h.appendMessage(ctx, message.Chat.ID, storage.ConversationMessage{Role: "user", Content: text, UserID: message.From.ID, Timestamp: time.Now()})
+	}
+}
+
+// handleOperatorMessage handles messages in the operator chat: replies in a
+// ticket's thread are relayed to its user, /close closes the ticket, other
+// commands work as usual and anything else is the operators' own discussion
+func (h *Handler) handleOperatorMessage(ctx context.Context, message *tgbotapi.Message) {
+	if message.IsCommand() {
+		if strings.EqualFold(message.Command(), closeCommand) && h.addressedToBot(message) {
+			h.closeTicket(ctx, message)
+			return
+		}
+		h.handleCommand(ctx, message)
+		return
+	}
+
+	ticket := h.operatorTicket(ctx, message)
+	if ticket == nil {
+		return
+	}
+
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", ticket.ChatID)
+	params.AddNonZero64("from_chat_id", message.Chat.ID)
+	params.AddNonZero("message_id", message.MessageID)
+	if err := h.callAPI(ctx, "copyMessage", ticket.ChatID, params, nil); err != nil {
+		h.log(ctx).Error("failed to relay operator reply", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+		_, _ = h.sendToTicket(ctx, ticket, h.botConfig(ctx).TicketUndeliveredMessage)
+		return
+	}
+
+	// Replies to the operator's own message also belong to the ticket
+	ticket.MessageIDs = append(ticket.MessageIDs, message.MessageID)
+	h.saveTicket(ctx, ticket)
+	if text := messageText(message); text != "" {
+		h.appendMessage(ctx, ticket.ChatID, storage.ConversationMessage{Role: "assistant", Content: text, Model: operatorModel, Timestamp: time.Now()})
+	}
+}
+
+// closeTicket closes the ticket /close was sent in and gives the chat back to the AI
+func (h *Handler) closeTicket(ctx context.Context, message *tgbotapi.Message) {
+	ticket := h.operatorTicket(ctx, message)
+	if ticket == nil {
+		h.sendMessage(ctx, message.Chat.ID, h.botConfig(ctx).TicketCloseUsageMessage)
+		return
+	}
+
+	now := time.Now()
+	ticket.Status, ticket.ClosedAt, ticket.UpdatedAt, ticket.ClosedBy = storage.TicketClosed, now, now, message.From.ID
+	if err := h.store.SaveTicket(ctx, ticket); err != nil {
+		h.log(ctx).Error("failed to close ticket", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+		h.sendMessage(ctx, message.Chat.ID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	h.log(ctx).Info("closed ticket", zap.Int64("ticket_id", ticket.ID), zap.Int64("operator_id", message.From.ID))
+
+	_, _ = h.sendToTicket(ctx, ticket, fmt.Sprintf(h.botConfig(ctx).TicketClosedMessage, ticket.ID, userLabel(message.From)))
+	if ticket.Forum {
+		params := tgbotapi.Params{}
+		params.AddNonZero64("chat_id", message.Chat.ID)
+		params.AddNonZero("message_thread_id", ticket.ThreadID)
+		if err := h.callAPI(ctx, "closeForumTopic", message.Chat.ID, params, nil); err != nil {
+			h.log(ctx).Warn("failed to close ticket topic", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+		}
+	}
+
+	userCtx := h.userContext(ctx, ticket.UserID)
+	h.sendMessage(userCtx, ticket.ChatID, h.botConfig(userCtx).OperatorClosedMessage)
+}
+
+// userContext returns ctx with the language of a user other than the sender of
+// the current update, e.g. the user of a ticket an operator closes
+func (h *Handler) userContext(ctx context.Context, userID int64) context.Context {
+	settings := h.userSettings(ctx, userID)
+	user := &tgbotapi.User{ID: userID}
+	if settings != nil {
+		user.LanguageCode = settings.LanguageCode
+	}
+	return h.withLanguage(ctx, user, settings)
+}
+
+// ticketParams returns the parameters addressing a ticket's thread in the operator chat
+func (h *Handler) ticketParams(ticket *storage.Ticket) tgbotapi.Params {
+	params := tgbotapi.Params{}
+	params.AddNonZero64("chat_id", h.operatorChatID())
+	if ticket.Forum {
+		params.AddNonZero("message_thread_id", ticket.ThreadID)
+	} else {
+		params.AddNonZero("reply_to_message_id", ticket.ThreadID)
+	}
+	return params
+}
+
+// sendToTicket posts plain text to a ticket's thread and returns the message ID
+func (h *Handler) sendToTicket(ctx context.Context, ticket *storage.Ticket, text string) (int, error) {
+	params := h.ticketParams(ticket)
+	params["text"] = text
+	var sent tgbotapi.Message
+	if err := h.callAPI(ctx, "sendMessage", h.operatorChatID(), params, &sent); err != nil {
+		return 0, err
+	}
+	return sent.MessageID, nil
+}
+
+// saveTicket stores a changed ticket, logging failures
+func (h *Handler) saveTicket(ctx context.Context, ticket *storage.Ticket) {
+	ticket.UpdatedAt = time.Now()
+	if err := h.store.SaveTicket(ctx, ticket); err != nil {
+		h.log(ctx).Warn("failed to save ticket", zap.Int64("ticket_id", ticket.ID), zap.Error(err))
+	}
+}
+
+// appendMessage adds a message to a chat's conversation, logging failures
+func (h *Handler) appendMessage(ctx context.Context, chatID int64, message storage.ConversationMessage) {
+	if err := h.store.AppendMessages(ctx, chatID, message); err != nil {
+		h.log(ctx).Warn("failed to save conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+	}
+}
+
+// messageText returns the text or caption of a message
+func messageText(message *tgbotapi.Message) string {
+	if message.Text != "" {
+		return message.Text
+	}
+	return message.Caption
+}
+
+// callAPI makes a Bot API request tgbotapi has no config for, e.g. for forum
+// topics, and decodes its result into v unless v is nil
+func (h *Handler) callAPI(ctx context.Context, method string, chatID int64, params tgbotapi.Params, v any) error {
+	span := startTelegramSpan(ctx, method, chatID)
+	resp, err := h.bot.MakeRequest(method, params)
+	if err == nil && v != nil {
+		err = json.Unmarshal(resp.Result, v)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	return nil
+}
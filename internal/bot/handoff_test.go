@@ -0,0 +1,321 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func TestCutHandoffMarker(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		marker     string
+		expected   string
+		wantMarker bool
+	}{
+		{name: "No marker", content: "All done.", marker: "[OPERATOR]", expected: "All done."},
+		{name: "Marker at the end", content: "Let me pass you to a colleague. [OPERATOR]", marker: "[OPERATOR]", expected: "Let me pass you to a colleague.", wantMarker: true},
+		{name: "Marker only", content: "[OPERATOR]", marker: "[OPERATOR]", expected: "", wantMarker: true},
+		{name: "Handoff disabled", content: "Bye [OPERATOR]", marker: "", expected: "Bye [OPERATOR]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := cutHandoffMarker(tt.content, tt.marker)
+			if got != tt.expected || found != tt.wantMarker {
+				t.Errorf("cutHandoffMarker() = %q, %v, want %q, %v", got, found, tt.expected, tt.wantMarker)
+			}
+		})
+	}
+}
+
+func TestUserLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     tgbotapi.User
+		expected string
+	}{
+		{name: "Full name and username", user: tgbotapi.User{ID: 1, FirstName: "Jane", LastName: "Doe", UserName: "jane"}, expected: "Jane Doe (@jane)"},
+		{name: "First name", user: tgbotapi.User{ID: 1, FirstName: "Jane"}, expected: "Jane"},
+		{name: "No name", user: tgbotapi.User{ID: 7}, expected: "User 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userLabel(&tt.user); got != tt.expected {
+				t.Errorf("userLabel() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTicketHeader(t *testing.T) {
+	user := &tgbotapi.User{ID: 42, FirstName: "Jane"}
+	usage := "/close closes the ticket."
+	long := strings.Repeat("x", maxQuotedMessage)
+	var many []storage.ConversationMessage
+	for i := 0; i < 20; i++ {
+		many = append(many, storage.ConversationMessage{Role: "user", Content: long})
+	}
+
+	tests := []struct {
+		name     string
+		ticket   storage.Ticket
+		note     string
+		history  []storage.ConversationMessage
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Command without history",
+			ticket:   storage.Ticket{ID: 3, Reason: ticketReasonCommand},
+			note:     "my order is late",
+			contains: []string{"Ticket #3\n", "Jane, ID 42", "💬 my order is late", usage},
+			excludes: []string{"escalated", "Recent conversation"},
+		},
+		{
+			name:   "Escalation with history",
+			ticket: storage.Ticket{ID: 4, Reason: ticketReasonEscalation},
+			history: []storage.ConversationMessage{
+				{Role: "user", Content: "Where is my refund?"},
+				{Role: "assistant", Content: "Let me pass you to a colleague."},
+			},
+			contains: []string{"(escalated by the AI)", "User: Where is my refund?\nBot: Let me pass you to a colleague.", usage},
+			excludes: []string{"💬"},
+		},
+		{
+			name:     "History too long for one message",
+			ticket:   storage.Ticket{ID: 5, Reason: ticketReasonCommand},
+			history:  many,
+			contains: []string{"Recent conversation", usage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ticketHeader(&tt.ticket, user, tt.note, tt.history, usage)
+			if n := utf8.RuneCountInString(got); n > maxMessageLength {
+				t.Errorf("ticketHeader() has %d characters, want at most %d", n, maxMessageLength)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("ticketHeader() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("ticketHeader() = %q, want it not to contain %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
+
+// apiCall is a Bot API request received by fakeTelegram
+type apiCall struct {
+	method string
+	params map[string]string
+}
+
+// fakeTelegram is a Bot API server answering forum topic, send and copy
+// requests. Requests to chats in blocked fail as if the user blocked the bot
+type fakeTelegram struct {
+	mu      sync.Mutex
+	calls   []apiCall
+	blocked map[string]bool
+}
+
+// newFakeTelegram starts a fake Bot API server and returns a client for it
+func newFakeTelegram(t *testing.T) (*fakeTelegram, *tgbotapi.BotAPI) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		method := path.Base(r.URL.Path)
+		call := apiCall{method: method, params: make(map[string]string)}
+		for key := range r.PostForm {
+			call.params[key] = r.PostForm.Get(key)
+		}
+		fake.mu.Lock()
+		fake.calls = append(fake.calls, call)
+		blocked := fake.blocked[call.params["chat_id"]]
+		fake.mu.Unlock()
+		if blocked {
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+			return
+		}
+
+		result := `true`
+		switch method {
+		case "getMe":
+			result = `{"id":1,"is_bot":true,"username":"MyBot"}`
+		case "createForumTopic":
+			result = `{"message_thread_id":50,"name":"ticket"}`
+		case "sendMessage":
+			result = `{"message_id":51,"date":0,"chat":{"id":` + call.params["chat_id"] + `,"type":"supergroup"}}`
+		case "copyMessage":
+			result = `{"message_id":52}`
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("test", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPIWithAPIEndpoint() error = %v", err)
+	}
+	return fake, api
+}
+
+// take returns the requests received since the last call, except getMe
+func (f *fakeTelegram) take() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []apiCall
+	for _, call := range f.calls {
+		if call.method != "getMe" {
+			calls = append(calls, call)
+		}
+	}
+	f.calls = nil
+	return calls
+}
+
+// methods returns the method names of calls
+func methods(calls []apiCall) string {
+	names := make([]string, len(calls))
+	for i, call := range calls {
+		names[i] = call.method
+	}
+	return strings.Join(names, ",")
+}
+
+func TestHandoff(t *testing.T) {
+	const operatorChat, userChat = -100, 10
+	fake, api := newFakeTelegram(t)
+	cfg := &config.Config{Bot: config.BotConfig{
+		OperatorChatID:           operatorChat,
+		OperatorConnectedMessage: "connected",
+		OperatorClosedMessage:    "closed",
+		OperatorWaitingMessage:   "waiting",
+		TicketUsageMessage:       "usage",
+		TicketUndeliveredMessage: "undelivered",
+		TicketClosedMessage:      "ticket %d closed by %s",
+	}}
+	store := storage.NewMemoryStore()
+	// aiService is nil: asking the AI while a ticket is open would panic
+	handler := &Handler{config: cfg, logger: zap.NewNop(), bot: api, store: store}
+	handler.botSettings.Store(&cfg.Bot)
+	ctx := context.Background()
+
+	user := &tgbotapi.User{ID: userChat, FirstName: "Jane"}
+	handler.handOff(ctx, &tgbotapi.Chat{ID: userChat, Type: "private"}, user, ticketReasonCommand, "help")
+	if got := methods(fake.take()); got != "createForumTopic,sendMessage,sendMessage" {
+		t.Fatalf("handOff() made requests %s", got)
+	}
+	ticket := handler.chatTicket(ctx, userChat)
+	if ticket == nil || !ticket.Forum || ticket.ThreadID != 50 {
+		t.Fatalf("chatTicket() = %+v, want an open forum ticket in thread 50", ticket)
+	}
+
+	// The user's messages go to the operators instead of the AI
+	userMessage := messageUpdate(1, userChat, userChat, "private")
+	userMessage.Message.From = user
+	userMessage.Message.MessageID = 7
+	handler.routeUpdate(ctx, userMessage)
+	calls := fake.take()
+	if methods(calls) != "copyMessage" || calls[0].params["message_thread_id"] != "50" {
+		t.Fatalf("user message made requests %+v, want a copy into thread 50", calls)
+	}
+
+	// Operator replies in the topic go to the user
+	operator := &tgbotapi.User{ID: 99, FirstName: "Olga"}
+	reply := tgbotapi.Update{UpdateID: 2, Message: &tgbotapi.Message{
+		MessageID:      60,
+		Text:           "Your refund is on its way",
+		From:           operator,
+		Chat:           &tgbotapi.Chat{ID: operatorChat, Type: "supergroup"},
+		ReplyToMessage: &tgbotapi.Message{MessageID: 50},
+	}}
+	handler.routeUpdate(ctx, reply)
+	calls = fake.take()
+	if methods(calls) != "copyMessage" || calls[0].params["chat_id"] != "10" {
+		t.Fatalf("operator reply made requests %+v, want a copy to chat 10", calls)
+	}
+
+	conv, err := store.GetConversation(ctx, userChat)
+	if err != nil {
+		t.Fatalf("GetConversation() error = %v", err)
+	}
+	if len(conv.Messages) != 2 || conv.Messages[0].Content != "hello" || conv.Messages[1].Model != operatorModel {
+		t.Errorf("conversation = %+v, want the user message and the operator reply", conv.Messages)
+	}
+
+	// A failed relay tells the operators without the API error
+	fake.mu.Lock()
+	fake.blocked = map[string]bool{"10": true}
+	fake.mu.Unlock()
+	reply.Message.MessageID = 62
+	handler.routeUpdate(ctx, reply)
+	calls = fake.take()
+	if methods(calls) != "copyMessage,sendMessage" || calls[1].params["text"] != "undelivered" {
+		t.Fatalf("failed relay made requests %+v, want the undelivered message", calls)
+	}
+	fake.mu.Lock()
+	fake.blocked = nil
+	fake.mu.Unlock()
+
+	// Buttons under earlier AI answers do not ask the AI either
+	err = store.AppendMessages(ctx, userChat,
+		storage.ConversationMessage{Role: "user", Content: "question", UserID: userChat},
+		storage.ConversationMessage{Role: "assistant", Content: "answer", MessageID: 40},
+	)
+	if err != nil {
+		t.Fatalf("AppendMessages() error = %v", err)
+	}
+	handler.handleAnswerCallback(ctx, &tgbotapi.CallbackQuery{
+		ID:      "q",
+		From:    user,
+		Message: &tgbotapi.Message{MessageID: 40, Chat: &tgbotapi.Chat{ID: userChat, Type: "private"}},
+	}, answerRegenerate)
+	calls = fake.take()
+	if methods(calls) != "answerCallbackQuery" || calls[0].params["text"] != "waiting" {
+		t.Fatalf("answer button made requests %+v, want the waiting message", calls)
+	}
+
+	// /close ends the ticket and tells the user
+	closing := tgbotapi.Update{UpdateID: 3, Message: &tgbotapi.Message{
+		MessageID:      61,
+		Text:           "/close",
+		From:           operator,
+		Chat:           &tgbotapi.Chat{ID: operatorChat, Type: "supergroup"},
+		ReplyToMessage: &tgbotapi.Message{MessageID: 50},
+		Entities:       []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 6}},
+	}}
+	handler.routeUpdate(ctx, closing)
+	calls = fake.take()
+	if got := methods(calls); got != "sendMessage,closeForumTopic,sendMessage" {
+		t.Fatalf("/close made requests %s", got)
+	}
+	if first := calls[0]; first.params["text"] != "ticket 1 closed by Olga" {
+		t.Errorf("/close posted %q in the ticket, want %q", first.params["text"], "ticket 1 closed by Olga")
+	}
+	if last := calls[len(calls)-1]; last.params["chat_id"] != "10" || last.params["text"] != "closed" {
+		t.Errorf("/close notified %+v, want %q in chat 10", last.params, "closed")
+	}
+	if handler.chatTicket(ctx, userChat) != nil {
+		t.Error("chatTicket() found a ticket after /close")
+	}
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/usage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// Names of persistent counters; daily variants are suffixed with ":YYYY-MM-DD"
+const (
+	counterMessages = "messages"
+	counterAIErrors = "ai_errors"
+)
+
+// rememberUser records a user's profile the first time they contact the bot and
+// returns their settings, or nil if they cannot be loaded
+func (h *Handler) rememberUser(ctx context.Context, user *tgbotapi.User) *storage.UserSettings {
+	if user == nil {
+		return nil
+	}
+
+	settings, err := h.store.GetUserSettings(ctx, user.ID)
+	if err == nil {
+		return settings
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		h.log(ctx).Warn("failed to load user settings", zap.Int64("user_id", user.ID), zap.Error(err))
+		return nil
+	}
+
+	now := time.Now()
+	settings = &storage.UserSettings{
+		UserID:       user.ID,
+		Username:     user.UserName,
+		FirstName:    user.FirstName,
+		LanguageCode: user.LanguageCode,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.store.SaveUserSettings(ctx, settings); err != nil {
+		h.log(ctx).Warn("failed to save user settings", zap.Int64("user_id", user.ID), zap.Error(err))
+	}
+	return settings
+}
+
+// userSettings returns a user's settings, or nil if they are unknown
+func (h *Handler) userSettings(ctx context.Context, userID int64) *storage.UserSettings {
+	settings, err := h.store.GetUserSettings(ctx, userID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.log(ctx).Warn("failed to load user settings", zap.Int64("user_id", userID), zap.Error(err))
+		}
+		return nil
+	}
+	return settings
+}
+
+// quotaExceeded reports whether the user has used up the daily message limit
+func (h *Handler) quotaExceeded(ctx context.Context, userID int64, period string) bool {
+	limit := h.botConfig(ctx).DailyMessageLimit
+	if limit <= 0 {
+		return false
+	}
+
+	quota, err := h.store.GetQuota(ctx, userID, period)
+	if err != nil {
+		h.log(ctx).Warn("failed to load quota", zap.Int64("user_id", userID), zap.Error(err))
+		return false
+	}
+	return quota.Requests >= limit
+}
+
+// loadHistory returns the most recent conversation turns of a chat for the AI request
+func (h *Handler) loadHistory(ctx context.Context, chatID int64) []ai.Message {
+	conv, err := h.store.GetConversation(ctx, chatID)
+	if err != nil {
+		h.log(ctx).Warn("failed to load conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+		return nil
+	}
+
+	return aiMessages(conv.Tail(h.config.Storage.HistoryLimit))
+}
+
+// aiMessages converts stored messages to the history of an AI request
+func aiMessages(stored []storage.ConversationMessage) []ai.Message {
+	history := make([]ai.Message, 0, len(stored))
+	for _, msg := range stored {
+		history = append(history, ai.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return history
+}
+
+// saveExchange appends a user message and the answer of model, shown as the
+// Telegram message messageID, to the chat's conversation
+func (h *Handler) saveExchange(ctx context.Context, chatID, userID int64, messageID int, text, response, model string) {
+	now := time.Now()
+	err := h.store.AppendMessages(ctx, chatID,
+		storage.ConversationMessage{Role: "user", Content: text, UserID: userID, Timestamp: now},
+		storage.ConversationMessage{Role: "assistant", Content: response, Model: model, MessageID: messageID, Timestamp: now},
+	)
+	if err != nil {
+		h.log(ctx).Warn("failed to save conversation", zap.Int64("chat_id", chatID), zap.Error(err))
+	}
+}
+
+// incrementCounter bumps a persistent counter and its daily variant, logging failures
+func (h *Handler) incrementCounter(ctx context.Context, name string) {
+	day := time.Now().UTC().Format(usage.DayFormat)
+	for _, key := range []string{name, dailyCounter(name, day)} {
+		if _, err := h.store.IncrementCounter(ctx, key, 1); err != nil {
+			h.log(ctx).Warn("failed to increment counter", zap.String("counter", key), zap.Error(err))
+		}
+	}
+}
+
+// dailyCounter returns the name of a counter's variant for a day
+func dailyCounter(name, day string) string {
+	return name + ":" + day
+}
+
+// trackChat records the chat of an incoming message as a known chat
+func (h *Handler) trackChat(ctx context.Context, chat *tgbotapi.Chat) {
+	if chat == nil {
+		return
+	}
+
+	now := time.Now()
+	err := h.store.TouchChat(ctx, storage.ChatInfo{
+		ChatID:    chat.ID,
+		Type:      chat.Type,
+		Title:     chat.Title,
+		Username:  chat.UserName,
+		FirstSeen: now,
+		LastSeen:  now,
+	})
+	if err != nil {
+		h.log(ctx).Warn("failed to record chat", zap.Int64("chat_id", chat.ID), zap.Error(err))
+	}
+}
+
+// recordUsage adds a completion's tokens and cost to the usage aggregates
+func (h *Handler) recordUsage(ctx context.Context, chatID, userID int64, day string, completion *ai.Completion) {
+	cost := h.config.Usage.PriceTable.Cost(completion.Model, completion.Usage.PromptTokens, completion.Usage.CompletionTokens)
+	metrics.AICost.WithLabelValues(completion.Model).Add(cost)
+
+	err := h.store.RecordUsage(ctx, storage.UsageRecord{
+		Day:              day,
+		UserID:           userID,
+		ChatID:           chatID,
+		Model:            completion.Model,
+		Requests:         1,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		Cost:             cost,
+		LatencyMs:        completion.Latency.Milliseconds(),
+	})
+	if err != nil {
+		h.log(ctx).Warn("failed to record usage", zap.Int64("chat_id", chatID), zap.Error(err))
+	}
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/tracing"
+	"tgbot-skeleton/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// inlineRateWindow is the period BOT_INLINE_RATE_LIMIT applies to
+const inlineRateWindow = time.Minute
+
+// inlineCacheCapacity is the number of inline answers kept in the cache
+const inlineCacheCapacity = 1000
+
+// inlineStartParameter is the /start parameter of the button shown instead of
+// results when an inline query cannot be answered
+const inlineStartParameter = "inline"
+
+// Limits of inline result articles
+const (
+	maxInlineTitle       = 64
+	maxInlineDescription = 200
+)
+
+// inlineDebouncer lets through only the latest inline query of each user, so
+// that the AI is not asked about every prefix of a question being typed
+type inlineDebouncer struct {
+	mu sync.Mutex
+	// latest maps user IDs to the ID of their latest inline query
+	latest map[int64]string
+}
+
+// newInlineDebouncer creates a debouncer without pending queries
+func newInlineDebouncer() *inlineDebouncer {
+	return &inlineDebouncer{latest: make(map[int64]string)}
+}
+
+// settle waits for delay and reports whether the query is still the user's
+// latest one; it returns false early when ctx is done
+func (d *inlineDebouncer) settle(ctx context.Context, userID int64, queryID string, delay time.Duration) bool {
+	d.mu.Lock()
+	d.latest[userID] = queryID
+	d.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.latest[userID] != queryID {
+		return false
+	}
+	delete(d.latest, userID)
+	return true
+}
+
+// inlineCacheEntry is a cached inline answer
+type inlineCacheEntry struct {
+	answer  string
+	expires time.Time
+}
+
+// inlineCache keeps AI answers to inline queries for a while, so a user retyping
+// a query gets the same answer without another request
+type inlineCache struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]inlineCacheEntry
+}
+
+// newInlineCache creates a cache holding up to capacity answers
+func newInlineCache(capacity int) *inlineCache {
+	return &inlineCache{capacity: capacity, now: time.Now, entries: make(map[string]inlineCacheEntry)}
+}
+
+// get returns the cached answer for key, if it has not expired
+func (c *inlineCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return "", false
+	}
+	return entry.answer, true
+}
+
+// put caches an answer for ttl; when the cache is full, expired answers are
+// dropped first and then arbitrary ones
+func (c *inlineCache) put(key, answer string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.capacity {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.capacity {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = inlineCacheEntry{answer: answer, expires: now.Add(ttl)}
+}
+
+// inlineCacheKey identifies an inline query regardless of case and spacing;
+// answers are cached per user and language, since prompts may address the user
+// by name and the AI answers in the user's language
+func inlineCacheKey(userID int64, language, query string) string {
+	return strconv.FormatInt(userID, 10) + "\x00" + language + "\x00" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// handleInlineQuery answers "@bot question" typed in any chat with an AI answer
+// the user can insert into the conversation
+func (h *Handler) handleInlineQuery(ctx context.Context, query *tgbotapi.InlineQuery) {
+	text := strings.TrimSpace(query.Query)
+	if text == "" {
+		return
+	}
+	userID := query.From.ID
+	ctx = h.withLanguage(ctx, query.From, h.userSettings(ctx, userID))
+	bot := h.botConfig(ctx)
+
+	if !h.inlineDebouncer.settle(ctx, userID, query.ID, bot.InlineDebounce) {
+		metrics.InlineQueries.WithLabelValues("debounced").Inc()
+		return
+	}
+
+	h.log(ctx).Info("processing inline query",
+		zap.Int64("user_id", userID),
+		h.contentField("text", text),
+	)
+
+	language := ""
+	if lang := languageFrom(ctx); lang != nil {
+		language = lang.code
+	}
+	key := inlineCacheKey(userID, language, text)
+	if answer, ok := h.inlineCache.get(key); ok {
+		metrics.InlineQueries.WithLabelValues("cached").Inc()
+		h.answerInline(ctx, query.ID, inlineResult(query.ID, text, answer))
+		return
+	}
+
+	if !h.isAdmin(userID) && !h.inlineLimiter.allow(userID, bot.InlineRateLimit) {
+		metrics.InlineQueries.WithLabelValues("rate_limited").Inc()
+		h.log(ctx).Warn("inline rate limit exceeded", zap.Int64("user_id", userID))
+		h.answerInlineButton(ctx, query.ID, bot.InlineRateLimitMessage)
+		return
+	}
+	period := time.Now().UTC().Format("2006-01-02")
+	if h.quotaExceeded(ctx, userID, period) {
+		metrics.InlineQueries.WithLabelValues("quota_exceeded").Inc()
+		h.answerInlineButton(ctx, query.ID, bot.QuotaExceededMessage)
+		return
+	}
+
+	completion, err := h.aiService.Complete(ctx, h.inlineRequest(ctx, query, text))
+	if err != nil {
+		h.log(ctx).Error("failed to get AI response", zap.Error(err))
+		metrics.InlineQueries.WithLabelValues("error").Inc()
+		h.incrementCounter(ctx, counterAIErrors)
+		h.answerInlineButton(ctx, query.ID, bot.ErrorMessage)
+		return
+	}
+	metrics.InlineQueries.WithLabelValues("answered").Inc()
+
+	h.inlineCache.put(key, completion.Content, bot.InlineCacheTTL)
+	h.answerInline(ctx, query.ID, inlineResult(query.ID, text, completion.Content))
+	// Inline answers are not tied to a chat
+	h.accountCompletion(ctx, 0, userID, period, completion)
+}
+
+// inlineRequest builds the AI request for an inline query: without history or
+// chat persona, and with AI_INLINE_PROMPT asking for a short answer
+func (h *Handler) inlineRequest(ctx context.Context, query *tgbotapi.InlineQuery, text string) ai.Request {
+	message := &tgbotapi.Message{From: query.From, Chat: &tgbotapi.Chat{Type: query.ChatType}}
+	request := ai.Request{
+		Message:      text,
+		PromptData:   h.promptData(ctx, message),
+		Instructions: h.config.AI.InlinePrompt,
+		MaxTokens:    h.config.AI.InlineMaxTokens,
+	}
+	if lang := languageFrom(ctx); h.config.AI.AnswerInUserLanguage && lang != nil && lang.code != "" {
+		request.Instructions = strings.TrimSpace(request.Instructions + "\n\n" + answerLanguageInstruction(lang))
+	}
+	h.addKnowledge(ctx, &request, text)
+	return request
+}
+
+// inlineResult returns the article inserting an AI answer into the chat; the
+// question is its title and the start of the answer its description
+func inlineResult(id, question, answer string) tgbotapi.InlineQueryResultArticle {
+	article := tgbotapi.NewInlineQueryResultArticleMarkdown(id, truncate(question, maxInlineTitle), utils.ConvertMarkdownToTelegram(answer))
+	article.Description = truncate(strings.Join(strings.Fields(answer), " "), maxInlineDescription)
+	return article
+}
+
+// truncate shortens text to at most limit characters, ending it with "…" when cut
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
+
+// answerInline sends an inline result, falling back to plain text if Telegram
+// cannot parse its Markdown
+func (h *Handler) answerInline(ctx context.Context, queryID string, article tgbotapi.InlineQueryResultArticle) {
+	span := startTelegramSpan(ctx, "answerInlineQuery", 0)
+	config := tgbotapi.InlineConfig{
+		InlineQueryID: queryID,
+		Results:       []interface{}{article},
+		CacheTime:     int(h.botConfig(ctx).InlineCacheTTL.Seconds()),
+		IsPersonal:    true,
+	}
+
+	_, err := h.bot.Request(config)
+	if err != nil && isMarkdownError(err) {
+		h.log(ctx).Warn("telegram rejected markdown, answering as plain text", zap.Error(err))
+		metrics.MarkdownFallbacks.Inc()
+		span.AddEvent("markdown_fallback")
+		content := article.InputMessageContent.(tgbotapi.InputTextMessageContent)
+		content.ParseMode = ""
+		article.InputMessageContent = content
+		config.Results = []interface{}{article}
+		_, err = h.bot.Request(config)
+	}
+	tracing.End(span, err)
+	if err != nil {
+		metrics.TelegramSendErrors.WithLabelValues("answerInlineQuery").Inc()
+		h.log(ctx).Warn("failed to answer inline query", zap.Error(err))
+	}
+}
+
+// answerInlineButton answers an inline query without results, showing text on
+// a button that opens the private chat with the bot
+func (h *Handler) answerInlineButton(ctx context.Context, queryID, text string) {
+	span := startTelegramSpan(ctx, "answerInlineQuery", 0)
+	_, err := h.bot.Request(tgbotapi.InlineConfig{
+		InlineQueryID:     queryID,
+		Results:           []interface{}{},
+		IsPersonal:        true,
+		SwitchPMText:      truncate(text, maxInlineTitle),
+		SwitchPMParameter: inlineStartParameter,
+	})
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Warn("failed to answer inline query", zap.Error(err))
+	}
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func TestInlineDebouncer_Settle(t *testing.T) {
+	debouncer := newInlineDebouncer()
+
+	// Queries typed in quick succession: only the last one is answered
+	var wg sync.WaitGroup
+	results := make([]bool, 3)
+	for i, id := range []string{"a", "ab", "abc"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = debouncer.settle(context.Background(), 1, id, 50*time.Millisecond)
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	if results[0] || results[1] || !results[2] {
+		t.Errorf("settle() = %v, want only the last query", results)
+	}
+
+	// Another user's query does not replace this user's
+	if !debouncer.settle(context.Background(), 2, "x", time.Millisecond) {
+		t.Errorf("settle() for a single query = false, want true")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if debouncer.settle(ctx, 3, "y", time.Minute) {
+		t.Errorf("settle() with a cancelled context = true, want false")
+	}
+}
+
+func TestInlineCache(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := newInlineCache(2)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", "answer a", time.Minute)
+	if got, ok := cache.get("a"); !ok || got != "answer a" {
+		t.Errorf("get(a) = %q, %v, want cached answer", got, ok)
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("get(b) found an answer that was not cached")
+	}
+
+	cache.put("disabled", "answer", 0)
+	if _, ok := cache.get("disabled"); ok {
+		t.Errorf("get(disabled) found an answer cached without TTL")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("get(a) found an expired answer")
+	}
+
+	// A full cache drops expired answers first, then makes room
+	cache.put("b", "answer b", time.Minute)
+	cache.put("c", "answer c", time.Minute)
+	cache.put("d", "answer d", time.Minute)
+	if len(cache.entries) != 2 {
+		t.Errorf("cache holds %d answers, want capacity 2", len(cache.entries))
+	}
+	if got, ok := cache.get("d"); !ok || got != "answer d" {
+		t.Errorf("get(d) = %q, %v, want the latest answer", got, ok)
+	}
+}
+
+func TestInlineCacheKey(t *testing.T) {
+	if inlineCacheKey(1, "en", "What is  Go?") != inlineCacheKey(1, "en", " what is go? ") {
+		t.Errorf("inlineCacheKey() differs by case and spacing")
+	}
+	if inlineCacheKey(1, "en", "hello") == inlineCacheKey(1, "ru", "hello") {
+		t.Errorf("inlineCacheKey() is the same for different languages")
+	}
+	if inlineCacheKey(1, "en", "hello") == inlineCacheKey(2, "en", "hello") {
+		t.Errorf("inlineCacheKey() is the same for different users")
+	}
+}
+
+func TestInlineResult(t *testing.T) {
+	question := strings.Repeat("q", maxInlineTitle+10)
+	answer := "**Go** is a\nprogramming language."
+
+	article := inlineResult("1", question, answer)
+	if article.Type != "article" || article.ID != "1" {
+		t.Errorf("article = %+v, want an article with the query ID", article)
+	}
+	if title := []rune(article.Title); len(title) != maxInlineTitle || title[len(title)-1] != '…' {
+		t.Errorf("Title = %q, want the question cut to %d characters", article.Title, maxInlineTitle)
+	}
+	if article.Description != "**Go** is a programming language." {
+		t.Errorf("Description = %q, want the answer on one line", article.Description)
+	}
+	content, ok := article.InputMessageContent.(tgbotapi.InputTextMessageContent)
+	if !ok || content.ParseMode != tgbotapi.ModeMarkdown || !strings.Contains(content.Text, "*Go*") {
+		t.Errorf("InputMessageContent = %+v, want the answer in Telegram Markdown", article.InputMessageContent)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text     string
+		limit    int
+		expected string
+	}{
+		{text: "short", limit: 10, expected: "short"},
+		{text: "exactly10!", limit: 10, expected: "exactly10!"},
+		{text: "привет мир", limit: 7, expected: "привет…"},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.limit); got != tt.expected {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestHandleInlineQuery_CachePerUser(t *testing.T) {
+	// The provider greets the user named in the system prompt
+	requests := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req ai.ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(ai.ChatResponse{Choices: []ai.Choice{{Message: ai.Message{Role: "assistant", Content: req.Messages[0].Content}}}})
+	}))
+	t.Cleanup(provider.Close)
+	service, err := ai.NewService(provider.URL, "test-model", "key", "Hi {{.UserFirstName}}", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	fake, api := newFakeTelegram(t)
+	cfg := &config.Config{Bot: config.BotConfig{InlineCacheTTL: time.Minute}}
+	handler := &Handler{
+		config:          cfg,
+		logger:          zap.NewNop(),
+		bot:             api,
+		store:           storage.NewMemoryStore(),
+		aiService:       service,
+		inlineDebouncer: newInlineDebouncer(),
+		inlineCache:     newInlineCache(inlineCacheCapacity),
+		inlineLimiter:   newRateLimiter(inlineRateWindow),
+	}
+	handler.botSettings.Store(&cfg.Bot)
+
+	for i, user := range []*tgbotapi.User{{ID: 1, FirstName: "Alice"}, {ID: 2, FirstName: "Bob"}, {ID: 1, FirstName: "Alice"}} {
+		handler.handleInlineQuery(context.Background(), &tgbotapi.InlineQuery{ID: strconv.Itoa(i), From: user, Query: "hello"})
+	}
+
+	calls := fake.take()
+	if len(calls) != 3 {
+		t.Fatalf("handleInlineQuery() made requests %+v, want three answers", calls)
+	}
+	for i, want := range []string{"Hi Alice", "Hi Bob", "Hi Alice"} {
+		if got := calls[i].params["results"]; !strings.Contains(got, want) {
+			t.Errorf("answer %d = %s, want it to contain %q", i, got, want)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("AI requests = %d, want 2: one per user, then the cached answer", requests)
+	}
+}
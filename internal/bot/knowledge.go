@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/knowledge"
+	"tgbot-skeleton/internal/redact"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// loadKnowledge indexes the documents of KNOWLEDGE_DIR by keywords, or returns
+// nil when the knowledge base is not configured
+func loadKnowledge(cfg *config.Config) (*knowledge.Index, error) {
+	if cfg.Knowledge.Dir == "" {
+		return nil, nil
+	}
+	chunks, files, err := knowledge.LoadDir(cfg.Knowledge.Dir, cfg.Knowledge.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	return knowledge.NewIndex(chunks, files), nil
+}
+
+// newEmbedder returns the embedder of the knowledge base, or nil when it has
+// no embeddings model
+func newEmbedder(cfg *config.Config) knowledge.Embedder {
+	if cfg.Knowledge.Dir == "" || cfg.Knowledge.EmbeddingsModel == "" {
+		return nil
+	}
+	return ai.NewEmbedder(cfg.Knowledge.EmbeddingsURL, cfg.Knowledge.EmbeddingsModel, cfg.Knowledge.EmbeddingsAPIKey)
+}
+
+// knowledgeIndex returns the current knowledge base index, nil when it is not configured
+func (h *Handler) knowledgeIndex() *knowledge.Index {
+	return h.knowledge.Load()
+}
+
+// reindex re-reads the documents and replaces the index. When the documents
+// cannot be embedded, the new index searches by keywords only
+func (h *Handler) reindex(ctx context.Context) (*knowledge.Index, error) {
+	h.reindexMu.Lock()
+	defer h.reindexMu.Unlock()
+
+	start := time.Now()
+	index, err := loadKnowledge(h.config)
+	if err != nil {
+		h.log(ctx).Error("failed to index knowledge base, keeping the current index", zap.Error(err))
+		return nil, err
+	}
+	if index == nil {
+		return nil, nil
+	}
+	if h.embedder != nil {
+		embedded, err := index.Embed(ctx, h.embedder)
+		if err != nil {
+			h.log(ctx).Warn("failed to embed knowledge base, searching by keywords only", zap.Error(err))
+		} else {
+			index = embedded
+		}
+	}
+
+	h.knowledge.Store(index)
+	h.log(ctx).Info("indexed knowledge base",
+		zap.Int("files", index.Files()),
+		zap.Int("chunks", index.Chunks()),
+		zap.Bool("embedded", index.Embedded()),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return index, nil
+}
+
+// handleReindex rebuilds the knowledge base index on request of an administrator
+func (h *Handler) handleReindex(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+	h.sendTyping(ctx, chatID)
+
+	bot := h.botConfig(ctx)
+	start := time.Now()
+	// reindex logs the details of a failure
+	index, err := h.reindex(ctx)
+	if err != nil {
+		h.sendMessage(ctx, chatID, bot.ReindexFailedMessage)
+		return
+	}
+
+	message := bot.ReindexSuccessMessage
+	switch {
+	case index.Embedded():
+		message = bot.ReindexEmbeddedMessage
+	case h.embedder != nil:
+		message = bot.ReindexFallbackMessage
+	}
+	h.sendMessage(ctx, chatID, fmt.Sprintf(message, time.Since(start).Round(time.Millisecond), index.Files(), index.Chunks()))
+}
+
+// addKnowledge appends the knowledge base chunks relevant to query to the
+// instructions of a request. With PII redaction on, the query is redacted
+// before it can reach the embeddings provider
+func (h *Handler) addKnowledge(ctx context.Context, request *ai.Request, query string) {
+	index := h.knowledgeIndex()
+	if index == nil {
+		return
+	}
+	if h.config.AI.RedactPII {
+		query = redact.Redact(query)
+	}
+	chunks, err := index.Search(ctx, query, h.config.Knowledge.TopK)
+	if err != nil {
+		h.log(ctx).Warn("failed to search knowledge base by embeddings, using keywords", zap.Error(err))
+	}
+	if len(chunks) == 0 {
+		return
+	}
+
+	sources := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		sources[i] = chunk.Label()
+	}
+	h.log(ctx).Debug("retrieved knowledge", zap.Strings("sources", sources))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("knowledge.chunks", len(chunks)))
+	request.Instructions = strings.TrimSpace(request.Instructions + "\n\n" + knowledge.Instructions(chunks))
+}
@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func TestHandler_AddKnowledge(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "faq.md"), []byte("# Refunds\nRefunds are issued within 30 days."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Knowledge: config.KnowledgeConfig{Dir: dir, ChunkSize: 800, TopK: 3}}
+	handler := &Handler{config: cfg, logger: zap.NewNop()}
+
+	// Nothing is added before the documents are indexed
+	request := ai.Request{Instructions: "Answer in English."}
+	handler.addKnowledge(context.Background(), &request, "refunds")
+	if request.Instructions != "Answer in English." {
+		t.Errorf("Instructions = %q without an index, want them unchanged", request.Instructions)
+	}
+
+	index, err := handler.reindex(context.Background())
+	if err != nil {
+		t.Fatalf("reindex() error = %v", err)
+	}
+	if index.Files() != 1 || index.Chunks() != 1 || index.Embedded() {
+		t.Errorf("reindex() = %d files, %d chunks, embedded %v, want 1 file, 1 chunk, not embedded", index.Files(), index.Chunks(), index.Embedded())
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "Relevant chunk", query: "How do refunds work?", expected: "[faq.md › Refunds]\nRefunds are issued within 30 days."},
+		{name: "No relevant chunk", query: "hello there", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := ai.Request{Instructions: "Answer in English."}
+			handler.addKnowledge(context.Background(), &request, tt.query)
+			if !strings.HasPrefix(request.Instructions, "Answer in English.") {
+				t.Errorf("Instructions = %q, want the existing instructions first", request.Instructions)
+			}
+			if added := strings.Contains(request.Instructions, "knowledge base"); added != (tt.expected != "") {
+				t.Errorf("Instructions = %q, knowledge added %v, want %v", request.Instructions, added, tt.expected != "")
+			}
+			if !strings.Contains(request.Instructions, tt.expected) {
+				t.Errorf("Instructions = %q, want them to contain %q", request.Instructions, tt.expected)
+			}
+		})
+	}
+
+	// A broken directory keeps the current index
+	handler.config = &config.Config{Knowledge: config.KnowledgeConfig{Dir: filepath.Join(dir, "missing"), ChunkSize: 800, TopK: 3}}
+	if _, err := handler.reindex(context.Background()); err == nil {
+		t.Error("reindex() of a missing directory succeeded")
+	}
+	if handler.knowledgeIndex() != index {
+		t.Error("reindex() replaced the index after a failure")
+	}
+}
+
+// recordingEmbedder embeds every text as the same vector and remembers the texts
+type recordingEmbedder struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (e *recordingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.texts = append(e.texts, texts...)
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestHandler_AddKnowledgeRedaction(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "faq.md"), []byte("# Refunds\nRefunds are issued within 30 days."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const query = "Refund please, call +1 415 555 0132 or write to jane.doe@example.com"
+
+	tests := []struct {
+		name      string
+		redactPII bool
+		leaks     bool
+	}{
+		{name: "Redaction on", redactPII: true, leaks: false},
+		{name: "Redaction off", redactPII: false, leaks: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder := &recordingEmbedder{}
+			cfg := &config.Config{
+				AI:        config.AIConfig{RedactPII: tt.redactPII},
+				Knowledge: config.KnowledgeConfig{Dir: dir, ChunkSize: 800, TopK: 3},
+			}
+			handler := &Handler{config: cfg, logger: zap.NewNop(), embedder: embedder}
+			if _, err := handler.reindex(context.Background()); err != nil {
+				t.Fatalf("reindex() error = %v", err)
+			}
+			embedder.texts = nil
+
+			request := ai.Request{}
+			handler.addKnowledge(context.Background(), &request, query)
+			if len(embedder.texts) != 1 {
+				t.Fatalf("embedder got %q, want the query only", embedder.texts)
+			}
+			got := embedder.texts[0]
+			for _, secret := range []string{"555 0132", "jane.doe@example.com"} {
+				if leaked := strings.Contains(got, secret); leaked != tt.leaks {
+					t.Errorf("embedder got %q, contains %q %v, want %v", got, secret, leaked, tt.leaks)
+				}
+			}
+			if !strings.Contains(request.Instructions, "Refunds are issued") {
+				t.Errorf("Instructions = %q, want the refunds chunk", request.Instructions)
+			}
+		})
+	}
+}
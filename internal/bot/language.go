@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// languageCallbackPrefix starts the callback data of language buttons; an empty
+// code after the prefix goes back to the language of the user's Telegram app
+const languageCallbackPrefix = "language:"
+
+// autoLanguageLabel is the button label and /language argument that clears the choice
+const autoLanguageLabel = "auto"
+
+// userLanguage is the language of the user an update came from
+type userLanguage struct {
+	// code is the effective language tag, e.g. "pt-br"
+	code string
+	// chosen is the language picked with /language, empty for the Telegram language
+	chosen string
+	// catalog holds the messages for code after fallbacks, nil for the default messages
+	catalog *i18n.Catalog
+}
+
+// languageKey is the context key of the userLanguage of an update
+type languageKey struct{}
+
+// withLanguage resolves the user's language, preferring the /language choice
+// over the language of their Telegram app, and stores it in ctx
+func (h *Handler) withLanguage(ctx context.Context, user *tgbotapi.User, settings *storage.UserSettings) context.Context {
+	if user == nil {
+		return ctx
+	}
+
+	lang := &userLanguage{code: i18n.Normalize(user.LanguageCode)}
+	if settings != nil && settings.Language != "" {
+		lang.chosen = settings.Language
+		lang.code = settings.Language
+	}
+	lang.catalog = h.localeBundle().Resolve(lang.code)
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// languageFrom returns the user's language stored by withLanguage, or nil
+func languageFrom(ctx context.Context) *userLanguage {
+	lang, _ := ctx.Value(languageKey{}).(*userLanguage)
+	return lang
+}
+
+// botConfig returns the bot messages in the language of the current user along
+// with the bot settings
+func (h *Handler) botConfig(ctx context.Context) *config.BotConfig {
+	if lang := languageFrom(ctx); lang != nil && lang.catalog != nil {
+		return lang.catalog.Bot
+	}
+	if bot := h.botSettings.Load(); bot != nil {
+		return bot
+	}
+	return &h.config.Bot
+}
+
+// localeBundle returns the current message catalogs, nil when they are not configured
+func (h *Handler) localeBundle() *i18n.Bundle {
+	return h.locales.Load()
+}
+
+// loadLocales loads the message catalogs when a locales directory is configured
+func loadLocales(cfg *config.Config) (*i18n.Bundle, error) {
+	if cfg.Bot.LocalesDir == "" {
+		return nil, nil
+	}
+	bundle, err := i18n.Load(cfg.Bot.LocalesDir, &cfg.Bot, cfg.Bot.DefaultLanguage)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCatalogCommands(newCommandRouter().mustRegister(builtinCommands()...), bundle); err != nil {
+		return nil, err
+	}
+	if err := validateCatalogButtons(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// handleLanguage lists languages as inline buttons, or switches directly with /language <code>
+func (h *Handler) handleLanguage(ctx context.Context, call *commandCall) {
+	message := call.message
+	chatID := message.Chat.ID
+	bundle := h.localeBundle()
+
+	if bundle.Len() == 0 {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).LanguageDisabledMessage)
+		return
+	}
+
+	if code := call.argText; code != "" {
+		if code == autoLanguageLabel {
+			code = ""
+		}
+		h.switchLanguage(ctx, chatID, message.From, code)
+		return
+	}
+
+	current := ""
+	if lang := languageFrom(ctx); lang != nil {
+		current = lang.chosen
+	}
+
+	span := startTelegramSpan(ctx, "sendMessage", chatID)
+	msg := tgbotapi.NewMessage(chatID, h.botConfig(ctx).LanguageListMessage)
+	msg.ReplyMarkup = languageKeyboard(bundle.List(), current)
+	_, err := h.bot.Send(msg)
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Error("failed to send language list", zap.Error(err))
+	}
+}
+
+// handleLanguageCallback switches the language selected with an inline button
+func (h *Handler) handleLanguageCallback(ctx context.Context, query *tgbotapi.CallbackQuery, code string) {
+	h.answerCallback(ctx, query.ID, "")
+	if query.Message == nil {
+		return
+	}
+	h.switchLanguage(ctx, query.Message.Chat.ID, query.From, code)
+}
+
+// switchLanguage stores the user's language and confirms in the new language
+func (h *Handler) switchLanguage(ctx context.Context, chatID int64, user *tgbotapi.User, code string) {
+	catalog, ok := h.localeBundle().Get(code)
+	if code != "" && !ok {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).LanguageUnknownMessage)
+		return
+	}
+
+	settings, err := h.saveUserLanguage(ctx, user, i18n.Normalize(code))
+	if err != nil {
+		h.log(ctx).Error("failed to save user language", zap.Int64("user_id", user.ID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	label := autoLanguageLabel
+	if catalog != nil {
+		label = catalog.Label()
+	}
+	h.log(ctx).Info("switched language", zap.Int64("user_id", user.ID), zap.String("language", label))
+
+	ctx = h.withLanguage(ctx, user, settings)
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).LanguageSwitchedMessage, label))
+}
+
+// saveUserLanguage stores the /language choice, creating the user's settings if needed
+func (h *Handler) saveUserLanguage(ctx context.Context, user *tgbotapi.User, code string) (*storage.UserSettings, error) {
+	return h.saveUserSettings(ctx, user, func(settings *storage.UserSettings) {
+		settings.Language = code
+	})
+}
+
+// saveUserSettings applies update to the user's settings and stores them,
+// creating the settings if needed
+func (h *Handler) saveUserSettings(ctx context.Context, user *tgbotapi.User, update func(*storage.UserSettings)) (*storage.UserSettings, error) {
+	now := time.Now()
+	settings, err := h.store.GetUserSettings(ctx, user.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		settings = &storage.UserSettings{
+			UserID:       user.ID,
+			Username:     user.UserName,
+			FirstName:    user.FirstName,
+			LanguageCode: user.LanguageCode,
+			CreatedAt:    now,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	update(settings)
+	settings.UpdatedAt = now
+	if err := h.store.SaveUserSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// answerLanguageInstruction asks the model to answer in the user's language
+func answerLanguageInstruction(lang *userLanguage) string {
+	name := lang.code
+	if c := lang.catalog; c != nil && c.Name != "" && (c.Code == lang.code || strings.HasPrefix(lang.code, c.Code+"-")) {
+		name = fmt.Sprintf("%s (%s)", c.Name, lang.code)
+	}
+	return fmt.Sprintf("Always answer in the user's language: %s, unless they ask for another language.", name)
+}
+
+// languageKeyboard builds one button per language, marking the current choice
+func languageKeyboard(catalogs []*i18n.Catalog, current string) tgbotapi.InlineKeyboardMarkup {
+	button := func(label, code string) []tgbotapi.InlineKeyboardButton {
+		if code == current {
+			label = "✅ " + label
+		}
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, languageCallbackPrefix+code))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{button(autoLanguageLabel, "")}
+	for _, c := range catalogs {
+		rows = append(rows, button(c.Label(), c.Code))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
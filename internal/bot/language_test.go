@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/i18n"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestHandler_WithLanguage(t *testing.T) {
+	cfg := &config.Config{Bot: config.BotConfig{StartMessage: "Hello"}}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ru.yaml"), []byte("messages:\n  start_message: Привет\n"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	bundle, err := i18n.Load(dir, &cfg.Bot, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	handler := &Handler{config: cfg}
+	handler.botSettings.Store(&cfg.Bot)
+	handler.locales.Store(bundle)
+
+	tests := []struct {
+		name         string
+		languageCode string
+		chosen       string
+		expected     string
+	}{
+		{name: "Telegram language", languageCode: "ru", expected: "Привет"},
+		{name: "Regional Telegram language", languageCode: "ru-RU", expected: "Привет"},
+		{name: "No catalog", languageCode: "de", expected: "Hello"},
+		{name: "Chosen language wins", languageCode: "de", chosen: "ru", expected: "Привет"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &tgbotapi.User{ID: 1, LanguageCode: tt.languageCode}
+			ctx := handler.withLanguage(context.Background(), user, &storage.UserSettings{Language: tt.chosen})
+			if got := handler.botConfig(ctx).StartMessage; got != tt.expected {
+				t.Errorf("StartMessage = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if got := handler.botConfig(context.Background()).StartMessage; got != "Hello" {
+		t.Errorf("StartMessage without user = %q, want default", got)
+	}
+}
+
+func TestAnswerLanguageInstruction(t *testing.T) {
+	ru := &i18n.Catalog{Code: "ru", Name: "Русский"}
+
+	tests := []struct {
+		name     string
+		lang     *userLanguage
+		expected string
+	}{
+		{name: "Catalog name", lang: &userLanguage{code: "ru", catalog: ru}, expected: "Русский (ru)"},
+		{name: "Regional variant", lang: &userLanguage{code: "ru-ua", catalog: ru}, expected: "Русский (ru-ua)"},
+		{name: "Default catalog of another language", lang: &userLanguage{code: "de", catalog: ru}, expected: "de"},
+		{name: "No catalog", lang: &userLanguage{code: "es"}, expected: "es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := "Always answer in the user's language: " + tt.expected + ", unless they ask for another language."
+			if got := answerLanguageInstruction(tt.lang); got != want {
+				t.Errorf("answerLanguageInstruction() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLanguageKeyboard(t *testing.T) {
+	catalogs := []*i18n.Catalog{{Code: "en", Name: "English"}, {Code: "ru", Name: "Русский"}}
+
+	keyboard := languageKeyboard(catalogs, "ru")
+	if len(keyboard.InlineKeyboard) != 3 {
+		t.Fatalf("rows = %d, want auto plus one per language", len(keyboard.InlineKeyboard))
+	}
+
+	auto := keyboard.InlineKeyboard[0][0]
+	if auto.Text != "auto" || *auto.CallbackData != languageCallbackPrefix {
+		t.Errorf("auto button = %q/%q", auto.Text, *auto.CallbackData)
+	}
+	selected := keyboard.InlineKeyboard[2][0]
+	if selected.Text != "✅ Русский" || *selected.CallbackData != languageCallbackPrefix+"ru" {
+		t.Errorf("selected button = %q/%q", selected.Text, *selected.CallbackData)
+	}
+}
@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/logger"
+	"tgbot-skeleton/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// updateRateWindow is the period BOT_UPDATE_RATE_LIMIT applies to
+const updateRateWindow = time.Minute
+
+// dedupCapacity is the number of recent update IDs remembered to drop redeliveries
+const dedupCapacity = 1024
+
+// UpdateHandler processes one Telegram update
+type UpdateHandler func(ctx context.Context, update tgbotapi.Update)
+
+// UpdateMiddleware wraps an UpdateHandler, e.g. to log, filter or recover from panics
+type UpdateMiddleware func(next UpdateHandler) UpdateHandler
+
+// Chain wraps handler in middleware; the first middleware is the outermost
+func Chain(handler UpdateHandler, middleware ...UpdateMiddleware) UpdateHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Use adds middleware that runs after the built-in middleware, right before
+// the update is routed. It must be called before the bot starts
+func (h *Handler) Use(middleware ...UpdateMiddleware) {
+	h.middleware = append(h.middleware, middleware...)
+	h.pipeline = Chain(h.routeUpdate, h.middleware...)
+}
+
+// defaultMiddleware returns the built-in middleware in the order it runs
+func (h *Handler) defaultMiddleware() []UpdateMiddleware {
+	return []UpdateMiddleware{
+		h.logUpdates,
+		h.recoverPanics,
+		countUpdates,
+		h.deduplicateUpdates(newUpdateDeduplicator(dedupCapacity)),
+		h.authorizeUpdates,
+		h.limitUpdates(newRateLimiter(updateRateWindow)),
+	}
+}
+
+// correlationKey is the context key of the correlation ID of an update
+type correlationKey struct{}
+
+// correlationIDFrom returns the correlation ID of the current update, or ""
+func correlationIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// newCorrelationID returns a random ID that ties together the logs of one update
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logUpdates gives every update a correlation ID, carried by the logger in the
+// context, and logs the update once it is processed
+func (h *Handler) logUpdates(next UpdateHandler) UpdateHandler {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		id := newCorrelationID()
+		log := h.logger.With(zap.String("correlation_id", id), zap.Int("update_id", update.UpdateID))
+		ctx = context.WithValue(logger.WithContext(ctx, log), correlationKey{}, id)
+
+		start := time.Now()
+		next(ctx, update)
+
+		fields := []zap.Field{
+			zap.String("type", updateType(update)),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if chat := updateChat(update); chat != nil {
+			fields = append(fields, zap.Int64("chat_id", chat.ID))
+		}
+		if user := update.SentFrom(); user != nil {
+			fields = append(fields, zap.Int64("user_id", user.ID))
+		}
+		log.Info("processed update", fields...)
+	}
+}
+
+// recoverPanics logs a panic in update processing and answers with the error
+// message, instead of crashing the webhook goroutine or the polling loop
+func (h *Handler) recoverPanics(next UpdateHandler) UpdateHandler {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		defer func() {
+			if r := recover(); r != nil {
+				metrics.UpdatePanics.Inc()
+				h.log(ctx).Error("panic while processing update", zap.Any("panic", r), zap.Stack("stack"))
+				if chat := updateChat(update); chat != nil && update.Message != nil {
+					h.sendMessage(ctx, chat.ID, h.botConfig(ctx).ErrorMessage)
+				}
+			}
+		}()
+		next(ctx, update)
+	}
+}
+
+// countUpdates records update counts, updates in progress and processing time
+func countUpdates(next UpdateHandler) UpdateHandler {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		kind := updateType(update)
+		metrics.UpdatesReceived.WithLabelValues(kind).Inc()
+		metrics.ActiveWorkers.Inc()
+		defer metrics.ActiveWorkers.Dec()
+
+		start := time.Now()
+		next(ctx, update)
+		metrics.UpdateDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+	}
+}
+
+// updateDeduplicator remembers the most recent update IDs
+type updateDeduplicator struct {
+	mu   sync.Mutex
+	seen map[int]struct{}
+	// ring holds the remembered IDs in arrival order; next is the oldest
+	ring []int
+	next int
+}
+
+// newUpdateDeduplicator remembers up to capacity update IDs
+func newUpdateDeduplicator(capacity int) *updateDeduplicator {
+	return &updateDeduplicator{seen: make(map[int]struct{}, capacity), ring: make([]int, 0, capacity)}
+}
+
+// first records an update ID and reports whether it was not seen before
+func (d *updateDeduplicator) first(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+	if len(d.ring) < cap(d.ring) {
+		d.ring = append(d.ring, id)
+	} else {
+		delete(d.seen, d.ring[d.next])
+		d.ring[d.next] = id
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.seen[id] = struct{}{}
+	return true
+}
+
+// deduplicateUpdates drops updates Telegram delivers again, e.g. when a webhook
+// response was lost
+func (h *Handler) deduplicateUpdates(dedup *updateDeduplicator) UpdateMiddleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			if !dedup.first(update.UpdateID) {
+				metrics.UpdatesDropped.WithLabelValues("duplicate").Inc()
+				h.log(ctx).Debug("dropped duplicate update")
+				return
+			}
+			next(ctx, update)
+		}
+	}
+}
+
+// access is the outcome of access control for an update
+type access int
+
+const (
+	accessGranted access = iota
+	// accessDenied is for users and chats outside the allowlists
+	accessDenied
+	// accessBlocked is for blocked users, who are ignored silently
+	accessBlocked
+)
+
+// checkAccess applies BOT_BLOCKED_USER_IDS, BOT_ALLOWED_USER_IDS and
+// BOT_ALLOWED_CHAT_IDS; administrators and the operator chat are always granted access
+func checkAccess(bot *config.BotConfig, user *tgbotapi.User, chat *tgbotapi.Chat) access {
+	if user != nil {
+		if slices.Contains(bot.BlockedUserIDs, user.ID) {
+			return accessBlocked
+		}
+		if slices.Contains(bot.AdminIDs, user.ID) || slices.Contains(bot.AllowedUserIDs, user.ID) {
+			return accessGranted
+		}
+	}
+	if len(bot.AllowedUserIDs) == 0 && len(bot.AllowedChatIDs) == 0 {
+		return accessGranted
+	}
+	if chat != nil && (slices.Contains(bot.AllowedChatIDs, chat.ID) || chat.ID == bot.OperatorChatID) {
+		return accessGranted
+	}
+	return accessDenied
+}
+
+// authorizeUpdates drops updates from blocked users and from users and chats
+// outside the allowlists; denied users are told so in private chats
+func (h *Handler) authorizeUpdates(next UpdateHandler) UpdateHandler {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		chat := updateChat(update)
+		switch checkAccess(h.botConfig(ctx), update.SentFrom(), chat) {
+		case accessGranted:
+			next(ctx, update)
+			return
+		case accessDenied:
+			if update.Message != nil && chat.IsPrivate() {
+				h.sendMessage(ctx, chat.ID, h.botConfig(ctx).AccessDeniedMessage)
+			}
+		}
+		metrics.UpdatesDropped.WithLabelValues("access_denied").Inc()
+		h.log(ctx).Debug("dropped update without access")
+	}
+}
+
+// limitUpdates drops updates from users who send more than
+// BOT_UPDATE_RATE_LIMIT per minute; administrators are exempt. Inline queries,
+// which arrive as the user types, have their own limit
+func (h *Handler) limitUpdates(limiter *rateLimiter) UpdateMiddleware {
+	return func(next UpdateHandler) UpdateHandler {
+		return func(ctx context.Context, update tgbotapi.Update) {
+			user := update.SentFrom()
+			if user != nil && update.InlineQuery == nil && !h.isAdmin(user.ID) && !limiter.allow(user.ID, h.botConfig(ctx).UpdateRateLimit) {
+				metrics.UpdatesDropped.WithLabelValues("rate_limited").Inc()
+				h.log(ctx).Debug("dropped update over the rate limit", zap.Int64("user_id", user.ID))
+				return
+			}
+			next(ctx, update)
+		}
+	}
+}
+
+// updateChat returns the chat of an update, or nil, e.g. for inline queries
+func updateChat(update tgbotapi.Update) *tgbotapi.Chat {
+	if update.CallbackQuery != nil {
+		if update.CallbackQuery.Message == nil {
+			return nil
+		}
+		return update.CallbackQuery.Message.Chat
+	}
+	return update.FromChat()
+}
@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"tgbot-skeleton/internal/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// messageUpdate returns an update with a text message from a user in a chat
+func messageUpdate(updateID int, userID, chatID int64, chatType string) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message: &tgbotapi.Message{
+			Text: "hello",
+			From: &tgbotapi.User{ID: userID},
+			Chat: &tgbotapi.Chat{ID: chatID, Type: chatType},
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) UpdateMiddleware {
+		return func(next UpdateHandler) UpdateHandler {
+			return func(ctx context.Context, update tgbotapi.Update) {
+				order = append(order, name)
+				next(ctx, update)
+			}
+		}
+	}
+
+	handler := Chain(func(ctx context.Context, update tgbotapi.Update) { order = append(order, "handler") },
+		trace("first"), trace("second"))
+	handler(context.Background(), tgbotapi.Update{})
+
+	if expected := []string{"first", "second", "handler"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("order = %v, want %v", order, expected)
+	}
+}
+
+func TestHandler_RecoverPanics(t *testing.T) {
+	handler := &Handler{config: &config.Config{}, logger: zap.NewNop(), bot: testBotAPI(t)}
+	next := handler.recoverPanics(func(ctx context.Context, update tgbotapi.Update) { panic("boom") })
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("panic was not recovered: %v", r)
+		}
+	}()
+	next(context.Background(), messageUpdate(1, 1, 1, "private"))
+}
+
+func TestHandler_LogUpdates(t *testing.T) {
+	handler := &Handler{logger: zap.NewNop()}
+	var ids []string
+	next := handler.logUpdates(func(ctx context.Context, update tgbotapi.Update) {
+		ids = append(ids, correlationIDFrom(ctx))
+	})
+
+	next(context.Background(), messageUpdate(1, 1, 1, "private"))
+	next(context.Background(), messageUpdate(2, 1, 1, "private"))
+
+	if len(ids) != 2 || len(ids[0]) != 16 || ids[0] == ids[1] {
+		t.Errorf("correlation IDs = %q, want two distinct IDs", ids)
+	}
+}
+
+func TestUpdateDeduplicator(t *testing.T) {
+	dedup := newUpdateDeduplicator(2)
+
+	steps := []struct {
+		id       int
+		expected bool
+	}{
+		{id: 1, expected: true},
+		{id: 1, expected: false},
+		{id: 2, expected: true},
+		// 1 is forgotten once 3 takes its place
+		{id: 3, expected: true},
+		{id: 2, expected: false},
+		{id: 1, expected: true},
+		{id: 3, expected: false},
+	}
+
+	for i, step := range steps {
+		if got := dedup.first(step.id); got != step.expected {
+			t.Errorf("step %d: first(%d) = %v, want %v", i, step.id, got, step.expected)
+		}
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		bot      config.BotConfig
+		userID   int64
+		chatID   int64
+		expected access
+	}{
+		{name: "Open bot", userID: 1, chatID: 1, expected: accessGranted},
+		{name: "Blocked user", bot: config.BotConfig{BlockedUserIDs: []int64{1}}, userID: 1, chatID: 1, expected: accessBlocked},
+		{name: "Allowed user", bot: config.BotConfig{AllowedUserIDs: []int64{1}}, userID: 1, chatID: 1, expected: accessGranted},
+		{name: "Other user", bot: config.BotConfig{AllowedUserIDs: []int64{1}}, userID: 2, chatID: 2, expected: accessDenied},
+		{name: "Allowed chat", bot: config.BotConfig{AllowedChatIDs: []int64{-100}}, userID: 2, chatID: -100, expected: accessGranted},
+		{name: "Private chat outside allowed chats", bot: config.BotConfig{AllowedChatIDs: []int64{-100}}, userID: 2, chatID: 2, expected: accessDenied},
+		{name: "Administrator", bot: config.BotConfig{AllowedUserIDs: []int64{1}, AdminIDs: []int64{9}}, userID: 9, chatID: 9, expected: accessGranted},
+		{name: "Blocked administrator", bot: config.BotConfig{AdminIDs: []int64{9}, BlockedUserIDs: []int64{9}}, userID: 9, chatID: 9, expected: accessBlocked},
+		{name: "Operator chat", bot: config.BotConfig{AllowedUserIDs: []int64{1}, OperatorChatID: -200}, userID: 2, chatID: -200, expected: accessGranted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkAccess(&tt.bot, &tgbotapi.User{ID: tt.userID}, &tgbotapi.Chat{ID: tt.chatID})
+			if got != tt.expected {
+				t.Errorf("checkAccess() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandler_FilterUpdates(t *testing.T) {
+	cfg := &config.Config{Bot: config.BotConfig{
+		AdminIDs:        []int64{9},
+		AllowedUserIDs:  []int64{1, 9},
+		UpdateRateLimit: 2,
+	}}
+	handler := &Handler{config: cfg, logger: zap.NewNop(), bot: testBotAPI(t)}
+
+	var processed []int
+	pipeline := Chain(func(ctx context.Context, update tgbotapi.Update) { processed = append(processed, update.UpdateID) },
+		handler.deduplicateUpdates(newUpdateDeduplicator(10)),
+		handler.authorizeUpdates,
+		handler.limitUpdates(newRateLimiter(updateRateWindow)),
+	)
+
+	updates := []tgbotapi.Update{
+		messageUpdate(1, 1, 1, "private"),
+		messageUpdate(1, 1, 1, "private"), // duplicate
+		messageUpdate(2, 2, 2, "private"), // not allowed
+		messageUpdate(3, 1, 1, "private"),
+		messageUpdate(4, 1, 1, "private"), // over the rate limit
+		messageUpdate(5, 9, 9, "private"),
+		messageUpdate(6, 9, 9, "private"),
+		messageUpdate(7, 9, 9, "private"), // administrators are not limited
+		// inline queries have their own limit
+		{UpdateID: 8, InlineQuery: &tgbotapi.InlineQuery{ID: "q", From: &tgbotapi.User{ID: 1}}},
+	}
+	for _, update := range updates {
+		pipeline(context.Background(), update)
+	}
+
+	if expected := []int{1, 3, 5, 6, 7, 8}; !reflect.DeepEqual(processed, expected) {
+		t.Errorf("processed = %v, want %v", processed, expected)
+	}
+}
+
+func TestUpdateChat(t *testing.T) {
+	chat := &tgbotapi.Chat{ID: 5}
+	tests := []struct {
+		name     string
+		update   tgbotapi.Update
+		expected *tgbotapi.Chat
+	}{
+		{name: "Message", update: tgbotapi.Update{Message: &tgbotapi.Message{Chat: chat}}, expected: chat},
+		{name: "Callback", update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{Message: &tgbotapi.Message{Chat: chat}}}, expected: chat},
+		{name: "Inline message callback", update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{InlineMessageID: "x"}}},
+		{name: "Inline query", update: tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateChat(tt.update); got != tt.expected {
+				t.Errorf("updateChat() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
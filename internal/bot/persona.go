@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/persona"
+	"tgbot-skeleton/internal/prompt"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// personaCallbackPrefix starts the callback data of persona buttons; an empty
+// name after the prefix selects the default prompt
+const personaCallbackPrefix = "persona:"
+
+// defaultPersonaLabel is the button label of the default prompt
+const defaultPersonaLabel = "default"
+
+// handlePersona lists personas as inline buttons, or switches directly with /persona <name>
+func (h *Handler) handlePersona(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+	personas := h.personaLibrary()
+
+	if personas.Len() == 0 {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).PersonaDisabledMessage)
+		return
+	}
+
+	if name := call.argText; name != "" {
+		if name == defaultPersonaLabel {
+			name = ""
+		}
+		h.switchPersona(ctx, chatID, name)
+		return
+	}
+
+	current := ""
+	if p := h.chatPersona(ctx, chatID); p != nil {
+		current = p.Name
+	}
+
+	span := startTelegramSpan(ctx, "sendMessage", chatID)
+	msg := tgbotapi.NewMessage(chatID, personaListText(h.botConfig(ctx).PersonaListMessage, personas.List()))
+	msg.ReplyMarkup = personaKeyboard(personas.List(), current)
+	_, err := h.bot.Send(msg)
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Error("failed to send persona list", zap.Error(err))
+	}
+}
+
+// handlePersonaCallback switches the persona selected with an inline button
+func (h *Handler) handlePersonaCallback(ctx context.Context, query *tgbotapi.CallbackQuery, name string) {
+	h.answerCallback(ctx, query.ID, "")
+	if query.Message == nil {
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	if !h.commandAllowed(ctx, chatID, query.From.ID, "persona") {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).UnknownCommandMessage)
+		return
+	}
+	h.switchPersona(ctx, chatID, name)
+}
+
+// switchPersona stores the chat's persona and greets with its welcome text
+func (h *Handler) switchPersona(ctx context.Context, chatID int64, name string) {
+	p, ok := h.personaLibrary().Get(name)
+	if name != "" && !ok {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).PersonaUnknownMessage)
+		return
+	}
+
+	if err := h.store.SetChatPersona(ctx, chatID, name); err != nil {
+		h.log(ctx).Error("failed to save chat persona", zap.Int64("chat_id", chatID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	label := defaultPersonaLabel
+	if p != nil {
+		label = p.Label()
+	}
+	h.log(ctx).Info("switched persona", zap.Int64("chat_id", chatID), zap.String("persona", label))
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).PersonaSwitchedMessage, label))
+
+	if p != nil && p.Welcome != "" {
+		h.sendMessage(ctx, chatID, p.Welcome)
+	}
+}
+
+// chatPersona returns the persona selected for a chat, or nil for the default prompt
+func (h *Handler) chatPersona(ctx context.Context, chatID int64) *persona.Persona {
+	personas := h.personaLibrary()
+	if personas.Len() == 0 {
+		return nil
+	}
+
+	chat, err := h.store.GetChat(ctx, chatID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.log(ctx).Warn("failed to load chat", zap.Int64("chat_id", chatID), zap.Error(err))
+		}
+		return nil
+	}
+	p, _ := personas.Get(chat.Persona)
+	return p
+}
+
+// startMessage returns the welcome text of the chat's persona or the configured start message
+func (h *Handler) startMessage(ctx context.Context, chatID int64) string {
+	if p := h.chatPersona(ctx, chatID); p != nil && p.Welcome != "" {
+		return p.Welcome
+	}
+	return h.botConfig(ctx).StartMessage
+}
+
+// loadPersonas loads the persona library when a prompts directory is configured
+func loadPersonas(cfg *config.Config) (*persona.Library, error) {
+	if cfg.AI.PromptsDir == "" {
+		return nil, nil
+	}
+	return persona.Load(cfg.AI.PromptsDir, cfg.AI.Vars)
+}
+
+// personaListText appends the descriptions of personas to the list message
+func personaListText(header string, personas []*persona.Persona) string {
+	var b strings.Builder
+	b.WriteString(header)
+	for _, p := range personas {
+		if p.Description != "" {
+			fmt.Fprintf(&b, "\n• %s — %s", p.Label(), p.Description)
+		}
+	}
+	return b.String()
+}
+
+// applyPersona sets the prompt and the front-matter overrides of a persona on a request
+func applyPersona(req *ai.Request, p *persona.Persona) {
+	req.Prompt = p.Prompt
+	req.Model = p.Model
+	req.Temperature = p.Temperature
+	req.MaxTokens = p.MaxTokens
+	if len(p.Examples) > 0 {
+		req.Examples = exampleMessages(p.Examples)
+	}
+}
+
+// promptData returns the prompt template values describing the sender and chat of a message
+func (h *Handler) promptData(ctx context.Context, message *tgbotapi.Message) prompt.Data {
+	data := prompt.Data{
+		ChatTitle:   message.Chat.Title,
+		ChatType:    message.Chat.Type,
+		BotUsername: h.bot.Self.UserName,
+	}
+	if message.From != nil {
+		data.UserFirstName = message.From.FirstName
+		data.UserLastName = message.From.LastName
+		data.Username = message.From.UserName
+		data.LanguageCode = message.From.LanguageCode
+	}
+	if lang := languageFrom(ctx); lang != nil {
+		data.LanguageCode = lang.code
+	}
+	return data
+}
+
+// exampleMessages converts few-shot examples into conversation turns
+func exampleMessages(examples []prompt.Example) []ai.Message {
+	messages := make([]ai.Message, 0, len(examples)*2)
+	for _, example := range examples {
+		messages = append(messages,
+			ai.Message{Role: "user", Content: example.User},
+			ai.Message{Role: "assistant", Content: example.Assistant},
+		)
+	}
+	return messages
+}
+
+// commandAllowed reports whether a command may run in a chat: the chat's persona
+// or the bot configuration may restrict commands, except /start, /help and the
+// commands of administrators
+func (h *Handler) commandAllowed(ctx context.Context, chatID, userID int64, command string) bool {
+	if command == "start" || command == "help" || h.isAdmin(userID) {
+		return true
+	}
+
+	allowed := h.botConfig(ctx).AllowedCommands
+	if p := h.chatPersona(ctx, chatID); p != nil && len(p.Commands) > 0 {
+		allowed = p.Commands
+	}
+	return len(allowed) == 0 || slices.Contains(allowed, command)
+}
+
+// personaKeyboard builds one button per persona, marking the current one
+func personaKeyboard(personas []*persona.Persona, current string) tgbotapi.InlineKeyboardMarkup {
+	button := func(label, name string) []tgbotapi.InlineKeyboardButton {
+		if name == current {
+			label = "✅ " + label
+		}
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, personaCallbackPrefix+name))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{button(defaultPersonaLabel, "")}
+	for _, p := range personas {
+		rows = append(rows, button(p.Label(), p.Name))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// answerCallback acknowledges a callback query so the client stops its loading indicator
+func (h *Handler) answerCallback(ctx context.Context, queryID, text string) {
+	span := startTelegramSpan(ctx, "answerCallbackQuery", 0)
+	_, err := h.bot.Request(tgbotapi.NewCallback(queryID, text))
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Warn("failed to answer callback query", zap.Error(err))
+	}
+}
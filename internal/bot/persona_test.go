@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"testing"
+
+	"tgbot-skeleton/internal/persona"
+)
+
+func TestPersonaKeyboard(t *testing.T) {
+	personas := []*persona.Persona{{Name: "support"}, {Name: "teacher"}}
+
+	keyboard := personaKeyboard(personas, "teacher")
+
+	expected := []struct {
+		text string
+		data string
+	}{
+		{text: "default", data: "persona:"},
+		{text: "support", data: "persona:support"},
+		{text: "✅ teacher", data: "persona:teacher"},
+	}
+	if len(keyboard.InlineKeyboard) != len(expected) {
+		t.Fatalf("keyboard has %d rows, want %d", len(keyboard.InlineKeyboard), len(expected))
+	}
+	for i, want := range expected {
+		button := keyboard.InlineKeyboard[i][0]
+		if button.Text != want.text || button.CallbackData == nil || *button.CallbackData != want.data {
+			t.Errorf("row %d = %q/%v, want %q/%q", i, button.Text, button.CallbackData, want.text, want.data)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter allows a number of events per key within a sliding window
+type rateLimiter struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// events holds the times of recent events per key, oldest first
+	events    map[int64][]time.Time
+	lastSweep time.Time
+}
+
+// newRateLimiter creates a limiter counting events within window
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		window: window,
+		now:    time.Now,
+		events: make(map[int64][]time.Time),
+	}
+}
+
+// allow records an event for key and reports whether it is within limit;
+// a limit of 0 or less allows everything. Rejected events are not recorded
+func (l *rateLimiter) allow(key int64, limit int) bool {
+	if l == nil || limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+	l.sweep(now, cutoff)
+
+	events := dropBefore(l.events[key], cutoff)
+	if len(events) >= limit {
+		l.events[key] = events
+		return false
+	}
+	l.events[key] = append(events, now)
+	return true
+}
+
+// sweep forgets keys without recent events once per window, so that users who
+// stopped writing do not accumulate
+func (l *rateLimiter) sweep(now, cutoff time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key, events := range l.events {
+		if events = dropBefore(events, cutoff); len(events) == 0 {
+			delete(l.events, key)
+		} else {
+			l.events[key] = events
+		}
+	}
+}
+
+// dropBefore removes the events that happened before cutoff
+func dropBefore(events []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(events) && events[i].Before(cutoff) {
+		i++
+	}
+	return events[i:]
+}
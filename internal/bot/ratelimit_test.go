@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance  time.Duration
+		key      int64
+		expected bool
+	}{
+		{key: 1, expected: true},
+		{advance: 10 * time.Second, key: 1, expected: true},
+		{advance: 10 * time.Second, key: 1, expected: false},
+		{key: 2, expected: true},
+		// The first event leaves the window, the rejected one was not recorded
+		{advance: 41 * time.Second, key: 1, expected: true},
+		{key: 1, expected: false},
+	}
+
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if got := limiter.allow(step.key, 2); got != step.expected {
+			t.Errorf("step %d: allow(%d) = %v, want %v", i, step.key, got, step.expected)
+		}
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow(1, 5)
+	now = now.Add(2 * time.Minute)
+	limiter.allow(2, 5)
+
+	if _, ok := limiter.events[1]; ok {
+		t.Errorf("events of an idle key were kept")
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	limiter := newRateLimiter(time.Minute)
+	for i := 0; i < 10; i++ {
+		if !limiter.allow(1, 0) {
+			t.Fatalf("allow() with limit 0 = false")
+		}
+	}
+	if !(*rateLimiter)(nil).allow(1, 1) {
+		t.Errorf("nil limiter allow() = false")
+	}
+}
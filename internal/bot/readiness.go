@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Readiness check tuning
+const (
+	// readyCacheTTL is how long a component check result is reused
+	readyCacheTTL = 30 * time.Second
+	// readyCheckTimeout bounds a single component check
+	readyCheckTimeout = 5 * time.Second
+)
+
+// componentStatus is the readiness of a single dependency
+type componentStatus struct {
+	Status    string    `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// readinessReport is the JSON body returned by /ready
+type readinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// cachedCheck runs a check at most once per ttl and remembers the result
+type cachedCheck struct {
+	ttl   time.Duration
+	check func(ctx context.Context) error
+
+	mu   sync.Mutex
+	last componentStatus
+}
+
+// status returns the cached result or runs the check if it has expired
+func (c *cachedCheck) status(ctx context.Context) componentStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < c.ttl {
+		return c.last
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+	c.last = componentStatus{
+		Status:    "ok",
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		c.last.Status = "fail"
+		c.last.Error = err.Error()
+	}
+	return c.last
+}
+
+// readinessChecker checks Telegram, the AI provider and storage
+type readinessChecker struct {
+	checks map[string]*cachedCheck
+}
+
+// newReadinessChecker wires component checks for the bot's dependencies
+func (b *Bot) newReadinessChecker() *readinessChecker {
+	return &readinessChecker{
+		checks: map[string]*cachedCheck{
+			"telegram": {
+				ttl: readyCacheTTL,
+				check: func(context.Context) error {
+					_, err := b.api.GetMe()
+					return err
+				},
+			},
+			"ai": {
+				ttl: readyCacheTTL,
+				check: func(ctx context.Context) error {
+					// A recent successful completion proves the provider is reachable
+					if time.Since(b.aiService.LastSuccess()) < readyCacheTTL {
+						return nil
+					}
+					return b.aiService.Ping(ctx)
+				},
+			},
+			"storage": {
+				ttl:   readyCacheTTL,
+				check: b.store.Ping,
+			},
+		},
+	}
+}
+
+// report checks every component concurrently
+func (r *readinessChecker) report(ctx context.Context) readinessReport {
+	report := readinessReport{
+		Status:     "ok",
+		Components: make(map[string]componentStatus, len(r.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range r.checks {
+		wg.Add(1)
+		go func(name string, check *cachedCheck) {
+			defer wg.Done()
+			status := check.status(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = status
+			if status.Status != "ok" {
+				report.Status = "fail"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// handleReady reports whether the bot's dependencies are reachable
+func (b *Bot) handleReady(w http.ResponseWriter, r *http.Request) {
+	report := b.readiness.report(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		b.logger.Error("failed to write readiness response", zap.Error(err))
+	}
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCachedCheck_ReusesResult(t *testing.T) {
+	calls := 0
+	check := &cachedCheck{
+		ttl: time.Minute,
+		check: func(context.Context) error {
+			calls++
+			return nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if status := check.status(context.Background()); status.Status != "ok" {
+			t.Errorf("status() = %+v, want ok", status)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("check ran %d times, want 1", calls)
+	}
+}
+
+func TestReadinessChecker_Report(t *testing.T) {
+	checker := &readinessChecker{
+		checks: map[string]*cachedCheck{
+			"telegram": {ttl: time.Minute, check: func(context.Context) error { return nil }},
+			"ai":       {ttl: time.Minute, check: func(context.Context) error { return errors.New("unreachable") }},
+		},
+	}
+
+	report := checker.report(context.Background())
+	if report.Status != "fail" {
+		t.Errorf("report status = %q, want fail", report.Status)
+	}
+	if report.Components["telegram"].Status != "ok" {
+		t.Errorf("telegram = %+v, want ok", report.Components["telegram"])
+	}
+	if ai := report.Components["ai"]; ai.Status != "fail" || ai.Error != "unreachable" {
+		t.Errorf("ai = %+v, want fail with error", ai)
+	}
+}
@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/metrics"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDebounce collects the burst of events editors produce when saving a file
+const reloadDebounce = 500 * time.Millisecond
+
+// reload re-reads the config and prompt files and, once all of them are valid,
+// swaps the system prompt, personas and bot messages. Other settings such as
+// the token, model or storage need a restart
+func (h *Handler) reload(source string) error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	err := h.swapConfig()
+	if err != nil {
+		metrics.ConfigReloads.WithLabelValues("error").Inc()
+		h.logger.Error("failed to reload configuration, keeping the current one",
+			zap.String("source", source),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	h.logger.Info("reloaded prompts and bot messages",
+		zap.String("source", source),
+		zap.Int("personas", h.personaLibrary().Len()),
+	)
+
+	// Personas, catalogs and allowed commands change which commands are offered
+	h.registerCommands(context.Background())
+
+	// Jobs of config.yaml may have changed
+	if err := h.syncSchedules(context.Background()); err != nil {
+		h.logger.Error("failed to update scheduled jobs", zap.Error(err))
+	}
+	return nil
+}
+
+// swapConfig loads and validates the configuration before replacing anything
+func (h *Handler) swapConfig() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	personas, err := loadPersonas(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load personas: %w", err)
+	}
+	locales, err := loadLocales(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load locales: %w", err)
+	}
+	if err := validateSchedules(cfg.Bot.Schedules, personas, cfg.Bot.Timezone, cfg.Bot.ScheduleMinInterval); err != nil {
+		return err
+	}
+
+	// Swapping the system prompt validates it, so it goes first and nothing
+	// else is replaced when it is invalid
+	if err := h.aiService.SetPrompt(cfg.AI.Prompt, cfg.AI.Vars, exampleMessages(cfg.AI.Examples)); err != nil {
+		return err
+	}
+	h.personas.Store(personas)
+	h.botSettings.Store(&cfg.Bot)
+	h.locales.Store(locales)
+	return nil
+}
+
+// handleReload reloads prompts and bot messages on request of an administrator
+func (h *Handler) handleReload(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+
+	// reload logs the details of a failure
+	if err := h.reload("command"); err != nil {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ReloadFailedMessage)
+		return
+	}
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).ReloadSuccessMessage, h.personaLibrary().Len()))
+}
+
+// watchReloads reloads on SIGHUP and when the config file or prompt files change
+func (b *Bot) watchReloads(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changes := b.watchFiles(ctx, newWatchSet(config.FileUsed(), b.config.AI.PromptFile, b.config.AI.PromptsDir, b.config.Bot.LocalesDir))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = b.handler.reload("SIGHUP")
+		case <-changes:
+			_ = b.handler.reload("file change")
+		}
+	}
+}
+
+// watchFiles reports changes of the watched files, debounced; it returns a nil
+// channel when there is nothing to watch or the watcher cannot start
+func (b *Bot) watchFiles(ctx context.Context, set *watchSet) <-chan struct{} {
+	dirs := set.watchDirs()
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		b.logger.Warn("failed to start file watcher, use SIGHUP or /reload to reload", zap.Error(err))
+		return nil
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			b.logger.Warn("failed to watch directory", zap.String("dir", dir), zap.Error(err))
+		}
+	}
+	b.logger.Info("watching files for changes", zap.Strings("dirs", dirs))
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+
+		timer := time.NewTimer(reloadDebounce)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) || !set.matches(event.Name) {
+					continue
+				}
+				timer.Reset(reloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				b.logger.Warn("file watcher error", zap.Error(err))
+			case <-timer.C:
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes
+}
+
+// watchedExtensions are the prompt and catalog files watched in directories
+var watchedExtensions = []string{".txt", ".yaml", ".yml", ".json"}
+
+// watchSet decides which file system events affect the configuration; files
+// are watched through their directory so that editors replacing them are noticed
+type watchSet struct {
+	files map[string]bool
+	// dirs are directories whose prompt and catalog files are all watched
+	dirs map[string]bool
+}
+
+// newWatchSet watches the config file, the prompt file and the prompts and
+// locales directories; empty paths are skipped
+func newWatchSet(configFile, promptFile string, dirs ...string) *watchSet {
+	set := &watchSet{files: make(map[string]bool), dirs: make(map[string]bool)}
+	for _, file := range []string{configFile, promptFile} {
+		if file != "" {
+			set.files[absPath(file)] = true
+		}
+	}
+	for _, dir := range dirs {
+		if dir != "" {
+			set.dirs[absPath(dir)] = true
+		}
+	}
+	return set
+}
+
+// watchDirs returns the directories to watch, sorted and without duplicates
+func (s *watchSet) watchDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for file := range s.files {
+		add(filepath.Dir(file))
+	}
+	for dir := range s.dirs {
+		add(dir)
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// matches reports whether a changed path is a watched file or a prompt or
+// catalog file in a watched directory
+func (s *watchSet) matches(name string) bool {
+	path := absPath(name)
+	if s.files[path] {
+		return true
+	}
+	return s.dirs[filepath.Dir(path)] && slices.Contains(watchedExtensions, filepath.Ext(path))
+}
+
+// absPath returns the cleaned absolute form of a path, or the cleaned path
+// itself if the working directory is unknown
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
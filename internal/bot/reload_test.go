@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchSet(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "configs", "config.yaml")
+	promptFile := filepath.Join(dir, "prompts", "main.txt")
+	promptsDir := filepath.Join(dir, "personas")
+	localesDir := filepath.Join(dir, "locales")
+
+	set := newWatchSet(configFile, promptFile, promptsDir, localesDir, "")
+
+	dirs := set.watchDirs()
+	want := []string{filepath.Join(dir, "configs"), localesDir, promptsDir, filepath.Join(dir, "prompts")}
+	if len(dirs) != len(want) {
+		t.Fatalf("watchDirs() = %v, want %v", dirs, want)
+	}
+	for i := range want {
+		if dirs[i] != want[i] {
+			t.Errorf("watchDirs()[%d] = %s, want %s", i, dirs[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{name: "Config file", path: configFile, expected: true},
+		{name: "Other file next to config", path: filepath.Join(dir, "configs", "config.yaml.swp"), expected: false},
+		{name: "Prompt file", path: promptFile, expected: true},
+		{name: "Other prompt next to prompt file", path: filepath.Join(dir, "prompts", "other.txt"), expected: false},
+		{name: "Persona prompt", path: filepath.Join(promptsDir, "support.txt"), expected: true},
+		{name: "Persona welcome", path: filepath.Join(promptsDir, "support.welcome.txt"), expected: true},
+		{name: "Editor backup in prompts directory", path: filepath.Join(promptsDir, "support.txt~"), expected: false},
+		{name: "Catalog", path: filepath.Join(localesDir, "ru.yaml"), expected: true},
+		{name: "JSON catalog", path: filepath.Join(localesDir, "pt-br.json"), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.matches(tt.path); got != tt.expected {
+				t.Errorf("matches(%s) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWatchSet_Empty(t *testing.T) {
+	if dirs := newWatchSet("", "", "", "").watchDirs(); len(dirs) != 0 {
+		t.Errorf("watchDirs() = %v, want none", dirs)
+	}
+}
@@ -0,0 +1,521 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/prompt"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// reminderCallbackPrefix starts the callback data of buttons canceling a reminder
+const reminderCallbackPrefix = "reminder:"
+
+// reminderMaxAttempts limits deliveries of a reminder Telegram keeps rejecting
+const reminderMaxAttempts = 3
+
+// reminderTimeLayout shows the due time of a reminder
+const reminderTimeLayout = "2006-01-02 15:04"
+
+// reminderButtonLength is the number of characters of a reminder shown on its cancel button
+const reminderButtonLength = 32
+
+// maxReminderUnits bounds the numbers of durations like "2h", so they cannot overflow
+const maxReminderUnits = 100000
+
+// reminderPrompt asks the model to extract a reminder from a message
+const reminderPrompt = `You extract reminders from messages sent to a reminder bot.
+Answer with JSON only. "due" is the local date and time to send the reminder, formatted as YYYY-MM-DDTHH:MM. "text" is what to remind about, in the language of the message, without the time and without words like "remind me".
+Resolve relative times such as "tomorrow", "in two hours" or "on Friday" against the current local time given below. When only an hour is given, choose its next occurrence. When the message does not say when to remind, set "due" to an empty string.`
+
+// reminderSchema is the JSON Schema of reminders extracted by the model
+var reminderSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "due": {"type": "string", "description": "Local date and time as YYYY-MM-DDTHH:MM, or empty"},
+    "text": {"type": "string", "description": "What to remind about"}
+  },
+  "required": ["due", "text"],
+  "additionalProperties": false
+}`)
+
+// reminderDueLayouts are the formats of due times accepted from the model
+var reminderDueLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// Patterns of the times /remind understands without asking the AI
+var (
+	reminderDuration     = regexp.MustCompile(`^(?:\d+(?:w|d|h|min|m))+$`)
+	reminderDurationPart = regexp.MustCompile(`(\d+)(w|d|h|min|m)`)
+	reminderClock        = regexp.MustCompile(`^([01]?\d|2[0-3]):([0-5]\d)$`)
+)
+
+// errNoReminderTime is returned when a message does not say when to remind
+var errNoReminderTime = errors.New("message has no reminder time")
+
+// parseReminderTime reads a reminder written as "<when> <text>", where when is a
+// duration like 2h, 1h30m, 3d or 1w, optionally after "in", a time like 18:30,
+// or a date and time like 2024-05-01 18:30. It reports false for other times,
+// which are left to the AI
+func parseReminderTime(text string, now time.Time) (time.Time, string, bool) {
+	fields := strings.Fields(text)
+	skip := 0
+	if len(fields) > 1 && strings.EqualFold(fields[0], "in") {
+		skip = 1
+	}
+	if len(fields) <= skip {
+		return time.Time{}, "", false
+	}
+	when := strings.ToLower(fields[skip])
+
+	switch {
+	case reminderDuration.MatchString(when):
+		due := now
+		for _, part := range reminderDurationPart.FindAllStringSubmatch(when, -1) {
+			n, err := strconv.Atoi(part[1])
+			if err != nil || n > maxReminderUnits {
+				return time.Time{}, "", false
+			}
+			switch part[2] {
+			case "w":
+				due = due.AddDate(0, 0, 7*n)
+			case "d":
+				due = due.AddDate(0, 0, n)
+			case "h":
+				due = due.Add(time.Duration(n) * time.Hour)
+			default:
+				due = due.Add(time.Duration(n) * time.Minute)
+			}
+		}
+		return due, cutFields(text, skip+1), true
+
+	case skip == 0 && reminderClock.MatchString(when):
+		due := clockTime(now, when)
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		return due, cutFields(text, 1), true
+
+	case skip == 0 && len(fields) > 1 && reminderClock.MatchString(fields[1]):
+		day, err := time.ParseInLocation("2006-01-02", when, now.Location())
+		if err != nil {
+			return time.Time{}, "", false
+		}
+		return clockTime(day, fields[1]), cutFields(text, 2), true
+	}
+	return time.Time{}, "", false
+}
+
+// clockTime returns the time "15:04" on the day of t
+func clockTime(t time.Time, clock string) time.Time {
+	match := reminderClock.FindStringSubmatch(clock)
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	return time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+}
+
+// cutFields removes the first n whitespace-separated fields of text, keeping
+// the formatting of the rest
+func cutFields(text string, n int) string {
+	text = strings.TrimSpace(text)
+	for i := 0; i < n; i++ {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+		text = strings.TrimSpace(text[end:])
+	}
+	return text
+}
+
+// cutReminderTrigger reports whether a message starts with a trigger like
+// "remind me" and returns the rest of it
+func cutReminderTrigger(text string, triggers []string) (string, bool) {
+	text = strings.TrimSpace(text)
+	for _, trigger := range triggers {
+		trigger = strings.TrimSpace(trigger)
+		n := len(trigger)
+		if trigger == "" || len(text) < n || !strings.EqualFold(text[:n], trigger) {
+			continue
+		}
+		rest := text[n:]
+		if r, _ := utf8.DecodeRuneInString(rest); rest != "" && !unicode.IsSpace(r) && !unicode.IsPunct(r) {
+			continue
+		}
+		return strings.TrimLeftFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }), true
+	}
+	return "", false
+}
+
+// userLocation returns the timezone a user chose with /timezone, or BOT_TIMEZONE
+func (h *Handler) userLocation(ctx context.Context, userID int64) *time.Location {
+	name := h.botConfig(ctx).Timezone
+	if settings := h.userSettings(ctx, userID); settings != nil && settings.Timezone != "" {
+		name = settings.Timezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		h.log(ctx).Warn("invalid timezone", zap.String("timezone", name), zap.Error(err))
+		return time.UTC
+	}
+	return location
+}
+
+// handleRemind sets a reminder with /remind <when> <text>
+func (h *Handler) handleRemind(ctx context.Context, call *commandCall) {
+	if call.argText == "" {
+		h.sendMessage(ctx, call.message.Chat.ID, h.botConfig(ctx).ReminderSyntaxMessage)
+		return
+	}
+	h.setReminder(ctx, call.message, call.argText, call.argText)
+}
+
+// setReminder stores the reminder a message asks for. spec is tried with
+// parseReminderTime first; otherwise the AI reads the time from request
+func (h *Handler) setReminder(ctx context.Context, message *tgbotapi.Message, request, spec string) {
+	chatID, userID := message.Chat.ID, message.From.ID
+	location := h.userLocation(ctx, userID)
+	now := time.Now().In(location)
+
+	due, text, ok := parseReminderTime(spec, now)
+	if !ok {
+		period := time.Now().UTC().Format("2006-01-02")
+		if h.quotaExceeded(ctx, userID, period) {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).QuotaExceededMessage)
+			return
+		}
+		h.sendTyping(ctx, chatID)
+
+		var err error
+		due, text, err = h.extractReminder(ctx, message, request, now, period)
+		if errors.Is(err, errNoReminderTime) {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ReminderTimeMessage)
+			return
+		}
+		if err != nil {
+			h.log(ctx).Error("failed to extract reminder", zap.Error(err))
+			h.incrementCounter(ctx, counterAIErrors)
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+	}
+
+	if text == "" {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ReminderSyntaxMessage)
+		return
+	}
+	if !due.After(now) {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ReminderPastMessage)
+		return
+	}
+	if limit := h.botConfig(ctx).MaxReminders; limit > 0 {
+		pending, err := h.store.ListReminders(ctx, userID)
+		if err != nil {
+			h.log(ctx).Error("failed to load reminders", zap.Error(err))
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+		if len(pending) >= limit {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ReminderLimitMessage)
+			return
+		}
+	}
+
+	reminder := &storage.Reminder{
+		ChatID:    chatID,
+		UserID:    userID,
+		Text:      text,
+		DueAt:     due.UTC(),
+		MessageID: message.MessageID,
+		CreatedAt: time.Now(),
+	}
+	if err := h.store.SaveReminder(ctx, reminder); err != nil {
+		h.log(ctx).Error("failed to save reminder", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	h.log(ctx).Info("set reminder",
+		zap.Int64("reminder_id", reminder.ID),
+		zap.Int64("user_id", userID),
+		zap.Time("due_at", reminder.DueAt),
+	)
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).ReminderSetMessage, formatDue(due, location), text))
+}
+
+// extractReminder asks the AI when and what to remind about, with the user's
+// local time now, and accounts the request towards the user's quota
+func (h *Handler) extractReminder(ctx context.Context, message *tgbotapi.Message, request string, now time.Time, period string) (time.Time, string, error) {
+	tmpl, err := prompt.Compile("reminder", reminderPrompt, nil)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	temperature := 0.0
+	completion, err := h.aiService.Complete(ctx, ai.Request{
+		Prompt:         tmpl,
+		Instructions:   fmt.Sprintf("Current local time: %s, %s (%s).", now.Format("2006-01-02T15:04"), now.Weekday(), now.Location()),
+		Temperature:    &temperature,
+		MaxTokens:      200,
+		Examples:       []ai.Message{},
+		Message:        request,
+		ResponseFormat: ai.JSONSchemaFormat("reminder", reminderSchema),
+	})
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	h.accountCompletion(ctx, message.Chat.ID, message.From.ID, period, completion)
+
+	var extracted struct {
+		Due  string `json:"due"`
+		Text string `json:"text"`
+	}
+	if err := ai.DecodeJSON(completion.Content, &extracted); err != nil {
+		return time.Time{}, "", err
+	}
+	if extracted.Due == "" {
+		return time.Time{}, "", errNoReminderTime
+	}
+	due, err := parseDue(extracted.Due, now.Location())
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return due, strings.TrimSpace(extracted.Text), nil
+}
+
+// parseDue reads a due time returned by the AI in the user's timezone
+func parseDue(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(location), nil
+	}
+	for _, layout := range reminderDueLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid reminder time %q", value)
+}
+
+// formatDue shows a due time in the user's timezone
+func formatDue(due time.Time, location *time.Location) string {
+	return fmt.Sprintf("%s (%s)", due.In(location).Format(reminderTimeLayout), location)
+}
+
+// handleReminders lists the user's reminders with a button to cancel each
+func (h *Handler) handleReminders(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+	text, markup, err := h.reminderList(ctx, call.message.Chat, call.message.From.ID)
+	if err != nil {
+		h.log(ctx).Error("failed to load reminders", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	if _, err := h.deliver(ctx, msg); err != nil {
+		h.log(ctx).Error("failed to send reminders", zap.Error(err))
+	}
+}
+
+// reminderList renders the reminders of a user with cancel buttons. Group chats
+// only show the reminders set in them, so private ones stay private
+func (h *Handler) reminderList(ctx context.Context, chat *tgbotapi.Chat, userID int64) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	reminders, err := h.store.ListReminders(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	location := h.userLocation(ctx, userID)
+
+	var lines []string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, reminder := range reminders {
+		if !chat.IsPrivate() && reminder.ChatID != chat.ID {
+			continue
+		}
+		n := len(lines) + 1
+		lines = append(lines, fmt.Sprintf("%d. %s — %s", n, reminder.DueAt.In(location).Format(reminderTimeLayout), reminder.Text))
+		label := fmt.Sprintf("❌ %d. %s", n, truncate(reminder.Text, reminderButtonLength))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, reminderCallbackPrefix+strconv.FormatInt(reminder.ID, 10)),
+		))
+	}
+	if len(lines) == 0 {
+		return h.botConfig(ctx).ReminderEmptyMessage, nil, nil
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return fmt.Sprintf(h.botConfig(ctx).ReminderListMessage, strings.Join(lines, "\n")), &markup, nil
+}
+
+// handleReminderCallback cancels the reminder of a button and updates the list
+func (h *Handler) handleReminderCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data string) {
+	id, err := strconv.ParseInt(data, 10, 64)
+	if err != nil || query.Message == nil {
+		h.answerCallback(ctx, query.ID, "")
+		return
+	}
+
+	// Only the owner may cancel; a reminder already sent or canceled just
+	// refreshes the list
+	reminder, err := h.store.GetReminder(ctx, id)
+	switch {
+	case err == nil && reminder.UserID != query.From.ID:
+		h.answerCallback(ctx, query.ID, "")
+		return
+	case err == nil:
+		if err := h.store.DeleteReminder(ctx, id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			h.log(ctx).Error("failed to cancel reminder", zap.Int64("reminder_id", id), zap.Error(err))
+			h.answerCallback(ctx, query.ID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+		h.log(ctx).Info("canceled reminder", zap.Int64("reminder_id", id), zap.Int64("user_id", query.From.ID))
+	case !errors.Is(err, storage.ErrNotFound):
+		h.log(ctx).Error("failed to load reminder", zap.Int64("reminder_id", id), zap.Error(err))
+		h.answerCallback(ctx, query.ID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	h.answerCallback(ctx, query.ID, h.botConfig(ctx).ReminderCanceledMessage)
+
+	text, markup, err := h.reminderList(ctx, query.Message.Chat, query.From.ID)
+	if err != nil {
+		h.log(ctx).Error("failed to load reminders", zap.Error(err))
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.ReplyMarkup = markup
+	span := startTelegramSpan(ctx, "editMessageText", query.Message.Chat.ID)
+	_, err = h.bot.Request(edit)
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Warn("failed to update reminder list", zap.Error(err))
+	}
+}
+
+// handleTimezone shows the user's timezone, or sets it with /timezone <name>
+func (h *Handler) handleTimezone(ctx context.Context, call *commandCall) {
+	chatID, user := call.message.Chat.ID, call.message.From
+
+	name := strings.TrimSpace(call.argText)
+	if name == "" {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).TimezoneMessage, h.userLocation(ctx, user.ID)))
+		return
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).TimezoneUnknownMessage)
+		return
+	}
+	_, err = h.saveUserSettings(ctx, user, func(settings *storage.UserSettings) {
+		settings.Timezone = location.String()
+	})
+	if err != nil {
+		h.log(ctx).Error("failed to save user timezone", zap.Int64("user_id", user.ID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	h.log(ctx).Info("switched timezone", zap.Int64("user_id", user.ID), zap.String("timezone", location.String()))
+	label := fmt.Sprintf("%s (%s)", location, time.Now().In(location).Format("15:04"))
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).TimezoneSwitchedMessage, label))
+}
+
+// deliverReminders sends the reminders due at now and returns how many were sent
+func (h *Handler) deliverReminders(ctx context.Context, now time.Time) int {
+	due, err := h.store.DueReminders(ctx, now)
+	if err != nil {
+		h.log(ctx).Error("failed to load due reminders", zap.Error(err))
+		return 0
+	}
+
+	sent := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if h.deliverReminder(ctx, &due[i]) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// deliverReminder sends a reminder in its user's language, replying to the
+// message that set it, and removes it once sent. Failed reminders are retried
+// on the next run, up to reminderMaxAttempts times
+func (h *Handler) deliverReminder(ctx context.Context, reminder *storage.Reminder) bool {
+	ctx = h.userContext(ctx, reminder.UserID)
+	log := h.log(ctx).With(zap.Int64("reminder_id", reminder.ID), zap.Int64("chat_id", reminder.ChatID))
+
+	msg := tgbotapi.NewMessage(reminder.ChatID, fmt.Sprintf(h.botConfig(ctx).ReminderMessage, reminder.Text))
+	msg.ReplyToMessageID = reminder.MessageID
+	msg.AllowSendingWithoutReply = true
+	_, err := h.deliver(ctx, msg)
+
+	result := "delivered"
+	switch {
+	case err == nil:
+		log.Info("delivered reminder")
+	case isBlockedError(err):
+		result = "blocked"
+		log.Info("reminder chat blocked the bot", zap.Error(err))
+		if err := h.store.SetChatBlocked(ctx, reminder.ChatID, true); err != nil {
+			log.Warn("failed to mark chat as blocked", zap.Error(err))
+		}
+	default:
+		reminder.Attempts++
+		if reminder.Attempts < reminderMaxAttempts {
+			log.Warn("failed to deliver reminder, will retry", zap.Int("attempts", reminder.Attempts), zap.Error(err))
+			if err := h.store.SaveReminder(ctx, reminder); err != nil {
+				log.Error("failed to save reminder", zap.Error(err))
+			}
+			return false
+		}
+		result = "failed"
+		log.Error("giving up on reminder", zap.Int("attempts", reminder.Attempts), zap.Error(err))
+	}
+
+	metrics.Reminders.WithLabelValues(result).Inc()
+	if err := h.store.DeleteReminder(ctx, reminder.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Error("failed to delete reminder", zap.Error(err))
+	}
+	return result == "delivered"
+}
+
+// runReminders delivers due reminders every BOT_REMINDER_INTERVAL until ctx is cancelled
+func (b *Bot) runReminders(ctx context.Context) {
+	interval := b.config.Bot.ReminderInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	b.logger.Info("starting reminder scheduler", zap.Duration("interval", interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		b.handler.deliverReminders(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func TestParseReminderTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	now := time.Date(2026, 10, 18, 14, 5, 0, 0, berlin)
+
+	tests := []struct {
+		name     string
+		text     string
+		expected time.Time
+		rest     string
+		wantOK   bool
+	}{
+		{name: "Hours", text: "2h call Bob", expected: now.Add(2 * time.Hour), rest: "call Bob", wantOK: true},
+		{name: "Combined duration", text: "1h30m  stretch", expected: now.Add(90 * time.Minute), rest: "stretch", wantOK: true},
+		{name: "Minutes with in", text: "in 15min tea is ready", expected: now.Add(15 * time.Minute), rest: "tea is ready", wantOK: true},
+		{name: "Days keep the wall clock", text: "14d renew the passport", expected: time.Date(2026, 11, 1, 14, 5, 0, 0, berlin), rest: "renew the passport", wantOK: true},
+		{name: "Week", text: "1w water the plants", expected: time.Date(2026, 10, 25, 14, 5, 0, 0, berlin), rest: "water the plants", wantOK: true},
+		{name: "Clock later today", text: "18:30 take the pills", expected: time.Date(2026, 10, 18, 18, 30, 0, 0, berlin), rest: "take the pills", wantOK: true},
+		{name: "Clock tomorrow", text: "9:00 stand-up", expected: time.Date(2026, 10, 19, 9, 0, 0, 0, berlin), rest: "stand-up", wantOK: true},
+		{name: "Date and time", text: "2026-12-31 23:59 party\n*bring snacks*", expected: time.Date(2026, 12, 31, 23, 59, 0, 0, berlin), rest: "party\n*bring snacks*", wantOK: true},
+		{name: "Duration without text", text: "2h", expected: now.Add(2 * time.Hour), rest: "", wantOK: true},
+		{name: "Words are left to the AI", text: "tomorrow at 9 call Bob"},
+		{name: "In alone", text: "in"},
+		{name: "Invalid clock", text: "25:00 sleep"},
+		{name: "Invalid date", text: "2026-02-30 10:00 nothing"},
+		{name: "Huge duration", text: "99999999999h never"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, ok := parseReminderTime(tt.text, now)
+			if ok != tt.wantOK {
+				t.Fatalf("parseReminderTime() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !got.Equal(tt.expected) || rest != tt.rest {
+				t.Errorf("parseReminderTime() = %v, %q, want %v, %q", got, rest, tt.expected, tt.rest)
+			}
+		})
+	}
+}
+
+func TestCutReminderTrigger(t *testing.T) {
+	triggers := []string{"remind me", "напомни"}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		wantOK   bool
+	}{
+		{name: "English", text: "Remind me tomorrow at 9 to call Bob", expected: "tomorrow at 9 to call Bob", wantOK: true},
+		{name: "Russian with punctuation", text: "Напомни, пожалуйста, завтра в 9", expected: "пожалуйста, завтра в 9", wantOK: true},
+		{name: "Trigger only", text: "remind me", expected: "", wantOK: true},
+		{name: "Longer word", text: "reminder: buy milk"},
+		{name: "Not at the start", text: "can you remind me later?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cutReminderTrigger(tt.text, triggers)
+			if got != tt.expected || ok != tt.wantOK {
+				t.Errorf("cutReminderTrigger() = %q, %v, want %q, %v", got, ok, tt.expected, tt.wantOK)
+			}
+		})
+	}
+}
+
+// newReminderHandler returns a handler talking to a fake Bot API server
+func newReminderHandler(t *testing.T) (*Handler, *fakeTelegram) {
+	t.Helper()
+	fake, api := newFakeTelegram(t)
+	cfg := &config.Config{Bot: config.BotConfig{
+		Timezone:                "UTC",
+		MaxReminders:            2,
+		ReminderSyntaxMessage:   "syntax",
+		ReminderSetMessage:      "set %s: %s",
+		ReminderPastMessage:     "past",
+		ReminderLimitMessage:    "limit",
+		ReminderListMessage:     "list:\n%s",
+		ReminderEmptyMessage:    "empty",
+		ReminderCanceledMessage: "canceled",
+		ReminderMessage:         "reminder: %s",
+		TimezoneSwitchedMessage: "timezone %s",
+		TimezoneUnknownMessage:  "unknown timezone",
+	}}
+	// aiService is nil: only times parseReminderTime understands can be used
+	handler := &Handler{config: cfg, logger: zap.NewNop(), bot: api, store: storage.NewMemoryStore()}
+	handler.botSettings.Store(&cfg.Bot)
+	return handler, fake
+}
+
+func TestReminders(t *testing.T) {
+	handler, fake := newReminderHandler(t)
+	ctx := context.Background()
+	user := &tgbotapi.User{ID: 10, FirstName: "Jane"}
+	chat := &tgbotapi.Chat{ID: 10, Type: "private"}
+	command := func(text string) *commandCall {
+		return &commandCall{message: &tgbotapi.Message{MessageID: 5, From: user, Chat: chat, Text: text}, argText: text}
+	}
+
+	handler.handleRemind(ctx, command("2h call Bob"))
+	handler.handleRemind(ctx, command("1h"))
+	handler.handleRemind(ctx, command("2026-01-01 10:00 too late"))
+	handler.handleRemind(ctx, command("3h water the plants"))
+	handler.handleRemind(ctx, command("4h one too many"))
+
+	var replies []string
+	for _, call := range fake.take() {
+		replies = append(replies, call.params["text"])
+	}
+	if len(replies) != 5 || replies[1] != "syntax" || replies[2] != "past" || replies[4] != "limit" {
+		t.Fatalf("/remind replies = %q, want set, syntax, past, set and limit", replies)
+	}
+
+	reminders, err := handler.store.ListReminders(ctx, user.ID)
+	if err != nil || len(reminders) != 2 || reminders[0].Text != "call Bob" || reminders[0].MessageID != 5 {
+		t.Fatalf("ListReminders() = %+v, %v, want call Bob and water the plants", reminders, err)
+	}
+
+	// Canceling through the /reminders button updates the list
+	query := &tgbotapi.CallbackQuery{
+		ID:      "q",
+		From:    user,
+		Message: &tgbotapi.Message{MessageID: 51, Chat: chat},
+	}
+	handler.handleReminderCallback(ctx, query, strconv.FormatInt(reminders[0].ID, 10))
+	calls := fake.take()
+	if methods(calls) != "answerCallbackQuery,editMessageText" || calls[0].params["text"] != "canceled" {
+		t.Fatalf("reminder callback made requests %+v", calls)
+	}
+	if _, err := handler.store.GetReminder(ctx, reminders[0].ID); err != storage.ErrNotFound {
+		t.Errorf("GetReminder() of a canceled reminder error = %v, want ErrNotFound", err)
+	}
+
+	// Other users cannot cancel the reminder
+	query.From = &tgbotapi.User{ID: 11}
+	handler.handleReminderCallback(ctx, query, strconv.FormatInt(reminders[1].ID, 10))
+	if _, err := handler.store.GetReminder(ctx, reminders[1].ID); err != nil {
+		t.Errorf("GetReminder() after another user's press error = %v", err)
+	}
+	fake.take()
+
+	// The timezone applies to the next reminders
+	handler.handleTimezone(ctx, command("Mars/Olympus"))
+	handler.handleTimezone(ctx, command("Asia/Tokyo"))
+	calls = fake.take()
+	if len(calls) != 2 || calls[0].params["text"] != "unknown timezone" {
+		t.Fatalf("/timezone made requests %+v", calls)
+	}
+	if got := handler.userLocation(ctx, user.ID).String(); got != "Asia/Tokyo" {
+		t.Errorf("userLocation() = %q, want Asia/Tokyo", got)
+	}
+}
+
+func TestDeliverReminders(t *testing.T) {
+	handler, fake := newReminderHandler(t)
+	fake.blocked = map[string]bool{"20": true}
+	ctx := context.Background()
+	now := time.Now()
+	if err := handler.store.TouchChat(ctx, storage.ChatInfo{ChatID: 20, Type: "private", FirstSeen: now, LastSeen: now}); err != nil {
+		t.Fatalf("TouchChat() error = %v", err)
+	}
+
+	for _, reminder := range []*storage.Reminder{
+		{ChatID: 10, UserID: 10, Text: "call Bob", DueAt: now.Add(-time.Minute), MessageID: 5},
+		{ChatID: 20, UserID: 20, Text: "blocked", DueAt: now.Add(-time.Minute)},
+		{ChatID: 10, UserID: 10, Text: "later", DueAt: now.Add(time.Hour)},
+	} {
+		if err := handler.store.SaveReminder(ctx, reminder); err != nil {
+			t.Fatalf("SaveReminder() error = %v", err)
+		}
+	}
+
+	if sent := handler.deliverReminders(ctx, now); sent != 1 {
+		t.Errorf("deliverReminders() = %d, want 1", sent)
+	}
+	calls := fake.take()
+	if methods(calls) != "sendMessage,sendMessage" {
+		t.Fatalf("deliverReminders() made requests %+v", calls)
+	}
+	if calls[0].params["text"] != "reminder: call Bob" || calls[0].params["reply_to_message_id"] != "5" {
+		t.Errorf("deliverReminders() sent %+v, want a reply to the /remind message", calls[0].params)
+	}
+
+	// Sent reminders and reminders of blocked chats are removed
+	due, err := handler.store.DueReminders(ctx, now.Add(2*time.Hour))
+	if err != nil || len(due) != 1 || due[0].Text != "later" {
+		t.Errorf("DueReminders() = %+v, %v, want only the later reminder", due, err)
+	}
+	if chat, err := handler.store.GetChat(ctx, 20); err != nil || !chat.Blocked {
+		t.Errorf("GetChat() = %+v, %v, want a blocked chat", chat, err)
+	}
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// handleForget erases all data stored about the user who sent the command
+func (h *Handler) handleForget(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+	userID := call.message.From.ID
+
+	if err := h.store.DeleteUserData(ctx, userID); err != nil {
+		h.log(ctx).Error("failed to delete user data", zap.Int64("user_id", userID), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+
+	h.log(ctx).Info("deleted user data", zap.Int64("user_id", userID))
+	h.sendMessage(ctx, chatID, h.botConfig(ctx).ForgetMessage)
+}
+
+// runJanitor periodically purges data older than the configured retention period
+func (b *Bot) runJanitor(ctx context.Context) {
+	retention := time.Duration(b.config.Storage.RetentionDays) * 24 * time.Hour
+	interval := b.config.Storage.JanitorInterval
+	if retention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	b.logger.Info("starting retention janitor",
+		zap.Int("retention_days", b.config.Storage.RetentionDays),
+		zap.Duration("interval", interval),
+	)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		b.purgeExpired(ctx, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpired removes stored data older than retention
+func (b *Bot) purgeExpired(ctx context.Context, retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+	removed, err := b.store.PurgeBefore(ctx, cutoff)
+	if err != nil {
+		b.logger.Error("retention purge failed", zap.Error(err))
+		return
+	}
+	if removed > 0 {
+		b.logger.Info("purged expired messages",
+			zap.Int("messages", removed),
+			zap.Time("cutoff", cutoff),
+		)
+	}
+}
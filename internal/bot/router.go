@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"tgbot-skeleton/internal/metrics"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// commandRateWindow is the period BOT_COMMAND_RATE_LIMIT applies to
+const commandRateWindow = time.Minute
+
+// commandNamePattern matches the command names Telegram accepts in menus
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// commandFunc runs a command
+type commandFunc func(ctx context.Context, call *commandCall)
+
+// commandMiddleware wraps a command, e.g. to check permissions before running it
+type commandMiddleware func(next commandFunc) commandFunc
+
+// commandCall is one invocation of a command
+type commandCall struct {
+	message *tgbotapi.Message
+	command *command
+	// name is the command as typed, which may be an alias
+	name string
+	// argText is the text after the command
+	argText string
+	// args are the arguments split on whitespace; quotes keep words together
+	args []string
+}
+
+// newCommandCall parses the arguments of a command message
+func newCommandCall(message *tgbotapi.Message, c *command, name string) *commandCall {
+	argText := strings.TrimSpace(message.CommandArguments())
+	return &commandCall{
+		message: message,
+		command: c,
+		name:    name,
+		argText: argText,
+		args:    parseArgs(argText),
+	}
+}
+
+// arg returns the i-th argument, or "" when there are fewer arguments
+func (c *commandCall) arg(i int) string {
+	if i < len(c.args) {
+		return c.args[i]
+	}
+	return ""
+}
+
+// commandRouter dispatches commands by name or alias through middleware
+type commandRouter struct {
+	// commands are in menu order
+	commands []*command
+	// byName indexes commands by name and aliases
+	byName map[string]*command
+	// middleware runs for every command, outermost first
+	middleware []commandMiddleware
+}
+
+// newCommandRouter creates a router running middleware for every command
+func newCommandRouter(middleware ...commandMiddleware) *commandRouter {
+	return &commandRouter{byName: make(map[string]*command), middleware: middleware}
+}
+
+// register adds commands; names and aliases must be valid Telegram command
+// names and unique
+func (r *commandRouter) register(commands ...*command) error {
+	for _, c := range commands {
+		if c.handler == nil {
+			return fmt.Errorf("command /%s has no handler", c.name)
+		}
+		for _, name := range append([]string{c.name}, c.aliases...) {
+			if !commandNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid command name %q: use 1-32 lowercase letters, digits or underscores", name)
+			}
+			if _, ok := r.byName[name]; ok {
+				return fmt.Errorf("command /%s is registered twice", name)
+			}
+		}
+		for _, name := range append([]string{c.name}, c.aliases...) {
+			r.byName[name] = c
+		}
+		r.commands = append(r.commands, c)
+	}
+	return nil
+}
+
+// mustRegister registers built-in commands, which are known to be valid
+func (r *commandRouter) mustRegister(commands ...*command) *commandRouter {
+	if err := r.register(commands...); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// lookup returns the command with the given name or alias
+func (r *commandRouter) lookup(name string) (*command, bool) {
+	if r == nil {
+		return nil, false
+	}
+	c, ok := r.byName[strings.ToLower(name)]
+	return c, ok
+}
+
+// dispatch runs a command through the router middleware and its own middleware
+func (r *commandRouter) dispatch(ctx context.Context, h *Handler, call *commandCall) {
+	next := func(ctx context.Context, call *commandCall) {
+		call.command.handler(h, ctx, call)
+	}
+	for i := len(call.command.middleware) - 1; i >= 0; i-- {
+		next = call.command.middleware[i](next)
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		next = r.middleware[i](next)
+	}
+	next(ctx, call)
+}
+
+// handleCommand routes a command message; commands addressed to another bot
+// with /command@otherbot are ignored
+func (h *Handler) handleCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !h.addressedToBot(message) {
+		return
+	}
+
+	name := strings.ToLower(message.Command())
+	c, ok := h.commands.lookup(name)
+	if !ok || !c.available(h) {
+		h.log(ctx).Info("unknown command", zap.String("command", name))
+		metrics.CommandsHandled.WithLabelValues("unknown").Inc()
+		h.sendMessage(ctx, message.Chat.ID, h.botConfig(ctx).UnknownCommandMessage)
+		return
+	}
+	h.commands.dispatch(ctx, h, newCommandCall(message, c, name))
+}
+
+// addressedToBot reports whether a command is for this bot: without a mention,
+// or with /command@thisbot
+func (h *Handler) addressedToBot(message *tgbotapi.Message) bool {
+	_, mention, ok := strings.Cut(message.CommandWithAt(), "@")
+	if !ok || h.bot == nil {
+		return true
+	}
+	return strings.EqualFold(mention, h.bot.Self.UserName)
+}
+
+// logCommands logs commands with their duration and counts them in metrics
+func (h *Handler) logCommands(next commandFunc) commandFunc {
+	return func(ctx context.Context, call *commandCall) {
+		metrics.CommandsHandled.WithLabelValues(call.command.name).Inc()
+		start := time.Now()
+		next(ctx, call)
+		h.log(ctx).Info("handled command",
+			zap.String("command", call.command.name),
+			zap.String("alias", call.name),
+			zap.Int64("chat_id", call.message.Chat.ID),
+			zap.Int64("user_id", call.message.From.ID),
+			zap.Duration("duration", time.Since(start)),
+		)
+	}
+}
+
+// authorizeCommands keeps administrative commands to administrators and the
+// rest to BOT_ALLOWED_COMMANDS and the chat persona's commands
+func (h *Handler) authorizeCommands(next commandFunc) commandFunc {
+	return func(ctx context.Context, call *commandCall) {
+		chatID, userID := call.message.Chat.ID, call.message.From.ID
+		if call.command.adminOnly && !h.isAdmin(userID) {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).AdminOnlyMessage)
+			return
+		}
+		if !h.commandAllowed(ctx, chatID, userID, call.command.name) {
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).UnknownCommandMessage)
+			return
+		}
+		next(ctx, call)
+	}
+}
+
+// restrictChatTypes runs commands only in the chat types of their scopes
+func (h *Handler) restrictChatTypes(next commandFunc) commandFunc {
+	return func(ctx context.Context, call *commandCall) {
+		if call.command.scopes&chatScope(call.message.Chat) == 0 {
+			h.sendMessage(ctx, call.message.Chat.ID, h.botConfig(ctx).CommandChatTypeMessage)
+			return
+		}
+		next(ctx, call)
+	}
+}
+
+// limitCommands allows each user BOT_COMMAND_RATE_LIMIT commands per minute;
+// administrators are exempt
+func (h *Handler) limitCommands(limiter *rateLimiter) commandMiddleware {
+	return func(next commandFunc) commandFunc {
+		return func(ctx context.Context, call *commandCall) {
+			userID := call.message.From.ID
+			if !h.isAdmin(userID) && !limiter.allow(userID, h.botConfig(ctx).CommandRateLimit) {
+				h.log(ctx).Warn("command rate limit exceeded",
+					zap.Int64("user_id", userID),
+					zap.String("command", call.command.name),
+				)
+				h.sendMessage(ctx, call.message.Chat.ID, h.botConfig(ctx).CommandRateLimitMessage)
+				return
+			}
+			next(ctx, call)
+		}
+	}
+}
+
+// chatScope returns the command scope of a chat; channels have none
+func chatScope(chat *tgbotapi.Chat) commandScope {
+	switch {
+	case chat.IsPrivate():
+		return scopePrivate
+	case chat.IsGroup(), chat.IsSuperGroup():
+		return scopeGroup
+	default:
+		return 0
+	}
+}
+
+// parseArgs splits command arguments on whitespace. Double or single quotes
+// keep words together, e.g. `"New York" 3` is two arguments; an unterminated
+// quote runs to the end of the text
+func parseArgs(text string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+	for _, r := range text {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
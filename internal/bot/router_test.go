@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+// testBotAPI returns a bot API client whose requests all succeed
+func testBotAPI(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"MyBot"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("test", server.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("NewBotAPIWithAPIEndpoint() error = %v", err)
+	}
+	return api
+}
+
+// commandMessage returns a command message from a user in a chat of the given type
+func commandMessage(text, chatType string, userID int64) *tgbotapi.Message {
+	command := strings.Fields(text)[0]
+	return &tgbotapi.Message{
+		Text:     text,
+		From:     &tgbotapi.User{ID: userID},
+		Chat:     &tgbotapi.Chat{ID: 1, Type: chatType},
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	}
+}
+
+func TestCommandRouter_Register(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []*command
+		wantErr  bool
+	}{
+		{name: "Valid", commands: []*command{{name: "weather", aliases: []string{"w"}, handler: noopCommand}}},
+		{name: "Duplicate name", commands: []*command{{name: "help", handler: noopCommand}}, wantErr: true},
+		{name: "Alias of another command", commands: []*command{{name: "weather", aliases: []string{"sum"}, handler: noopCommand}}, wantErr: true},
+		{name: "Uppercase name", commands: []*command{{name: "Weather", handler: noopCommand}}, wantErr: true},
+		{name: "Too long", commands: []*command{{name: strings.Repeat("a", 33), handler: noopCommand}}, wantErr: true},
+		{name: "No handler", commands: []*command{{name: "weather"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := testCommands().register(tt.commands...); (err != nil) != tt.wantErr {
+				t.Errorf("register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommandRouter_Lookup(t *testing.T) {
+	router := testCommands()
+	for name, expected := range map[string]string{"summary": "summary", "sum": "summary", "SUM": "summary"} {
+		if c, ok := router.lookup(name); !ok || c.name != expected {
+			t.Errorf("lookup(%s) = %v, %v, want %s", name, c, ok, expected)
+		}
+	}
+	if _, ok := router.lookup("weather"); ok {
+		t.Errorf("lookup(weather) found an unknown command")
+	}
+	if _, ok := (*commandRouter)(nil).lookup("help"); ok {
+		t.Errorf("nil router found a command")
+	}
+}
+
+func TestCommandRouter_Dispatch(t *testing.T) {
+	var order []string
+	trace := func(name string) commandMiddleware {
+		return func(next commandFunc) commandFunc {
+			return func(ctx context.Context, call *commandCall) {
+				order = append(order, name)
+				next(ctx, call)
+			}
+		}
+	}
+	stop := func(next commandFunc) commandFunc {
+		return func(ctx context.Context, call *commandCall) { order = append(order, "stop") }
+	}
+
+	router := newCommandRouter(trace("outer"), trace("inner"))
+	router.mustRegister(
+		&command{name: "run", middleware: []commandMiddleware{trace("command")},
+			handler: func(h *Handler, ctx context.Context, call *commandCall) { order = append(order, "handler") }},
+		&command{name: "blocked", middleware: []commandMiddleware{stop}, handler: noopCommand},
+	)
+
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{command: "run", expected: []string{"outer", "inner", "command", "handler"}},
+		{command: "blocked", expected: []string{"outer", "inner", "stop"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			order = nil
+			c, _ := router.lookup(tt.command)
+			router.dispatch(context.Background(), &Handler{}, newCommandCall(commandMessage("/"+tt.command, "private", 1), c, tt.command))
+			if !reflect.DeepEqual(order, tt.expected) {
+				t.Errorf("order = %v, want %v", order, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandler_AddressedToBot(t *testing.T) {
+	handler := &Handler{bot: &tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "MyBot"}}}
+
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{text: "/help", expected: true},
+		{text: "/help@MyBot", expected: true},
+		{text: "/help@mybot", expected: true},
+		{text: "/help@OtherBot", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := handler.addressedToBot(commandMessage(tt.text, "group", 1)); got != tt.expected {
+				t.Errorf("addressedToBot() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewCommandCall(t *testing.T) {
+	c := &command{name: "weather"}
+	call := newCommandCall(commandMessage(`/weather@MyBot  "New York" 3 `, "group", 1), c, "weather")
+	if call.argText != `"New York" 3` {
+		t.Errorf("argText = %q", call.argText)
+	}
+	if call.arg(0) != "New York" || call.arg(1) != "3" || call.arg(2) != "" {
+		t.Errorf("args = %q, want [New York 3]", call.args)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{text: "", expected: nil},
+		{text: "  a   b ", expected: []string{"a", "b"}},
+		{text: `"New York" 3`, expected: []string{"New York", "3"}},
+		{text: `'it''s' x`, expected: []string{"its", "x"}},
+		{text: `say "it's fine"`, expected: []string{"say", "it's fine"}},
+		{text: `"" x`, expected: []string{"", "x"}},
+		{text: `a "unterminated quote`, expected: []string{"a", "unterminated quote"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := parseArgs(tt.text); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseArgs(%q) = %q, want %q", tt.text, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChatScope(t *testing.T) {
+	tests := []struct {
+		chatType string
+		expected commandScope
+	}{
+		{chatType: "private", expected: scopePrivate},
+		{chatType: "group", expected: scopeGroup},
+		{chatType: "supergroup", expected: scopeGroup},
+		{chatType: "channel", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chatType, func(t *testing.T) {
+			if got := chatScope(&tgbotapi.Chat{Type: tt.chatType}); got != tt.expected {
+				t.Errorf("chatScope() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandler_CommandMiddleware(t *testing.T) {
+	cfg := &config.Config{Bot: config.BotConfig{AdminIDs: []int64{99}, CommandRateLimit: 1}}
+
+	tests := []struct {
+		name       string
+		middleware func(h *Handler) commandMiddleware
+		command    *command
+		chatType   string
+		userID     int64
+		calls      int
+		expected   int
+	}{
+		{name: "Admin command by user", middleware: func(h *Handler) commandMiddleware { return h.authorizeCommands },
+			command: &command{name: "stats", adminOnly: true}, chatType: "private", userID: 1, calls: 1, expected: 0},
+		{name: "Admin command by admin", middleware: func(h *Handler) commandMiddleware { return h.authorizeCommands },
+			command: &command{name: "stats", adminOnly: true}, chatType: "private", userID: 99, calls: 1, expected: 1},
+		{name: "Group command in private chat", middleware: func(h *Handler) commandMiddleware { return h.restrictChatTypes },
+			command: &command{name: "summary", scopes: scopeGroup}, chatType: "private", userID: 1, calls: 1, expected: 0},
+		{name: "Group command in supergroup", middleware: func(h *Handler) commandMiddleware { return h.restrictChatTypes },
+			command: &command{name: "summary", scopes: scopeGroup}, chatType: "supergroup", userID: 1, calls: 1, expected: 1},
+		{name: "Rate limited user", middleware: func(h *Handler) commandMiddleware { return h.limitCommands(newRateLimiter(time.Minute)) },
+			command: &command{name: "export"}, chatType: "private", userID: 1, calls: 3, expected: 1},
+		{name: "Admins are not rate limited", middleware: func(h *Handler) commandMiddleware { return h.limitCommands(newRateLimiter(time.Minute)) },
+			command: &command{name: "export"}, chatType: "private", userID: 99, calls: 3, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &Handler{config: cfg, logger: zap.NewNop(), bot: testBotAPI(t)}
+			ran := 0
+			next := tt.middleware(handler)(func(ctx context.Context, call *commandCall) { ran++ })
+			for i := 0; i < tt.calls; i++ {
+				next(context.Background(), newCommandCall(commandMessage("/"+tt.command.name, tt.chatType, tt.userID), tt.command, tt.command.name))
+			}
+			if ran != tt.expected {
+				t.Errorf("command ran %d times, want %d", ran, tt.expected)
+			}
+		})
+	}
+}
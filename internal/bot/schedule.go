@@ -0,0 +1,500 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/metrics"
+	"tgbot-skeleton/internal/persona"
+	"tgbot-skeleton/internal/prompt"
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/tracing"
+	"tgbot-skeleton/internal/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// scheduleTimeout bounds a run of a scheduled job, AI request included
+const scheduleTimeout = 3 * time.Minute
+
+// scheduleParser reads five-field cron expressions and descriptors like @daily or @every 6h
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// scheduledJob is a job of config.yaml or of /schedule
+type scheduledJob struct {
+	// name identifies config.yaml jobs; jobs added with /schedule have an id
+	name      string
+	id        int64
+	chatID    int64
+	spec      string
+	prompt    string
+	persona   string
+	createdBy int64
+}
+
+// label names a job in logs and /schedule list
+func (j scheduledJob) label() string {
+	if j.name != "" {
+		return j.name
+	}
+	return "#" + strconv.FormatInt(j.id, 10)
+}
+
+// scheduledJobs returns the jobs of config.yaml followed by the stored ones
+func scheduledJobs(configured []config.ScheduleJob, stored []storage.Schedule) []scheduledJob {
+	jobs := make([]scheduledJob, 0, len(configured)+len(stored))
+	for _, job := range configured {
+		jobs = append(jobs, scheduledJob{
+			name:    job.Name,
+			chatID:  job.ChatID,
+			spec:    strings.TrimSpace(job.Schedule),
+			prompt:  job.Prompt,
+			persona: job.Persona,
+		})
+	}
+	for _, schedule := range stored {
+		jobs = append(jobs, scheduledJob{
+			id:        schedule.ID,
+			chatID:    schedule.ChatID,
+			spec:      schedule.Spec,
+			prompt:    schedule.Prompt,
+			persona:   schedule.Persona,
+			createdBy: schedule.CreatedBy,
+		})
+	}
+	return jobs
+}
+
+// parseSchedule parses a cron expression in timezone, unless it sets its own
+// with a CRON_TZ= prefix
+func parseSchedule(spec, timezone string) (cron.Schedule, error) {
+	if !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") && timezone != "" {
+		spec = "CRON_TZ=" + timezone + " " + spec
+	}
+	return scheduleParser.Parse(spec)
+}
+
+// scheduleIntervalRuns is how many upcoming runs are checked for the shortest interval
+const scheduleIntervalRuns = 100
+
+// scheduleInterval returns the shortest time between the upcoming runs of a schedule
+func scheduleInterval(schedule cron.Schedule, from time.Time) time.Duration {
+	var shortest time.Duration
+	prev := schedule.Next(from)
+	for i := 0; i < scheduleIntervalRuns && !prev.IsZero(); i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		prev = next
+	}
+	return shortest
+}
+
+// tooFrequent reports whether a schedule runs more often than every minInterval
+func tooFrequent(schedule cron.Schedule, minInterval time.Duration) bool {
+	return minInterval > 0 && scheduleInterval(schedule, time.Now()) < minInterval
+}
+
+// validateSchedules checks the schedules and personas of config.yaml jobs
+func validateSchedules(jobs []config.ScheduleJob, personas *persona.Library, timezone string, minInterval time.Duration) error {
+	for _, job := range jobs {
+		schedule, err := parseSchedule(strings.TrimSpace(job.Schedule), timezone)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q of job %q: %w", job.Schedule, job.Name, err)
+		}
+		if tooFrequent(schedule, minInterval) {
+			return fmt.Errorf("schedule %q of job %q runs more often than every %s", job.Schedule, job.Name, minInterval)
+		}
+		if _, ok := personas.Get(job.Persona); job.Persona != "" && !ok {
+			return fmt.Errorf("unknown persona %q of job %q", job.Persona, job.Name)
+		}
+	}
+	return nil
+}
+
+// cronLogger writes the logs of cron to zap; routine messages are debug logs
+type cronLogger struct {
+	logger *zap.SugaredLogger
+}
+
+// Info implements cron.Logger
+func (l cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Debugw("cron: "+msg, keysAndValues...)
+}
+
+// Error implements cron.Logger
+func (l cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.logger.Errorw("cron: "+msg, append(keysAndValues, "error", err)...)
+}
+
+// newScheduleCron creates the cron runner of scheduled jobs. A job still
+// running when it is due again skips that run
+func newScheduleCron(logger *zap.Logger) *cron.Cron {
+	log := cronLogger{logger: logger.Sugar()}
+	return cron.New(cron.WithLogger(log), cron.WithChain(cron.Recover(log), cron.SkipIfStillRunning(log)))
+}
+
+// syncSchedules replaces the cron entries with the current jobs of config.yaml
+// and storage. It runs at startup, after reloads and after /schedule changes
+func (h *Handler) syncSchedules(ctx context.Context) error {
+	if h.cron == nil {
+		return nil
+	}
+	stored, err := h.store.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load schedules: %w", err)
+	}
+	settings := h.botSettings.Load()
+	jobs := scheduledJobs(settings.Schedules, stored)
+
+	h.scheduleMu.Lock()
+	defer h.scheduleMu.Unlock()
+
+	for _, id := range h.scheduleEntries {
+		h.cron.Remove(id)
+	}
+	h.scheduleEntries = h.scheduleEntries[:0]
+	for _, job := range jobs {
+		schedule, err := parseSchedule(job.spec, settings.Timezone)
+		if err != nil {
+			h.logger.Error("skipping invalid schedule", zap.String("schedule", job.label()), zap.String("spec", job.spec), zap.Error(err))
+			continue
+		}
+		job := job
+		id := h.cron.Schedule(schedule, cron.FuncJob(func() {
+			h.runScheduledJob(h.scheduleContext(), job)
+		}))
+		h.scheduleEntries = append(h.scheduleEntries, id)
+	}
+
+	h.logger.Info("scheduled jobs", zap.Int("count", len(h.scheduleEntries)))
+	return nil
+}
+
+// scheduleContext returns the context scheduled jobs run in, cancelled on shutdown
+func (h *Handler) scheduleContext() context.Context {
+	h.scheduleMu.Lock()
+	defer h.scheduleMu.Unlock()
+	if h.scheduleCtx == nil {
+		return context.Background()
+	}
+	return h.scheduleCtx
+}
+
+// runScheduledJob asks the AI the job's prompt and posts the answer to its chat.
+// The answer is added to the chat's conversation, so members can ask about it
+func (h *Handler) runScheduledJob(ctx context.Context, job scheduledJob) {
+	ctx, cancel := context.WithTimeout(ctx, scheduleTimeout)
+	defer cancel()
+	log := h.log(ctx).With(zap.String("schedule", job.label()), zap.Int64("chat_id", job.chatID))
+
+	chat, err := h.store.GetChat(ctx, job.chatID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Warn("failed to load chat", zap.Error(err))
+	}
+	if chat != nil && chat.Blocked {
+		log.Info("skipping scheduled post to a chat that blocked the bot")
+		metrics.ScheduledPosts.WithLabelValues("blocked").Inc()
+		return
+	}
+
+	completion, err := h.aiService.Complete(ctx, h.scheduleRequest(ctx, job, chat))
+	if err != nil {
+		log.Error("failed to generate scheduled post", zap.Error(err))
+		h.incrementCounter(ctx, counterAIErrors)
+		metrics.ScheduledPosts.WithLabelValues("failed").Inc()
+		return
+	}
+	content, _ := cutHandoffMarker(completion.Content, h.botConfig(ctx).HandoffMarker)
+	if content == "" {
+		log.Warn("AI returned an empty scheduled post")
+		metrics.ScheduledPosts.WithLabelValues("failed").Inc()
+		return
+	}
+
+	sent, err := h.deliver(ctx, tgbotapi.NewMessage(job.chatID, utils.ConvertMarkdownToTelegram(content)))
+	h.recordUsage(ctx, job.chatID, job.createdBy, time.Now().UTC().Format("2006-01-02"), completion)
+	switch {
+	case isBlockedError(err):
+		log.Info("scheduled post chat blocked the bot", zap.Error(err))
+		metrics.ScheduledPosts.WithLabelValues("blocked").Inc()
+		if err := h.store.SetChatBlocked(ctx, job.chatID, true); err != nil {
+			log.Warn("failed to mark chat as blocked", zap.Error(err))
+		}
+		return
+	case err != nil:
+		log.Error("failed to send scheduled post", zap.Error(err))
+		metrics.ScheduledPosts.WithLabelValues("failed").Inc()
+		return
+	}
+
+	err = h.store.AppendMessages(ctx, job.chatID, storage.ConversationMessage{
+		Role:      "assistant",
+		Content:   content,
+		Model:     completion.Model,
+		MessageID: sent.MessageID,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		log.Warn("failed to save conversation", zap.Error(err))
+	}
+	log.Info("sent scheduled post", zap.Int("message_id", sent.MessageID))
+	metrics.ScheduledPosts.WithLabelValues("sent").Inc()
+}
+
+// scheduleRequest builds the AI request of a job with the chat's history, so
+// recurring posts do not repeat themselves, and the job's or chat's persona
+func (h *Handler) scheduleRequest(ctx context.Context, job scheduledJob, chat *storage.ChatInfo) ai.Request {
+	request := ai.Request{
+		History: h.loadHistory(ctx, job.chatID),
+		Message: job.prompt,
+		PromptData: prompt.Data{
+			BotUsername: h.bot.Self.UserName,
+		},
+	}
+	if chat != nil {
+		request.PromptData.ChatTitle = chat.Title
+		request.PromptData.ChatType = chat.Type
+	}
+
+	p, ok := h.personaLibrary().Get(job.persona)
+	if !ok {
+		if job.persona != "" {
+			h.log(ctx).Warn("unknown persona of scheduled job, using the chat's", zap.String("schedule", job.label()), zap.String("persona", job.persona))
+		}
+		p = h.chatPersona(ctx, job.chatID)
+	}
+	if p != nil {
+		applyPersona(&request, p)
+	}
+	return request
+}
+
+// handleSchedule adds, lists and removes scheduled jobs
+func (h *Handler) handleSchedule(ctx context.Context, call *commandCall) {
+	action := strings.ToLower(firstField(call.argText))
+	args := cutFields(call.argText, 1)
+
+	switch action {
+	case "add":
+		h.addSchedule(ctx, call.message, args)
+	case "list":
+		h.listSchedules(ctx, call.message.Chat.ID)
+	case "remove":
+		h.removeSchedule(ctx, call.message.Chat.ID, args)
+	default:
+		h.sendMessage(ctx, call.message.Chat.ID, h.botConfig(ctx).ScheduleSyntaxMessage)
+	}
+}
+
+// firstField returns the first whitespace-separated field of text
+func firstField(text string) string {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// addSchedule stores a job from "/schedule add" and schedules it
+func (h *Handler) addSchedule(ctx context.Context, message *tgbotapi.Message, args string) {
+	chatID := message.Chat.ID
+	bot := h.botConfig(ctx)
+
+	schedule, err := parseScheduleArgs(args, chatID)
+	if err != nil {
+		h.log(ctx).Debug("rejected schedule", zap.Error(err))
+		h.sendMessage(ctx, chatID, bot.ScheduleSyntaxMessage)
+		return
+	}
+	parsed, err := parseSchedule(schedule.Spec, bot.Timezone)
+	if err != nil {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(bot.ScheduleInvalidMessage, schedule.Spec, err))
+		return
+	}
+	if tooFrequent(parsed, bot.ScheduleMinInterval) {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(bot.ScheduleFrequentMessage, bot.ScheduleMinInterval))
+		return
+	}
+	if _, ok := h.personaLibrary().Get(schedule.Persona); schedule.Persona != "" && !ok {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(bot.SchedulePersonaMessage, schedule.Persona))
+		return
+	}
+
+	schedule.CreatedBy = message.From.ID
+	schedule.CreatedAt = time.Now()
+	if err := h.store.SaveSchedule(ctx, schedule); err != nil {
+		h.log(ctx).Error("failed to save schedule", zap.Error(err))
+		h.sendMessage(ctx, chatID, bot.ErrorMessage)
+		return
+	}
+	if err := h.syncSchedules(ctx); err != nil {
+		h.log(ctx).Error("failed to update scheduled jobs", zap.Error(err))
+	}
+
+	h.log(ctx).Info("added schedule",
+		zap.Int64("schedule_id", schedule.ID),
+		zap.Int64("chat_id", schedule.ChatID),
+		zap.String("spec", schedule.Spec),
+		zap.Int64("admin_id", message.From.ID),
+	)
+	h.sendMessage(ctx, chatID, fmt.Sprintf(bot.ScheduleAddedMessage, schedule.ID, schedule.ChatID, nextRun(parsed)))
+}
+
+// parseScheduleArgs reads "[chat=<id>] [persona=<name>] <schedule> <prompt>".
+// The schedule is five cron fields or a descriptor like @daily or @every 6h,
+// optionally after CRON_TZ=<zone>; the job posts to chatID unless chat= is given
+func parseScheduleArgs(args string, chatID int64) (*storage.Schedule, error) {
+	schedule := &storage.Schedule{ChatID: chatID}
+	fields := strings.Fields(args)
+
+	i := 0
+	for ; i < len(fields); i++ {
+		key, value, ok := strings.Cut(fields[i], "=")
+		if !ok {
+			break
+		}
+		switch strings.ToLower(key) {
+		case "chat":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id == 0 {
+				return nil, fmt.Errorf("invalid chat ID %q", value)
+			}
+			schedule.ChatID = id
+			continue
+		case "persona":
+			schedule.Persona = value
+			continue
+		}
+		break
+	}
+
+	start := i
+	if i < len(fields) && (strings.HasPrefix(fields[i], "CRON_TZ=") || strings.HasPrefix(fields[i], "TZ=")) {
+		i++
+	}
+	switch {
+	case i >= len(fields):
+		return nil, errors.New("missing schedule")
+	case strings.EqualFold(fields[i], "@every"):
+		i += 2
+	case strings.HasPrefix(fields[i], "@"):
+		i++
+	default:
+		i += 5
+	}
+	if i > len(fields) {
+		return nil, errors.New("incomplete schedule")
+	}
+	schedule.Spec = strings.Join(fields[start:i], " ")
+
+	schedule.Prompt = cutFields(args, i)
+	if schedule.Prompt == "" {
+		return nil, errors.New("missing prompt")
+	}
+	return schedule, nil
+}
+
+// listSchedules shows the jobs of config.yaml and /schedule with their next run
+func (h *Handler) listSchedules(ctx context.Context, chatID int64) {
+	stored, err := h.store.ListSchedules(ctx)
+	if err != nil {
+		h.log(ctx).Error("failed to load schedules", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	jobs := scheduledJobs(h.botConfig(ctx).Schedules, stored)
+	if len(jobs) == 0 {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ScheduleEmptyMessage)
+		return
+	}
+
+	var b strings.Builder
+	for _, job := range jobs {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		source := "remove with /schedule remove " + strconv.FormatInt(job.id, 10)
+		if job.name != "" {
+			source = "config.yaml"
+		}
+		next := "invalid schedule"
+		if schedule, err := parseSchedule(job.spec, h.botConfig(ctx).Timezone); err == nil {
+			next = "next " + nextRun(schedule)
+		}
+		fmt.Fprintf(&b, "%s (%s)\nchat %d, %s, %s", job.label(), source, job.chatID, job.spec, next)
+		if job.persona != "" {
+			fmt.Fprintf(&b, ", persona %s", job.persona)
+		}
+		fmt.Fprintf(&b, "\n💬 %s", truncate(job.prompt, maxQuotedMessage))
+	}
+
+	// Prompts and cron expressions are full of Markdown characters
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(h.botConfig(ctx).ScheduleListMessage, b.String()))
+	span := startTelegramSpan(ctx, "sendMessage", chatID)
+	_, err = h.bot.Send(msg)
+	tracing.End(span, err)
+	if err != nil {
+		h.log(ctx).Error("failed to send schedules", zap.Error(err))
+	}
+}
+
+// removeSchedule deletes a job added with /schedule
+func (h *Handler) removeSchedule(ctx context.Context, chatID int64, args string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(firstField(args), "#"), 10, 64)
+	if err != nil {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ScheduleSyntaxMessage)
+		return
+	}
+
+	err = h.store.DeleteSchedule(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).ScheduleNotFoundMessage, id))
+		return
+	}
+	if err != nil {
+		h.log(ctx).Error("failed to delete schedule", zap.Int64("schedule_id", id), zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	if err := h.syncSchedules(ctx); err != nil {
+		h.log(ctx).Error("failed to update scheduled jobs", zap.Error(err))
+	}
+
+	h.log(ctx).Info("removed schedule", zap.Int64("schedule_id", id))
+	h.sendMessage(ctx, chatID, fmt.Sprintf(h.botConfig(ctx).ScheduleRemovedMessage, id))
+}
+
+// nextRun shows when a schedule runs next in its timezone
+func nextRun(schedule cron.Schedule) string {
+	next := schedule.Next(time.Now())
+	return formatDue(next, next.Location())
+}
+
+// runSchedules runs the scheduled jobs until ctx is cancelled, then waits for
+// running jobs, which are cancelled too
+func (b *Bot) runSchedules(ctx context.Context) {
+	h := b.handler
+	h.scheduleMu.Lock()
+	h.scheduleCtx = ctx
+	h.scheduleMu.Unlock()
+
+	if err := h.syncSchedules(ctx); err != nil {
+		b.logger.Error("failed to schedule jobs", zap.Error(err))
+	}
+	h.cron.Start()
+
+	<-ctx.Done()
+	<-h.cron.Stop().Done()
+}
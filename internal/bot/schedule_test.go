@@ -0,0 +1,298 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func TestParseScheduleArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		expected storage.Schedule
+		wantErr  bool
+	}{
+		{
+			name:     "Cron expression in this chat",
+			args:     "0 9 * * 1-5 Post the English word of the day",
+			expected: storage.Schedule{ChatID: 10, Spec: "0 9 * * 1-5", Prompt: "Post the English word of the day"},
+		},
+		{
+			name:     "Options and descriptor",
+			args:     "chat=-100123 persona=english-teacher @daily Word of the day\n\nKeep it *short*",
+			expected: storage.Schedule{ChatID: -100123, Persona: "english-teacher", Spec: "@daily", Prompt: "Word of the day\n\nKeep it *short*"},
+		},
+		{
+			name:     "Interval",
+			args:     "@every 6h Summarize the news",
+			expected: storage.Schedule{ChatID: 10, Spec: "@every 6h", Prompt: "Summarize the news"},
+		},
+		{
+			name:     "Own timezone",
+			args:     "CRON_TZ=Europe/Berlin 30 8 * * * Good morning",
+			expected: storage.Schedule{ChatID: 10, Spec: "CRON_TZ=Europe/Berlin 30 8 * * *", Prompt: "Good morning"},
+		},
+		{name: "Missing prompt", args: "0 9 * * *", wantErr: true},
+		{name: "Incomplete schedule", args: "0 9 *", wantErr: true},
+		{name: "Missing schedule", args: "chat=5", wantErr: true},
+		{name: "Invalid chat", args: "chat=general @daily hi", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScheduleArgs(tt.args, 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseScheduleArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *got != tt.expected {
+				t.Errorf("parseScheduleArgs() = %+v, want %+v", *got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	from := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "Default timezone", spec: "0 9 * * *", expected: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{name: "Own timezone", spec: "CRON_TZ=UTC 0 9 * * *", expected: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{name: "Descriptor", spec: "@daily", expected: time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)},
+		{name: "Seconds are not supported", spec: "0 0 9 * * *", wantErr: true},
+		{name: "Invalid field", spec: "0 25 * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseSchedule(tt.spec, "Asia/Tokyo")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := schedule.Next(from); !got.Equal(tt.expected) {
+				t.Errorf("Next() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	jobs := []config.ScheduleJob{{Name: "digest", ChatID: 1, Schedule: "@daily", Prompt: "News"}}
+	if err := validateSchedules(jobs, nil, "UTC", time.Hour); err != nil {
+		t.Errorf("validateSchedules() error = %v", err)
+	}
+
+	jobs[0].Persona = "english-teacher"
+	if err := validateSchedules(jobs, nil, "UTC", time.Hour); err == nil {
+		t.Error("validateSchedules() accepted an unknown persona")
+	}
+
+	jobs[0].Persona, jobs[0].Schedule = "", "every day"
+	if err := validateSchedules(jobs, nil, "UTC", time.Hour); err == nil {
+		t.Error("validateSchedules() accepted an invalid schedule")
+	}
+
+	jobs[0].Schedule = "*/5 * * * *"
+	if err := validateSchedules(jobs, nil, "UTC", time.Hour); err == nil {
+		t.Error("validateSchedules() accepted a schedule below the minimum interval")
+	}
+	if err := validateSchedules(jobs, nil, "UTC", 0); err != nil {
+		t.Errorf("validateSchedules() without a minimum interval error = %v", err)
+	}
+}
+
+func TestScheduleInterval(t *testing.T) {
+	from := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Duration
+	}{
+		{spec: "* * * * *", expected: time.Minute},
+		{spec: "@every 1s", expected: time.Second},
+		{spec: "@hourly", expected: time.Hour},
+		{spec: "0 9,10 * * *", expected: time.Hour},
+		{spec: "0 9 * * 1-5", expected: 24 * time.Hour},
+		{spec: "@every 6h", expected: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := parseSchedule(tt.spec, "UTC")
+			if err != nil {
+				t.Fatalf("parseSchedule() error = %v", err)
+			}
+			if got := scheduleInterval(schedule, from); got != tt.expected {
+				t.Errorf("scheduleInterval() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// newScheduleHandler returns a handler with a config.yaml job and an AI answering reply
+func newScheduleHandler(t *testing.T, reply string) (*Handler, *fakeTelegram) {
+	t.Helper()
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` + reply + `"}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+	}))
+	t.Cleanup(provider.Close)
+	service, err := ai.NewService(provider.URL, "test-model", "key", "You are a bot.", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	fake, api := newFakeTelegram(t)
+	cfg := &config.Config{Bot: config.BotConfig{
+		Timezone:                "UTC",
+		ScheduleMinInterval:     time.Hour,
+		ScheduleSyntaxMessage:   "syntax",
+		ScheduleInvalidMessage:  "invalid %q: %v",
+		ScheduleFrequentMessage: "too frequent, at most every %s",
+		SchedulePersonaMessage:  "unknown persona %q",
+		ScheduleAddedMessage:    "added #%d for chat %d, next run %s",
+		ScheduleListMessage:     "jobs:\n\n%s",
+		ScheduleEmptyMessage:    "empty",
+		ScheduleNotFoundMessage: "no #%d",
+		ScheduleRemovedMessage:  "removed #%d",
+		Schedules: []config.ScheduleJob{
+			{Name: "word-of-the-day", ChatID: -100, Schedule: "0 9 * * *", Prompt: "Post the English word of the day"},
+		},
+	}}
+	handler := &Handler{
+		config:    cfg,
+		logger:    zap.NewNop(),
+		bot:       api,
+		store:     storage.NewMemoryStore(),
+		aiService: service,
+		cron:      newScheduleCron(zap.NewNop()),
+	}
+	handler.botSettings.Store(&cfg.Bot)
+	return handler, fake
+}
+
+func TestSchedules(t *testing.T) {
+	handler, fake := newScheduleHandler(t, "unused")
+	ctx := context.Background()
+	admin := &tgbotapi.User{ID: 1}
+	command := func(args string) *commandCall {
+		return &commandCall{message: &tgbotapi.Message{From: admin, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}}, argText: args}
+	}
+	reply := func() string {
+		t.Helper()
+		calls := fake.take()
+		if len(calls) != 1 {
+			t.Fatalf("made requests %+v, want one message", calls)
+		}
+		return calls[0].params["text"]
+	}
+
+	if err := handler.syncSchedules(ctx); err != nil || len(handler.scheduleEntries) != 1 {
+		t.Fatalf("syncSchedules() error = %v, %d entries, want the config.yaml job", err, len(handler.scheduleEntries))
+	}
+
+	handler.handleSchedule(ctx, command("add chat=-200 @every 6h Summarize the news"))
+	if got := reply(); !strings.HasPrefix(got, "added #1 for chat -200, next run ") {
+		t.Errorf("/schedule add replied %q", got)
+	}
+	if len(handler.scheduleEntries) != 2 {
+		t.Errorf("scheduled %d jobs after /schedule add, want 2", len(handler.scheduleEntries))
+	}
+
+	handler.handleSchedule(ctx, command("add 0 25 * * * Never"))
+	if got := reply(); !strings.HasPrefix(got, `invalid "0 25 * * *": `) {
+		t.Errorf("/schedule add of an invalid schedule replied %q", got)
+	}
+	handler.handleSchedule(ctx, command("add @every 1s Spam"))
+	if got := reply(); got != "too frequent, at most every 1h0m0s" {
+		t.Errorf("/schedule add of a too frequent schedule replied %q", got)
+	}
+	handler.handleSchedule(ctx, command("add 0 9 *"))
+	if got := reply(); got != "syntax" {
+		t.Errorf("/schedule add of an incomplete schedule replied %q, want the syntax", got)
+	}
+	handler.handleSchedule(ctx, command("add persona=pirate @daily Arr"))
+	if got := reply(); got != `unknown persona "pirate"` {
+		t.Errorf("/schedule add with an unknown persona replied %q", got)
+	}
+
+	handler.handleSchedule(ctx, command("list"))
+	got := reply()
+	for _, want := range []string{"jobs:\n\nword-of-the-day (config.yaml)\nchat -100, 0 9 * * *", "#1 (remove with /schedule remove 1)\nchat -200, @every 6h", "💬 Summarize the news"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("/schedule list = %q, want it to contain %q", got, want)
+		}
+	}
+
+	handler.handleSchedule(ctx, command("remove 1"))
+	if got := reply(); got != "removed #1" {
+		t.Errorf("/schedule remove replied %q", got)
+	}
+	handler.handleSchedule(ctx, command("remove 1"))
+	if got := reply(); got != "no #1" {
+		t.Errorf("/schedule remove of a removed job replied %q", got)
+	}
+	if len(handler.scheduleEntries) != 1 {
+		t.Errorf("scheduled %d jobs after /schedule remove, want 1", len(handler.scheduleEntries))
+	}
+
+	handler.handleSchedule(ctx, command("pause 1"))
+	if got := reply(); got != "syntax" {
+		t.Errorf("/schedule pause replied %q, want the syntax", got)
+	}
+}
+
+func TestRunScheduledJob(t *testing.T) {
+	handler, fake := newScheduleHandler(t, "Serendipity: a happy accident.")
+	fake.blocked = map[string]bool{"-300": true}
+	ctx := context.Background()
+	now := time.Now()
+	for _, chatID := range []int64{-100, -300} {
+		if err := handler.store.TouchChat(ctx, storage.ChatInfo{ChatID: chatID, Type: "supergroup", FirstSeen: now, LastSeen: now}); err != nil {
+			t.Fatalf("TouchChat() error = %v", err)
+		}
+	}
+
+	handler.runScheduledJob(ctx, scheduledJob{name: "word-of-the-day", chatID: -100, prompt: "Post the English word of the day"})
+	calls := fake.take()
+	if methods(calls) != "sendMessage" || calls[0].params["chat_id"] != "-100" || calls[0].params["text"] != "Serendipity: a happy accident." {
+		t.Fatalf("runScheduledJob() made requests %+v", calls)
+	}
+	conv, err := handler.store.GetConversation(ctx, -100)
+	if err != nil || len(conv.Messages) != 1 || conv.Messages[0].Role != "assistant" || conv.Messages[0].MessageID != 51 {
+		t.Errorf("GetConversation() = %+v, %v, want the post as an assistant message", conv, err)
+	}
+
+	// A chat that blocked the bot is marked and then skipped
+	job := scheduledJob{id: 2, chatID: -300, prompt: "News"}
+	handler.runScheduledJob(ctx, job)
+	if chat, err := handler.store.GetChat(ctx, -300); err != nil || !chat.Blocked {
+		t.Errorf("GetChat() = %+v, %v, want a blocked chat", chat, err)
+	}
+	fake.take()
+	handler.runScheduledJob(ctx, job)
+	if calls := fake.take(); len(calls) != 0 {
+		t.Errorf("runScheduledJob() for a blocked chat made requests %+v", calls)
+	}
+}
@@ -0,0 +1,33 @@
+package bot
+
+import (
+	"net/http"
+
+	"tgbot-skeleton/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// newServeMux builds the HTTP routes shared by webhook and long polling modes
+func (b *Bot) newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", b.handleHealth)
+	mux.HandleFunc("/ready", b.handleReady)
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// serveHTTP runs the HTTP server until it fails
+func (b *Bot) serveHTTP(handler http.Handler) {
+	if err := http.ListenAndServe(b.config.Server.Address, handler); err != nil {
+		b.logger.Error("HTTP server error", zap.Error(err))
+	}
+}
+
+// handleHealth reports that the process is alive
+func (b *Bot) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		b.logger.Error("failed to write health response", zap.Error(err))
+	}
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/tools"
+)
+
+// builtinTools creates the tools AI_TOOLS can enable, by name
+var builtinTools = map[string]func(cfg *config.AIConfig) (ai.Tool, error){
+	"current_time": func(cfg *config.AIConfig) (ai.Tool, error) {
+		location, err := time.LoadLocation(cfg.ToolTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tool timezone %q: %w", cfg.ToolTimezone, err)
+		}
+		return tools.NewTime(location), nil
+	},
+	"calculator": func(*config.AIConfig) (ai.Tool, error) {
+		return tools.NewCalculator(), nil
+	},
+	"convert_units": func(*config.AIConfig) (ai.Tool, error) {
+		return tools.NewUnitConverter(), nil
+	},
+	"http_get": func(cfg *config.AIConfig) (ai.Tool, error) {
+		if len(cfg.ToolHTTPDomains) == 0 {
+			return nil, fmt.Errorf("http_get needs the allowed domains in AI_TOOL_HTTP_DOMAINS")
+		}
+		return tools.NewHTTPGet(cfg.ToolHTTPDomains), nil
+	},
+}
+
+// loadTools creates the tools listed in AI_TOOLS
+func loadTools(cfg *config.AIConfig) ([]ai.Tool, error) {
+	var enabled []ai.Tool
+	for _, name := range cfg.Tools {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		create, ok := builtinTools[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		tool, err := create(cfg)
+		if err != nil {
+			return nil, err
+		}
+		enabled = append(enabled, tool)
+	}
+	return enabled, nil
+}
+
+// withToolCalls shows the tools the model used above its answer, e.g.
+// "🛠 `current_time` `calculator`"
+func withToolCalls(content string, calls []ai.ToolResult) string {
+	var names []string
+	for _, call := range calls {
+		if !slices.Contains(names, call.Name) {
+			names = append(names, call.Name)
+		}
+	}
+	if len(names) == 0 {
+		return content
+	}
+	return "🛠 `" + strings.Join(names, "` `") + "`\n\n" + content
+}
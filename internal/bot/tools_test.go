@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"testing"
+
+	"tgbot-skeleton/internal/ai"
+	"tgbot-skeleton/internal/config"
+)
+
+func TestLoadTools(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.AIConfig
+		expected []string
+		wantErr  bool
+	}{
+		{name: "No tools", cfg: config.AIConfig{}},
+		{
+			name:     "Built-in tools",
+			cfg:      config.AIConfig{Tools: []string{"current_time", " calculator", "convert_units", ""}, ToolTimezone: "Europe/Berlin"},
+			expected: []string{"current_time", "calculator", "convert_units"},
+		},
+		{
+			name:     "HTTP tool with domains",
+			cfg:      config.AIConfig{Tools: []string{"http_get"}, ToolHTTPDomains: []string{"example.com"}},
+			expected: []string{"http_get"},
+		},
+		{name: "HTTP tool without domains", cfg: config.AIConfig{Tools: []string{"http_get"}}, wantErr: true},
+		{name: "Invalid timezone", cfg: config.AIConfig{Tools: []string{"current_time"}, ToolTimezone: "Nowhere/City"}, wantErr: true},
+		{name: "Unknown tool", cfg: config.AIConfig{Tools: []string{"shell"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools, err := loadTools(&tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTools() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tools) != len(tt.expected) {
+				t.Fatalf("loadTools() returned %d tools, want %d", len(tools), len(tt.expected))
+			}
+			for i, tool := range tools {
+				if tool.Name() != tt.expected[i] {
+					t.Errorf("tool %d = %s, want %s", i, tool.Name(), tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWithToolCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		calls    []ai.ToolResult
+		expected string
+	}{
+		{name: "No calls", expected: "answer"},
+		{
+			name:     "Repeated calls",
+			calls:    []ai.ToolResult{{Name: "calculator"}, {Name: "current_time"}, {Name: "calculator"}},
+			expected: "🛠 `calculator` `current_time`\n\nanswer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withToolCalls("answer", tt.calls); got != tt.expected {
+				t.Errorf("withToolCalls() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
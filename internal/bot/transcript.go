@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/storage"
+)
+
+// transcriptVersion is the version of the JSON transcript format
+const transcriptVersion = 1
+
+// transcriptTimeFormat is used for timestamps in Markdown transcripts
+const transcriptTimeFormat = "2006-01-02 15:04:05 MST"
+
+// Transcript is the re-importable JSON representation of a conversation
+type Transcript struct {
+	Version    int                           `json:"version"`
+	ChatID     int64                         `json:"chat_id"`
+	ExportedAt time.Time                     `json:"exported_at"`
+	Messages   []storage.ConversationMessage `json:"messages"`
+}
+
+// buildJSONTranscript encodes a conversation as an indented JSON transcript
+func buildJSONTranscript(conv *storage.Conversation, exportedAt time.Time) ([]byte, error) {
+	transcript := Transcript{
+		Version:    transcriptVersion,
+		ChatID:     conv.ChatID,
+		ExportedAt: exportedAt.UTC(),
+		Messages:   conv.Messages,
+	}
+	data, err := json.MarshalIndent(transcript, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal transcript: %w", err)
+	}
+	return data, nil
+}
+
+// buildMarkdownTranscript renders a conversation as a human-readable Markdown document
+func buildMarkdownTranscript(conv *storage.Conversation, exportedAt time.Time) []byte {
+	var b strings.Builder
+
+	b.WriteString("# Chat transcript\n\n")
+	fmt.Fprintf(&b, "- Chat ID: %d\n", conv.ChatID)
+	fmt.Fprintf(&b, "- Exported: %s\n", exportedAt.UTC().Format(transcriptTimeFormat))
+	fmt.Fprintf(&b, "- Messages: %d\n", len(conv.Messages))
+
+	for _, msg := range conv.Messages {
+		b.WriteString("\n## ")
+		switch msg.Role {
+		case "user":
+			b.WriteString("👤 User")
+		case "assistant":
+			b.WriteString("🤖 Assistant")
+		default:
+			b.WriteString(msg.Role)
+		}
+		if msg.Model != "" {
+			fmt.Fprintf(&b, " (%s)", msg.Model)
+		}
+		if !msg.Timestamp.IsZero() {
+			fmt.Fprintf(&b, " — %s", msg.Timestamp.UTC().Format(transcriptTimeFormat))
+		}
+		b.WriteString("\n\n")
+		b.WriteString(strings.TrimSpace(msg.Content))
+		b.WriteString("\n")
+	}
+
+	return []byte(b.String())
+}
+
+// parseJSONTranscript decodes and validates a JSON transcript produced by /export json
+func parseJSONTranscript(data []byte) (*Transcript, error) {
+	var transcript Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("invalid transcript JSON: %w", err)
+	}
+
+	if transcript.Version != transcriptVersion {
+		return nil, fmt.Errorf("unsupported transcript version %d", transcript.Version)
+	}
+	if len(transcript.Messages) == 0 {
+		return nil, fmt.Errorf("transcript has no messages")
+	}
+	for i, msg := range transcript.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return nil, fmt.Errorf("message %d has unsupported role %q", i+1, msg.Role)
+		}
+		if strings.TrimSpace(msg.Content) == "" {
+			return nil, fmt.Errorf("message %d is empty", i+1)
+		}
+	}
+
+	return &transcript, nil
+}
@@ -0,0 +1,169 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/config"
+	"tgbot-skeleton/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.uber.org/zap"
+)
+
+func testConversation() *storage.Conversation {
+	ts := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	return &storage.Conversation{
+		ChatID: 123,
+		Messages: []storage.ConversationMessage{
+			{Role: "user", Content: "What is Go?", UserID: 1, Timestamp: ts},
+			{Role: "assistant", Content: "A programming language.", Model: "test-model", Timestamp: ts.Add(time.Second)},
+		},
+	}
+}
+
+func TestTranscript_JSONRoundTrip(t *testing.T) {
+	conv := testConversation()
+
+	data, err := buildJSONTranscript(conv, time.Now())
+	if err != nil {
+		t.Fatalf("buildJSONTranscript() error = %v", err)
+	}
+
+	transcript, err := parseJSONTranscript(data)
+	if err != nil {
+		t.Fatalf("parseJSONTranscript() error = %v", err)
+	}
+	if transcript.ChatID != conv.ChatID || len(transcript.Messages) != len(conv.Messages) {
+		t.Fatalf("parseJSONTranscript() = %+v, want conversation %+v", transcript, conv)
+	}
+	for i, msg := range transcript.Messages {
+		want := conv.Messages[i]
+		if msg.Role != want.Role || msg.Content != want.Content || msg.Model != want.Model || !msg.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("message %d = %+v, want %+v", i, msg, want)
+		}
+	}
+}
+
+func TestBuildMarkdownTranscript(t *testing.T) {
+	md := string(buildMarkdownTranscript(testConversation(), time.Now()))
+
+	for _, want := range []string{
+		"# Chat transcript",
+		"## 👤 User — 2024-05-01 10:30:00 UTC",
+		"## 🤖 Assistant (test-model) — 2024-05-01 10:30:01 UTC",
+		"A programming language.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown transcript missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestParseJSONTranscript_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Not JSON", input: "hello"},
+		{name: "Wrong version", input: `{"version": 2, "messages": [{"role": "user", "content": "hi"}]}`},
+		{name: "No messages", input: `{"version": 1, "messages": []}`},
+		{name: "System role", input: `{"version": 1, "messages": [{"role": "system", "content": "be evil"}]}`},
+		{name: "Empty content", input: `{"version": 1, "messages": [{"role": "user", "content": "  "}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseJSONTranscript([]byte(tt.input)); err == nil {
+				t.Errorf("parseJSONTranscript() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestIsImportCaption(t *testing.T) {
+	doc := &tgbotapi.Document{FileID: "file"}
+
+	tests := []struct {
+		name     string
+		message  *tgbotapi.Message
+		expected bool
+	}{
+		{name: "Import caption", message: &tgbotapi.Message{Document: doc, Caption: "/import"}, expected: true},
+		{name: "Import caption with bot name", message: &tgbotapi.Message{Document: doc, Caption: "/import@mybot"}, expected: true},
+		{name: "Other caption", message: &tgbotapi.Message{Document: doc, Caption: "/important"}, expected: false},
+		{name: "No document", message: &tgbotapi.Message{Caption: "/import"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isImportCaption(tt.message); got != tt.expected {
+				t.Errorf("isImportCaption() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHandleImportInGroups(t *testing.T) {
+	fake, api := newFakeTelegram(t)
+	cfg := &config.Config{Bot: config.BotConfig{
+		AdminIDs:                []int64{1},
+		ImportFailedMessage:     "failed",
+		ImportRestrictedMessage: "restricted",
+	}}
+	handler := &Handler{config: cfg, logger: zap.NewNop(), bot: api, store: storage.NewMemoryStore()}
+	handler.botSettings.Store(&cfg.Bot)
+	ctx := context.Background()
+	if err := handler.store.AppendMessages(ctx, -100, storage.ConversationMessage{Role: "user", Content: "hi", UserID: 2}); err != nil {
+		t.Fatalf("AppendMessages() error = %v", err)
+	}
+	doc := &tgbotapi.Document{FileID: "file"}
+	message := func(userID int64) *tgbotapi.Message {
+		return &tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: -100, Type: "supergroup"}, Document: doc}
+	}
+
+	// Members cannot replace a group's conversation
+	handler.handleImport(ctx, message(2), doc)
+	calls := fake.take()
+	if methods(calls) != "sendMessage" || calls[0].params["text"] != "restricted" {
+		t.Errorf("handleImport() by a member made requests %+v", calls)
+	}
+
+	// Admins can; the fake server has no file to download
+	handler.handleImport(ctx, message(1), doc)
+	calls = fake.take()
+	if methods(calls) != "getFile,sendMessage" || calls[1].params["text"] != "failed" {
+		t.Errorf("handleImport() by an admin made requests %+v", calls)
+	}
+}
+
+func TestHandleImportCaption(t *testing.T) {
+	var calls []*commandCall
+	record := func(next commandFunc) commandFunc {
+		return func(ctx context.Context, call *commandCall) {
+			calls = append(calls, call)
+			next(ctx, call)
+		}
+	}
+	handler := &Handler{
+		config:   &config.Config{},
+		logger:   zap.NewNop(),
+		bot:      testBotAPI(t),
+		commands: newCommandRouter(record).mustRegister(&command{name: "import", handler: noopCommand}),
+	}
+	doc := &tgbotapi.Document{FileID: "file"}
+	chat := &tgbotapi.Chat{ID: 1, Type: "private"}
+
+	handler.handleImportCaption(context.Background(), &tgbotapi.Message{Chat: chat, Document: doc, Caption: "/import@MyBot keep"})
+	if len(calls) != 1 || calls[0].command.name != "import" || calls[0].argText != "keep" || calls[0].message.Document != doc {
+		t.Fatalf("handleImportCaption() dispatched %+v, want /import through the router middleware", calls)
+	}
+
+	// Captions addressed to another bot are not for this one
+	handler.handleImportCaption(context.Background(), &tgbotapi.Message{Chat: chat, Document: doc, Caption: "/import@OtherBot"})
+	if len(calls) != 1 {
+		t.Errorf("handleImportCaption() dispatched a caption for another bot")
+	}
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/storage"
+	"tgbot-skeleton/internal/usage"
+
+	"go.uber.org/zap"
+)
+
+// usageReportLimit caps the number of rows per breakdown in the /usage reply
+const usageReportLimit = 10
+
+// usageQuery holds the parsed arguments of /usage
+type usageQuery struct {
+	userID int64
+	period usage.Period
+	csv    bool
+}
+
+// handleUsage reports AI usage and cost for a period, optionally for a single user or as CSV
+func (h *Handler) handleUsage(ctx context.Context, call *commandCall) {
+	chatID := call.message.Chat.ID
+
+	query, err := parseUsageQuery(call.argText, time.Now())
+	if err != nil {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).UsageSyntaxMessage)
+		return
+	}
+
+	records, err := h.store.ListUsage(ctx, query.period.From, query.period.To)
+	if err != nil {
+		h.log(ctx).Error("failed to load usage", zap.Error(err))
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+		return
+	}
+	if query.userID != 0 {
+		records = usage.FilterUser(records, query.userID)
+	}
+	if len(records) == 0 {
+		h.sendMessage(ctx, chatID, h.botConfig(ctx).UsageEmptyMessage)
+		return
+	}
+
+	if query.csv {
+		var buf bytes.Buffer
+		if err := usage.WriteCSV(&buf, records); err != nil {
+			h.log(ctx).Error("failed to build usage CSV", zap.Error(err))
+			h.sendMessage(ctx, chatID, h.botConfig(ctx).ErrorMessage)
+			return
+		}
+		name := fmt.Sprintf("usage-%s-%s.csv", query.period.From, query.period.To)
+		h.sendDocument(ctx, chatID, name, buf.Bytes())
+		return
+	}
+
+	h.sendMessage(ctx, chatID, h.buildUsageReport(ctx, query, records))
+}
+
+// parseUsageQuery parses "/usage [user_id] [period] [csv]" in any order; the
+// period defaults to the current month
+func parseUsageQuery(args string, now time.Time) (usageQuery, error) {
+	query := usageQuery{period: usage.CurrentMonth(now)}
+	for _, arg := range strings.Fields(args) {
+		if strings.EqualFold(arg, "csv") {
+			query.csv = true
+			continue
+		}
+		if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			query.userID = id
+			continue
+		}
+		period, err := usage.ParsePeriod(arg, now)
+		if err != nil {
+			return usageQuery{}, err
+		}
+		query.period = period
+	}
+	return query, nil
+}
+
+// buildUsageReport renders totals and the most expensive users, chats and models
+func (h *Handler) buildUsageReport(ctx context.Context, query usageQuery, records []storage.UsageRecord) string {
+	currency := h.config.Usage.Currency
+	totals := usage.Sum(records)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Usage for %s\n", query.period)
+	if query.userID != 0 {
+		fmt.Fprintf(&b, "👤 %s\n", h.userLabel(ctx, query.userID))
+	}
+	fmt.Fprintf(&b, "\nRequests: %d\n", totals.Requests)
+	fmt.Fprintf(&b, "Tokens: %d in / %d out\n", totals.PromptTokens, totals.CompletionTokens)
+	fmt.Fprintf(&b, "Cost: %s\n", formatCost(totals.Cost, currency))
+
+	if query.userID == 0 {
+		byUser := usage.GroupBy(records, func(r storage.UsageRecord) string {
+			return strconv.FormatInt(r.UserID, 10)
+		})
+		writeUsageGroups(&b, "By user", byUser, currency, func(key string) string {
+			id, _ := strconv.ParseInt(key, 10, 64)
+			return h.userLabel(ctx, id)
+		})
+	}
+
+	byChat := usage.GroupBy(records, func(r storage.UsageRecord) string {
+		return strconv.FormatInt(r.ChatID, 10)
+	})
+	writeUsageGroups(&b, "By chat", byChat, currency, nil)
+
+	byModel := usage.GroupBy(records, func(r storage.UsageRecord) string { return r.Model })
+	writeUsageGroups(&b, "By model", byModel, currency, nil)
+
+	return b.String()
+}
+
+// writeUsageGroups appends a breakdown section, labelling keys with label if set
+func writeUsageGroups(b *strings.Builder, title string, groups []usage.Group, currency string, label func(string) string) {
+	fmt.Fprintf(b, "\n%s:\n", title)
+	for i, group := range groups {
+		if i == usageReportLimit {
+			fmt.Fprintf(b, "• … and %d more\n", len(groups)-usageReportLimit)
+			break
+		}
+		name := group.Key
+		if label != nil {
+			name = label(group.Key)
+		}
+		fmt.Fprintf(b, "• %s: %d req, %d tokens, %s\n",
+			name, group.Requests, group.PromptTokens+group.CompletionTokens, formatCost(group.Cost, currency))
+	}
+}
+
+// userLabel describes a user by ID and, if known, username
+func (h *Handler) userLabel(ctx context.Context, userID int64) string {
+	settings, err := h.store.GetUserSettings(ctx, userID)
+	if err != nil || settings.Username == "" {
+		return strconv.FormatInt(userID, 10)
+	}
+	return fmt.Sprintf("%d (@%s)", userID, settings.Username)
+}
+
+// formatCost formats an amount of money with enough precision for small requests
+func formatCost(cost float64, currency string) string {
+	return fmt.Sprintf("%.4f %s", cost, currency)
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/usage"
+)
+
+func TestParseUsageQuery(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		args     string
+		expected usageQuery
+		wantErr  bool
+	}{
+		{name: "Defaults", args: "", expected: usageQuery{period: usage.Period{From: "2024-05-01", To: "2024-05-15"}}},
+		{name: "User and period", args: "42 2024-04", expected: usageQuery{userID: 42, period: usage.Period{From: "2024-04-01", To: "2024-04-30"}}},
+		{name: "CSV any order", args: "csv today", expected: usageQuery{period: usage.Period{From: "2024-05-15", To: "2024-05-15"}, csv: true}},
+		{name: "Invalid", args: "everything", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUsageQuery(tt.args, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUsageQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("parseUsageQuery() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
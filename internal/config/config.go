@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
+
+	"tgbot-skeleton/internal/prompt"
+	"tgbot-skeleton/internal/usage"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/viper"
@@ -11,11 +15,15 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Telegram TelegramConfig `mapstructure:"telegram"`
-	Server   ServerConfig   `mapstructure:"server"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
-	AI       AIConfig       `mapstructure:"ai"`
-	Bot      BotConfig      `mapstructure:"bot"`
+	Telegram  TelegramConfig  `mapstructure:"telegram"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Logging   LoggingConfig   `mapstructure:"logging"`
+	AI        AIConfig        `mapstructure:"ai"`
+	Bot       BotConfig       `mapstructure:"bot"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	Usage     UsageConfig     `mapstructure:"usage"`
+	Knowledge KnowledgeConfig `mapstructure:"knowledge"`
 }
 
 // TelegramConfig holds Telegram bot configuration
@@ -37,25 +45,214 @@ type ServerConfig struct {
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level string `mapstructure:"level"`
+	Level             string `mapstructure:"level"`
+	LogMessageContent bool   `mapstructure:"log_message_content"`
 }
 
 // AIConfig holds AI provider configuration
 type AIConfig struct {
-	URL        string `mapstructure:"url"`
-	Model      string `mapstructure:"model"`
-	APIKey     string `mapstructure:"api_key"`
-	Prompt     string `mapstructure:"prompt"`
-	PromptFile string `mapstructure:"prompt_file"`
+	URL         string  `mapstructure:"url"`
+	Model       string  `mapstructure:"model"`
+	APIKey      string  `mapstructure:"api_key"`
+	Prompt      string  `mapstructure:"prompt"`
+	PromptFile  string  `mapstructure:"prompt_file"`
+	PromptsDir  string  `mapstructure:"prompts_dir"`
+	RedactPII   bool    `mapstructure:"redact_pii"`
+	Temperature float64 `mapstructure:"temperature"`
+	MaxTokens   int     `mapstructure:"max_tokens"`
+	// AnswerInUserLanguage instructs the model to answer in the user's language
+	AnswerInUserLanguage bool `mapstructure:"answer_in_user_language"`
+	// InlinePrompt is appended to the system prompt for inline queries to keep answers short
+	InlinePrompt string `mapstructure:"inline_prompt"`
+	// InlineMaxTokens limits the length of inline answers
+	InlineMaxTokens int `mapstructure:"inline_max_tokens"`
+	// Tools lists the built-in tools the model may call; empty disables tool calling
+	Tools []string `mapstructure:"tools"`
+	// ToolMaxIterations limits the rounds of tool calls per answer
+	ToolMaxIterations int `mapstructure:"tool_max_iterations"`
+	// ToolTimezone is the default timezone of the current_time tool
+	ToolTimezone string `mapstructure:"tool_timezone"`
+	// ToolHTTPDomains are the domains, with their subdomains, the http_get tool may fetch
+	ToolHTTPDomains []string `mapstructure:"tool_http_domains"`
+	// ShowToolCalls shows the tools used above AI answers
+	ShowToolCalls bool `mapstructure:"show_tool_calls"`
+	// PromptVars lists custom prompt template values, e.g. "company=Acme,support_email=help@acme.com"
+	PromptVars string            `mapstructure:"prompt_vars"`
+	Vars       map[string]string `mapstructure:"-"`
+	// Examples are few-shot turns from the prompt file front-matter
+	Examples []prompt.Example `mapstructure:"-"`
 }
 
 // BotConfig holds bot messages and behavior configuration
 type BotConfig struct {
-	StartMessage          string `mapstructure:"start_message"`
-	HelpMessage           string `mapstructure:"help_message"`
-	UnknownCommandMessage string `mapstructure:"unknown_command_message"`
-	ErrorMessage          string `mapstructure:"error_message"`
-	EmptyMessage          string `mapstructure:"empty_message"`
+	StartMessage             string  `mapstructure:"start_message"`
+	HelpMessage              string  `mapstructure:"help_message"`
+	UnknownCommandMessage    string  `mapstructure:"unknown_command_message"`
+	ErrorMessage             string  `mapstructure:"error_message"`
+	EmptyMessage             string  `mapstructure:"empty_message"`
+	QuotaExceededMessage     string  `mapstructure:"quota_exceeded_message"`
+	ExportUsageMessage       string  `mapstructure:"export_usage_message"`
+	ExportEmptyMessage       string  `mapstructure:"export_empty_message"`
+	ImportUsageMessage       string  `mapstructure:"import_usage_message"`
+	ImportSuccessMessage     string  `mapstructure:"import_success_message"`
+	ImportFailedMessage      string  `mapstructure:"import_failed_message"`
+	ImportRestrictedMessage  string  `mapstructure:"import_restricted_message"`
+	ForgetMessage            string  `mapstructure:"forget_message"`
+	AdminOnlyMessage         string  `mapstructure:"admin_only_message"`
+	UsageSyntaxMessage       string  `mapstructure:"usage_syntax_message"`
+	UsageEmptyMessage        string  `mapstructure:"usage_empty_message"`
+	BroadcastSyntaxMessage   string  `mapstructure:"broadcast_syntax_message"`
+	BroadcastBusyMessage     string  `mapstructure:"broadcast_busy_message"`
+	BroadcastDryRunMessage   string  `mapstructure:"broadcast_dry_run_message"`
+	BroadcastStartedMessage  string  `mapstructure:"broadcast_started_message"`
+	BroadcastProgressMessage string  `mapstructure:"broadcast_progress_message"`
+	BroadcastFinishedMessage string  `mapstructure:"broadcast_finished_message"`
+	ReloadSuccessMessage     string  `mapstructure:"reload_success_message"`
+	ReloadFailedMessage      string  `mapstructure:"reload_failed_message"`
+	ReindexSuccessMessage    string  `mapstructure:"reindex_success_message"`
+	ReindexEmbeddedMessage   string  `mapstructure:"reindex_embedded_message"`
+	ReindexFallbackMessage   string  `mapstructure:"reindex_fallback_message"`
+	ReindexFailedMessage     string  `mapstructure:"reindex_failed_message"`
+	ScheduleSyntaxMessage    string  `mapstructure:"schedule_syntax_message"`
+	ScheduleInvalidMessage   string  `mapstructure:"schedule_invalid_message"`
+	ScheduleFrequentMessage  string  `mapstructure:"schedule_frequent_message"`
+	SchedulePersonaMessage   string  `mapstructure:"schedule_persona_message"`
+	ScheduleAddedMessage     string  `mapstructure:"schedule_added_message"`
+	ScheduleListMessage      string  `mapstructure:"schedule_list_message"`
+	ScheduleEmptyMessage     string  `mapstructure:"schedule_empty_message"`
+	ScheduleNotFoundMessage  string  `mapstructure:"schedule_not_found_message"`
+	ScheduleRemovedMessage   string  `mapstructure:"schedule_removed_message"`
+	PersonaListMessage       string  `mapstructure:"persona_list_message"`
+	PersonaSwitchedMessage   string  `mapstructure:"persona_switched_message"`
+	PersonaUnknownMessage    string  `mapstructure:"persona_unknown_message"`
+	PersonaDisabledMessage   string  `mapstructure:"persona_disabled_message"`
+	LanguageListMessage      string  `mapstructure:"language_list_message"`
+	LanguageSwitchedMessage  string  `mapstructure:"language_switched_message"`
+	LanguageUnknownMessage   string  `mapstructure:"language_unknown_message"`
+	LanguageDisabledMessage  string  `mapstructure:"language_disabled_message"`
+	CommandChatTypeMessage   string  `mapstructure:"command_chat_type_message"`
+	CommandRateLimitMessage  string  `mapstructure:"command_rate_limit_message"`
+	AccessDeniedMessage      string  `mapstructure:"access_denied_message"`
+	AnswerExpiredMessage     string  `mapstructure:"answer_expired_message"`
+	AnswerNotYoursMessage    string  `mapstructure:"answer_not_yours_message"`
+	InlineRateLimitMessage   string  `mapstructure:"inline_rate_limit_message"`
+	OperatorConnectedMessage string  `mapstructure:"operator_connected_message"`
+	OperatorWaitingMessage   string  `mapstructure:"operator_waiting_message"`
+	OperatorClosedMessage    string  `mapstructure:"operator_closed_message"`
+	TicketUsageMessage       string  `mapstructure:"ticket_usage_message"`
+	TicketUndeliveredMessage string  `mapstructure:"ticket_undelivered_message"`
+	TicketCloseUsageMessage  string  `mapstructure:"ticket_close_usage_message"`
+	TicketClosedMessage      string  `mapstructure:"ticket_closed_message"`
+	ReminderSyntaxMessage    string  `mapstructure:"reminder_syntax_message"`
+	ReminderSetMessage       string  `mapstructure:"reminder_set_message"`
+	ReminderTimeMessage      string  `mapstructure:"reminder_time_message"`
+	ReminderPastMessage      string  `mapstructure:"reminder_past_message"`
+	ReminderLimitMessage     string  `mapstructure:"reminder_limit_message"`
+	ReminderListMessage      string  `mapstructure:"reminder_list_message"`
+	ReminderEmptyMessage     string  `mapstructure:"reminder_empty_message"`
+	ReminderCanceledMessage  string  `mapstructure:"reminder_canceled_message"`
+	ReminderMessage          string  `mapstructure:"reminder_message"`
+	TimezoneMessage          string  `mapstructure:"timezone_message"`
+	TimezoneSwitchedMessage  string  `mapstructure:"timezone_switched_message"`
+	TimezoneUnknownMessage   string  `mapstructure:"timezone_unknown_message"`
+	DailyMessageLimit        int     `mapstructure:"daily_message_limit"`
+	AdminIDs                 []int64 `mapstructure:"admin_ids"`
+	// AllowedCommands limits the commands users can run; empty allows all
+	AllowedCommands []string `mapstructure:"allowed_commands"`
+	// AnswerActions shows Regenerate, Continue, Shorter and Longer buttons under AI answers
+	AnswerActions bool `mapstructure:"answer_actions"`
+	// AllowedUserIDs and AllowedChatIDs restrict the bot to these users and chats; empty allows everyone
+	AllowedUserIDs []int64 `mapstructure:"allowed_user_ids"`
+	AllowedChatIDs []int64 `mapstructure:"allowed_chat_ids"`
+	// BlockedUserIDs are ignored entirely
+	BlockedUserIDs []int64 `mapstructure:"blocked_user_ids"`
+	// UpdateRateLimit is the number of updates processed per user per minute; 0 disables the limit
+	UpdateRateLimit int `mapstructure:"update_rate_limit"`
+	// OperatorChatID is the group where conversations are handed off to human operators; 0 disables handoff
+	OperatorChatID int64 `mapstructure:"operator_chat_id"`
+	// HandoffMarker in an AI answer hands the conversation off to an operator
+	HandoffMarker string `mapstructure:"handoff_marker"`
+	// HandoffHistory is the number of recent messages shown to operators with a new ticket
+	HandoffHistory int `mapstructure:"handoff_history"`
+	// InlineRateLimit is the number of inline queries answered by the AI per user per minute; 0 disables the limit
+	InlineRateLimit int `mapstructure:"inline_rate_limit"`
+	// InlineDebounce is how long a user must stop typing before an inline query is answered
+	InlineDebounce time.Duration `mapstructure:"inline_debounce"`
+	// InlineCacheTTL is how long inline answers are reused for the same query of a user
+	InlineCacheTTL time.Duration `mapstructure:"inline_cache_ttl"`
+	// CommandRateLimit is the number of commands a user may run per minute; 0 disables the limit
+	CommandRateLimit int `mapstructure:"command_rate_limit"`
+	// BroadcastRate is the number of messages per second sent by /broadcast
+	BroadcastRate float64 `mapstructure:"broadcast_rate"`
+	// LocalesDir holds message catalogs per language, e.g. "ru.yaml"
+	LocalesDir string `mapstructure:"locales_dir"`
+	// DefaultLanguage is the catalog used when the user's language has none
+	DefaultLanguage string `mapstructure:"default_language"`
+	// Timezone is the IANA timezone of users who have not chosen one with /timezone
+	Timezone string `mapstructure:"timezone"`
+	// ReminderInterval is how often the scheduler looks for due reminders
+	ReminderInterval time.Duration `mapstructure:"reminder_interval"`
+	// MaxReminders is the number of pending reminders a user may have; 0 disables the limit
+	MaxReminders int `mapstructure:"max_reminders"`
+	// ReminderTriggers start messages that set a reminder, e.g. "remind me"
+	ReminderTriggers []string `mapstructure:"reminder_triggers"`
+	// Schedules are jobs, set in config.yaml, posting AI answers to chats on cron schedules
+	Schedules []ScheduleJob `mapstructure:"schedules"`
+	// ScheduleMinInterval is the shortest time allowed between runs of a job; 0 disables the limit
+	ScheduleMinInterval time.Duration `mapstructure:"schedule_min_interval"`
+}
+
+// ScheduleJob posts the AI answer to a prompt to a chat on a cron schedule
+type ScheduleJob struct {
+	// Name identifies the job in logs and /schedule list
+	Name   string `mapstructure:"name"`
+	ChatID int64  `mapstructure:"chat_id"`
+	// Schedule is a cron expression like "0 9 * * *" or a descriptor like "@daily"
+	Schedule string `mapstructure:"schedule"`
+	Prompt   string `mapstructure:"prompt"`
+	// Persona answers the prompt; empty uses the chat's persona
+	Persona string `mapstructure:"persona"`
+}
+
+// StorageConfig holds persistence configuration
+type StorageConfig struct {
+	Backend         string        `mapstructure:"backend"`
+	Path            string        `mapstructure:"path"`
+	HistoryLimit    int           `mapstructure:"history_limit"`
+	MaxMessages     int           `mapstructure:"max_messages"`
+	RetentionDays   int           `mapstructure:"retention_days"`
+	JanitorInterval time.Duration `mapstructure:"janitor_interval"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// UsageConfig holds usage and cost accounting configuration
+type UsageConfig struct {
+	Currency string `mapstructure:"currency"`
+	// Prices lists model prices per million tokens, e.g. "gpt-4o=2.5/10,gpt-4o-mini=0.15/0.6"
+	Prices     string           `mapstructure:"prices"`
+	PriceTable usage.PriceTable `mapstructure:"-"`
+}
+
+// KnowledgeConfig holds knowledge base retrieval configuration
+type KnowledgeConfig struct {
+	// Dir holds the Markdown and text documents; empty disables the knowledge base
+	Dir string `mapstructure:"dir"`
+	// ChunkSize is the maximum number of characters in an indexed chunk
+	ChunkSize int `mapstructure:"chunk_size"`
+	// TopK is the number of chunks added to the system prompt per request
+	TopK int `mapstructure:"top_k"`
+	// EmbeddingsModel enables ranking by embeddings in addition to keywords;
+	// the URL and API key default to the AI provider's
+	EmbeddingsModel  string `mapstructure:"embeddings_model"`
+	EmbeddingsURL    string `mapstructure:"embeddings_url"`
+	EmbeddingsAPIKey string `mapstructure:"embeddings_api_key"`
 }
 
 // Load loads configuration from environment variables and config file
@@ -73,14 +270,116 @@ func Load() (*Config, error) {
 	viper.SetDefault("telegram.webhook_path", "/webhook")
 	viper.SetDefault("server.address", ":8080")
 	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("logging.log_message_content", true)
 	viper.SetDefault("ai.model", "gpt-3.5-turbo")
+	viper.SetDefault("ai.redact_pii", false)
+	viper.SetDefault("ai.temperature", 0.7)
+	viper.SetDefault("ai.max_tokens", 1000)
+	viper.SetDefault("ai.answer_in_user_language", false)
+	viper.SetDefault("ai.inline_prompt", "This answer will be inserted into a chat from inline mode. Answer in at most three short sentences, without headings, tables or long lists.")
+	viper.SetDefault("ai.inline_max_tokens", 300)
+	viper.SetDefault("ai.tool_max_iterations", 5)
+	viper.SetDefault("ai.tool_timezone", "UTC")
+	viper.SetDefault("ai.show_tool_calls", false)
+	viper.SetDefault("storage.backend", "memory")
+	viper.SetDefault("storage.path", "data/bot.db")
+	viper.SetDefault("storage.history_limit", 20)
+	viper.SetDefault("storage.max_messages", 200)
+	viper.SetDefault("storage.retention_days", 0)
+	viper.SetDefault("storage.janitor_interval", "1h")
+	viper.SetDefault("bot.daily_message_limit", 0)
+	viper.SetDefault("bot.broadcast_rate", 25)
+	viper.SetDefault("bot.command_rate_limit", 20)
+	viper.SetDefault("bot.update_rate_limit", 30)
+	viper.SetDefault("bot.answer_actions", true)
+	viper.SetDefault("bot.inline_rate_limit", 10)
+	viper.SetDefault("bot.inline_debounce", "700ms")
+	viper.SetDefault("bot.inline_cache_ttl", "10m")
+	viper.SetDefault("bot.handoff_marker", "[OPERATOR]")
+	viper.SetDefault("bot.handoff_history", 10)
+	viper.SetDefault("bot.timezone", "UTC")
+	viper.SetDefault("bot.reminder_interval", "30s")
+	viper.SetDefault("bot.schedule_min_interval", "1h")
+	viper.SetDefault("bot.max_reminders", 20)
+	viper.SetDefault("bot.reminder_triggers", []string{"remind me", "напомни"})
+	viper.SetDefault("tracing.exporter", "none")
+	viper.SetDefault("tracing.service_name", "universal-ai-bot")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("usage.currency", "USD")
+	viper.SetDefault("knowledge.chunk_size", 800)
+	viper.SetDefault("knowledge.top_k", 4)
 
 	// Bot message defaults
 	viper.SetDefault("bot.start_message", "🤖 Hello! I'm a universal AI assistant.\n\n💡 Just send me a message and I'll help you with any questions!\n\nUse /help for additional information.")
-	viper.SetDefault("bot.help_message", "📚 AI Assistant Help:\n\n💬 **Any message** → Get a smart response:\n• Answer questions\n• Help with tasks\n• Explanations and advice\n• Creative ideas\n\n🔧 **Available commands:**\n• /start - Start working with the bot\n• /help - Show this help\n\n💡 Just send text - I'll help right away!")
+	viper.SetDefault("bot.help_message", "📚 AI Assistant Help:\n\n💬 **Any message** → Get a smart response:\n• Answer questions\n• Help with tasks\n• Explanations and advice\n• Creative ideas\n\n🔧 **Available commands:**\n%s\n\n💡 Just send text - I'll help right away!")
 	viper.SetDefault("bot.unknown_command_message", "❓ Unknown command. Use /help to get information about bot capabilities.")
 	viper.SetDefault("bot.error_message", "Sorry, an error occurred while processing your message. Please try again.")
 	viper.SetDefault("bot.empty_message", "Please send a text message.")
+	viper.SetDefault("bot.quota_exceeded_message", "⏳ You have reached your daily message limit. Please try again tomorrow.")
+	viper.SetDefault("bot.export_usage_message", "Usage: /export [md|json]")
+	viper.SetDefault("bot.export_empty_message", "📭 There is no conversation to export yet.")
+	viper.SetDefault("bot.import_usage_message", "📎 Send a JSON file from /export with the caption /import, or reply /import to such a file.")
+	viper.SetDefault("bot.import_success_message", "✅ Conversation restored. I'll continue from where it left off.")
+	viper.SetDefault("bot.import_failed_message", "❌ Could not import this file. Please send a JSON transcript created with /export json.")
+	viper.SetDefault("bot.import_restricted_message", "🔒 This chat already has a conversation. Only admins can replace it with an import.")
+	viper.SetDefault("bot.forget_message", "🗑 All data stored about you has been erased.")
+	viper.SetDefault("bot.admin_only_message", "⛔ This command is available to administrators only.")
+	viper.SetDefault("bot.usage_syntax_message", "Usage: /usage [user_id] [today|yesterday|week|month|YYYY-MM|YYYY-MM-DD|FROM..TO] [csv]")
+	viper.SetDefault("bot.usage_empty_message", "📭 No usage recorded for this period.")
+	viper.SetDefault("bot.broadcast_syntax_message", "Usage: /broadcast [--dry-run] <text>")
+	viper.SetDefault("bot.schedule_syntax_message", "Usage:\n`/schedule add [chat=<id>] [persona=<name>] <schedule> <prompt>`, e.g. `/schedule add persona=english-teacher 0 9 * * * Post the English word of the day`\n`/schedule list`\n`/schedule remove <id>`")
+	viper.SetDefault("bot.schedule_invalid_message", "❌ Invalid schedule %q: %v")
+	viper.SetDefault("bot.schedule_frequent_message", "❌ Jobs may run at most once every %s.")
+	viper.SetDefault("bot.schedule_persona_message", "❌ Unknown persona %q.")
+	viper.SetDefault("bot.schedule_added_message", "✅ Schedule #%d added for chat %d, next run %s.")
+	viper.SetDefault("bot.schedule_list_message", "🗓 Scheduled jobs:\n\n%s")
+	viper.SetDefault("bot.schedule_empty_message", "📭 No scheduled jobs. Add one with /schedule add.")
+	viper.SetDefault("bot.schedule_not_found_message", "❓ No schedule #%d. Jobs from config.yaml are removed by editing the file.")
+	viper.SetDefault("bot.schedule_removed_message", "🗑 Schedule #%d removed.")
+	viper.SetDefault("bot.persona_list_message", "🎭 Choose a persona for this chat:")
+	viper.SetDefault("bot.persona_switched_message", "✅ Persona switched to %s.")
+	viper.SetDefault("bot.persona_unknown_message", "❓ Unknown persona. Use /persona to see the available ones.")
+	viper.SetDefault("bot.persona_disabled_message", "Personas are not configured for this bot.")
+	viper.SetDefault("bot.language_list_message", "🌐 Choose your language:")
+	viper.SetDefault("bot.language_switched_message", "✅ Language set to %s.")
+	viper.SetDefault("bot.language_unknown_message", "❓ Unknown language. Use /language to see the available ones.")
+	viper.SetDefault("bot.language_disabled_message", "Languages are not configured for this bot.")
+	viper.SetDefault("bot.command_chat_type_message", "💬 This command is not available in this chat.")
+	viper.SetDefault("bot.command_rate_limit_message", "⏳ Too many commands. Please wait a minute and try again.")
+	viper.SetDefault("bot.access_denied_message", "🔒 This bot is private.")
+	viper.SetDefault("bot.answer_expired_message", "This answer can no longer be changed.")
+	viper.SetDefault("bot.answer_not_yours_message", "Only the person who asked can change this answer.")
+	viper.SetDefault("bot.inline_rate_limit_message", "⏳ Too many inline requests. Tap to chat with me instead.")
+	viper.SetDefault("bot.operator_connected_message", "👩‍💼 I've passed our conversation to a human operator. They will answer here, and I'll forward your messages to them until they're done.")
+	viper.SetDefault("bot.operator_waiting_message", "👩‍💼 An operator already has your conversation. Just write here and I'll forward your message.")
+	viper.SetDefault("bot.operator_closed_message", "✅ The operator has closed this conversation. I'm back to help you!")
+	viper.SetDefault("bot.ticket_usage_message", "Reply in this thread to answer; the user sees your messages as the bot's. /close closes the ticket.")
+	viper.SetDefault("bot.ticket_undelivered_message", "⚠️ Could not deliver this reply to the user. Please try again later.")
+	viper.SetDefault("bot.ticket_close_usage_message", "Send /close in a ticket thread or as a reply to a ticket message.")
+	viper.SetDefault("bot.ticket_closed_message", "✅ Ticket #%d closed by %s.")
+	viper.SetDefault("bot.reminder_syntax_message", "Usage: /remind <when> <text>, e.g. /remind 2h call Bob, /remind 18:30 take the pills or /remind tomorrow at 9 call Bob")
+	viper.SetDefault("bot.reminder_set_message", "⏰ I'll remind you on %s: %s")
+	viper.SetDefault("bot.reminder_time_message", "❓ I couldn't tell when to remind you. Try e.g. /remind 2h call Bob.")
+	viper.SetDefault("bot.reminder_past_message", "⏰ That time has already passed. Please choose a time in the future.")
+	viper.SetDefault("bot.reminder_limit_message", "⏳ You have too many reminders. Cancel some with /reminders first.")
+	viper.SetDefault("bot.reminder_list_message", "⏰ Your reminders, tap one to cancel it:\n\n%s")
+	viper.SetDefault("bot.reminder_empty_message", "📭 You have no reminders. Set one with /remind.")
+	viper.SetDefault("bot.reminder_canceled_message", "🗑 Reminder canceled.")
+	viper.SetDefault("bot.reminder_message", "⏰ Reminder: %s")
+	viper.SetDefault("bot.timezone_message", "🕒 Your timezone is %s. Change it with /timezone <name>, e.g. /timezone Europe/Berlin.")
+	viper.SetDefault("bot.timezone_switched_message", "✅ Timezone set to %s.")
+	viper.SetDefault("bot.timezone_unknown_message", "❓ Unknown timezone. Use a name like Europe/Berlin or America/New_York.")
+	viper.SetDefault("bot.broadcast_busy_message", "⏳ A broadcast is already in progress. Please wait until it finishes.")
+	viper.SetDefault("bot.broadcast_dry_run_message", "🧪 Dry run: the message below would be sent to %d chats (%d blocked chats skipped).")
+	viper.SetDefault("bot.broadcast_started_message", "📣 Broadcasting to %d chats…")
+	viper.SetDefault("bot.broadcast_progress_message", "📣 Broadcasting: %d/%d sent, %d blocked, %d failed")
+	viper.SetDefault("bot.broadcast_finished_message", "✅ Broadcast finished: %d/%d sent, %d blocked, %d failed")
+	viper.SetDefault("bot.reload_success_message", "✅ Prompts and bot messages reloaded (%d personas).")
+	viper.SetDefault("bot.reload_failed_message", "❌ Reload failed, the current configuration stays active. See the logs for details.")
+	viper.SetDefault("bot.reindex_success_message", "✅ Knowledge base reindexed in %s: %d files, %d chunks, search by keywords.")
+	viper.SetDefault("bot.reindex_embedded_message", "✅ Knowledge base reindexed in %s: %d files, %d chunks, search by keywords and embeddings.")
+	viper.SetDefault("bot.reindex_fallback_message", "⚠️ Knowledge base reindexed in %s: %d files, %d chunks, search by keywords only, embedding failed (see the logs).")
+	viper.SetDefault("bot.reindex_failed_message", "❌ Reindexing failed, the current index stays active. See the logs for details.")
 
 	// Bind environment variables
 	viper.AutomaticEnv()
@@ -97,16 +396,136 @@ func Load() (*Config, error) {
 	_ = viper.BindEnv("telegram.webhook_path", "TELEGRAM_WEBHOOK_PATH")
 	_ = viper.BindEnv("server.address", "SERVER_ADDRESS")
 	_ = viper.BindEnv("logging.level", "LOG_LEVEL")
+	_ = viper.BindEnv("logging.log_message_content", "LOG_MESSAGE_CONTENT")
 	_ = viper.BindEnv("ai.url", "AI_URL")
 	_ = viper.BindEnv("ai.model", "AI_MODEL")
 	_ = viper.BindEnv("ai.api_key", "AI_API_KEY")
 	_ = viper.BindEnv("ai.prompt", "AI_PROMPT")
 	_ = viper.BindEnv("ai.prompt_file", "AI_PROMPT_FILE")
+	_ = viper.BindEnv("ai.prompts_dir", "AI_PROMPTS_DIR")
+	_ = viper.BindEnv("ai.redact_pii", "AI_REDACT_PII")
+	_ = viper.BindEnv("ai.temperature", "AI_TEMPERATURE")
+	_ = viper.BindEnv("ai.max_tokens", "AI_MAX_TOKENS")
+	_ = viper.BindEnv("ai.prompt_vars", "AI_PROMPT_VARS")
+	_ = viper.BindEnv("ai.answer_in_user_language", "AI_ANSWER_IN_USER_LANGUAGE")
+	_ = viper.BindEnv("ai.inline_prompt", "AI_INLINE_PROMPT")
+	_ = viper.BindEnv("ai.inline_max_tokens", "AI_INLINE_MAX_TOKENS")
+	_ = viper.BindEnv("ai.tools", "AI_TOOLS")
+	_ = viper.BindEnv("ai.tool_max_iterations", "AI_TOOL_MAX_ITERATIONS")
+	_ = viper.BindEnv("ai.tool_timezone", "AI_TOOL_TIMEZONE")
+	_ = viper.BindEnv("ai.tool_http_domains", "AI_TOOL_HTTP_DOMAINS")
+	_ = viper.BindEnv("ai.show_tool_calls", "AI_SHOW_TOOL_CALLS")
 	_ = viper.BindEnv("bot.start_message", "BOT_START_MESSAGE")
 	_ = viper.BindEnv("bot.help_message", "BOT_HELP_MESSAGE")
 	_ = viper.BindEnv("bot.unknown_command_message", "BOT_UNKNOWN_COMMAND_MESSAGE")
 	_ = viper.BindEnv("bot.error_message", "BOT_ERROR_MESSAGE")
 	_ = viper.BindEnv("bot.empty_message", "BOT_EMPTY_MESSAGE")
+	_ = viper.BindEnv("bot.quota_exceeded_message", "BOT_QUOTA_EXCEEDED_MESSAGE")
+	_ = viper.BindEnv("bot.export_usage_message", "BOT_EXPORT_USAGE_MESSAGE")
+	_ = viper.BindEnv("bot.export_empty_message", "BOT_EXPORT_EMPTY_MESSAGE")
+	_ = viper.BindEnv("bot.import_usage_message", "BOT_IMPORT_USAGE_MESSAGE")
+	_ = viper.BindEnv("bot.import_success_message", "BOT_IMPORT_SUCCESS_MESSAGE")
+	_ = viper.BindEnv("bot.import_failed_message", "BOT_IMPORT_FAILED_MESSAGE")
+	_ = viper.BindEnv("bot.import_restricted_message", "BOT_IMPORT_RESTRICTED_MESSAGE")
+	_ = viper.BindEnv("bot.forget_message", "BOT_FORGET_MESSAGE")
+	_ = viper.BindEnv("bot.admin_only_message", "BOT_ADMIN_ONLY_MESSAGE")
+	_ = viper.BindEnv("bot.usage_syntax_message", "BOT_USAGE_SYNTAX_MESSAGE")
+	_ = viper.BindEnv("bot.usage_empty_message", "BOT_USAGE_EMPTY_MESSAGE")
+	_ = viper.BindEnv("bot.daily_message_limit", "BOT_DAILY_MESSAGE_LIMIT")
+	_ = viper.BindEnv("bot.admin_ids", "BOT_ADMIN_IDS")
+	_ = viper.BindEnv("bot.allowed_commands", "BOT_ALLOWED_COMMANDS")
+	_ = viper.BindEnv("bot.broadcast_syntax_message", "BOT_BROADCAST_SYNTAX_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_syntax_message", "BOT_SCHEDULE_SYNTAX_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_invalid_message", "BOT_SCHEDULE_INVALID_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_frequent_message", "BOT_SCHEDULE_FREQUENT_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_persona_message", "BOT_SCHEDULE_PERSONA_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_added_message", "BOT_SCHEDULE_ADDED_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_list_message", "BOT_SCHEDULE_LIST_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_empty_message", "BOT_SCHEDULE_EMPTY_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_not_found_message", "BOT_SCHEDULE_NOT_FOUND_MESSAGE")
+	_ = viper.BindEnv("bot.schedule_removed_message", "BOT_SCHEDULE_REMOVED_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_busy_message", "BOT_BROADCAST_BUSY_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_dry_run_message", "BOT_BROADCAST_DRY_RUN_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_started_message", "BOT_BROADCAST_STARTED_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_progress_message", "BOT_BROADCAST_PROGRESS_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_finished_message", "BOT_BROADCAST_FINISHED_MESSAGE")
+	_ = viper.BindEnv("bot.reload_success_message", "BOT_RELOAD_SUCCESS_MESSAGE")
+	_ = viper.BindEnv("bot.reload_failed_message", "BOT_RELOAD_FAILED_MESSAGE")
+	_ = viper.BindEnv("bot.reindex_success_message", "BOT_REINDEX_SUCCESS_MESSAGE")
+	_ = viper.BindEnv("bot.reindex_embedded_message", "BOT_REINDEX_EMBEDDED_MESSAGE")
+	_ = viper.BindEnv("bot.reindex_fallback_message", "BOT_REINDEX_FALLBACK_MESSAGE")
+	_ = viper.BindEnv("bot.reindex_failed_message", "BOT_REINDEX_FAILED_MESSAGE")
+	_ = viper.BindEnv("bot.broadcast_rate", "BOT_BROADCAST_RATE")
+	_ = viper.BindEnv("bot.persona_list_message", "BOT_PERSONA_LIST_MESSAGE")
+	_ = viper.BindEnv("bot.persona_switched_message", "BOT_PERSONA_SWITCHED_MESSAGE")
+	_ = viper.BindEnv("bot.persona_unknown_message", "BOT_PERSONA_UNKNOWN_MESSAGE")
+	_ = viper.BindEnv("bot.persona_disabled_message", "BOT_PERSONA_DISABLED_MESSAGE")
+	_ = viper.BindEnv("bot.language_list_message", "BOT_LANGUAGE_LIST_MESSAGE")
+	_ = viper.BindEnv("bot.language_switched_message", "BOT_LANGUAGE_SWITCHED_MESSAGE")
+	_ = viper.BindEnv("bot.language_unknown_message", "BOT_LANGUAGE_UNKNOWN_MESSAGE")
+	_ = viper.BindEnv("bot.language_disabled_message", "BOT_LANGUAGE_DISABLED_MESSAGE")
+	_ = viper.BindEnv("bot.command_chat_type_message", "BOT_COMMAND_CHAT_TYPE_MESSAGE")
+	_ = viper.BindEnv("bot.command_rate_limit_message", "BOT_COMMAND_RATE_LIMIT_MESSAGE")
+	_ = viper.BindEnv("bot.command_rate_limit", "BOT_COMMAND_RATE_LIMIT")
+	_ = viper.BindEnv("bot.update_rate_limit", "BOT_UPDATE_RATE_LIMIT")
+	_ = viper.BindEnv("bot.allowed_user_ids", "BOT_ALLOWED_USER_IDS")
+	_ = viper.BindEnv("bot.allowed_chat_ids", "BOT_ALLOWED_CHAT_IDS")
+	_ = viper.BindEnv("bot.blocked_user_ids", "BOT_BLOCKED_USER_IDS")
+	_ = viper.BindEnv("bot.access_denied_message", "BOT_ACCESS_DENIED_MESSAGE")
+	_ = viper.BindEnv("bot.answer_actions", "BOT_ANSWER_ACTIONS")
+	_ = viper.BindEnv("bot.answer_expired_message", "BOT_ANSWER_EXPIRED_MESSAGE")
+	_ = viper.BindEnv("bot.answer_not_yours_message", "BOT_ANSWER_NOT_YOURS_MESSAGE")
+	_ = viper.BindEnv("bot.inline_rate_limit", "BOT_INLINE_RATE_LIMIT")
+	_ = viper.BindEnv("bot.inline_rate_limit_message", "BOT_INLINE_RATE_LIMIT_MESSAGE")
+	_ = viper.BindEnv("bot.inline_debounce", "BOT_INLINE_DEBOUNCE")
+	_ = viper.BindEnv("bot.inline_cache_ttl", "BOT_INLINE_CACHE_TTL")
+	_ = viper.BindEnv("bot.operator_chat_id", "BOT_OPERATOR_CHAT_ID")
+	_ = viper.BindEnv("bot.handoff_marker", "BOT_HANDOFF_MARKER")
+	_ = viper.BindEnv("bot.handoff_history", "BOT_HANDOFF_HISTORY")
+	_ = viper.BindEnv("bot.operator_connected_message", "BOT_OPERATOR_CONNECTED_MESSAGE")
+	_ = viper.BindEnv("bot.operator_waiting_message", "BOT_OPERATOR_WAITING_MESSAGE")
+	_ = viper.BindEnv("bot.operator_closed_message", "BOT_OPERATOR_CLOSED_MESSAGE")
+	_ = viper.BindEnv("bot.ticket_usage_message", "BOT_TICKET_USAGE_MESSAGE")
+	_ = viper.BindEnv("bot.ticket_undelivered_message", "BOT_TICKET_UNDELIVERED_MESSAGE")
+	_ = viper.BindEnv("bot.ticket_close_usage_message", "BOT_TICKET_CLOSE_USAGE_MESSAGE")
+	_ = viper.BindEnv("bot.ticket_closed_message", "BOT_TICKET_CLOSED_MESSAGE")
+	_ = viper.BindEnv("bot.timezone", "BOT_TIMEZONE")
+	_ = viper.BindEnv("bot.reminder_interval", "BOT_REMINDER_INTERVAL")
+	_ = viper.BindEnv("bot.schedule_min_interval", "BOT_SCHEDULE_MIN_INTERVAL")
+	_ = viper.BindEnv("bot.max_reminders", "BOT_MAX_REMINDERS")
+	_ = viper.BindEnv("bot.reminder_triggers", "BOT_REMINDER_TRIGGERS")
+	_ = viper.BindEnv("bot.reminder_syntax_message", "BOT_REMINDER_SYNTAX_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_set_message", "BOT_REMINDER_SET_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_time_message", "BOT_REMINDER_TIME_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_past_message", "BOT_REMINDER_PAST_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_limit_message", "BOT_REMINDER_LIMIT_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_list_message", "BOT_REMINDER_LIST_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_empty_message", "BOT_REMINDER_EMPTY_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_canceled_message", "BOT_REMINDER_CANCELED_MESSAGE")
+	_ = viper.BindEnv("bot.reminder_message", "BOT_REMINDER_MESSAGE")
+	_ = viper.BindEnv("bot.timezone_message", "BOT_TIMEZONE_MESSAGE")
+	_ = viper.BindEnv("bot.timezone_switched_message", "BOT_TIMEZONE_SWITCHED_MESSAGE")
+	_ = viper.BindEnv("bot.timezone_unknown_message", "BOT_TIMEZONE_UNKNOWN_MESSAGE")
+	_ = viper.BindEnv("bot.locales_dir", "BOT_LOCALES_DIR")
+	_ = viper.BindEnv("bot.default_language", "BOT_DEFAULT_LANGUAGE")
+	_ = viper.BindEnv("storage.backend", "STORAGE_BACKEND")
+	_ = viper.BindEnv("storage.path", "STORAGE_PATH")
+	_ = viper.BindEnv("storage.history_limit", "STORAGE_HISTORY_LIMIT")
+	_ = viper.BindEnv("storage.max_messages", "STORAGE_MAX_MESSAGES")
+	_ = viper.BindEnv("storage.retention_days", "STORAGE_RETENTION_DAYS")
+	_ = viper.BindEnv("storage.janitor_interval", "STORAGE_JANITOR_INTERVAL")
+	_ = viper.BindEnv("tracing.exporter", "TRACING_EXPORTER")
+	_ = viper.BindEnv("tracing.endpoint", "TRACING_ENDPOINT")
+	_ = viper.BindEnv("tracing.service_name", "TRACING_SERVICE_NAME")
+	_ = viper.BindEnv("tracing.sample_ratio", "TRACING_SAMPLE_RATIO")
+	_ = viper.BindEnv("usage.currency", "USAGE_CURRENCY")
+	_ = viper.BindEnv("usage.prices", "USAGE_PRICES")
+	_ = viper.BindEnv("knowledge.dir", "KNOWLEDGE_DIR")
+	_ = viper.BindEnv("knowledge.chunk_size", "KNOWLEDGE_CHUNK_SIZE")
+	_ = viper.BindEnv("knowledge.top_k", "KNOWLEDGE_TOP_K")
+	_ = viper.BindEnv("knowledge.embeddings_model", "KNOWLEDGE_EMBEDDINGS_MODEL")
+	_ = viper.BindEnv("knowledge.embeddings_url", "KNOWLEDGE_EMBEDDINGS_URL")
+	_ = viper.BindEnv("knowledge.embeddings_api_key", "KNOWLEDGE_EMBEDDINGS_API_KEY")
 
 	// Set config file
 	viper.SetConfigName("config")
@@ -133,7 +552,7 @@ func Load() (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to load prompt from file: %w", err)
 		}
-		config.AI.Prompt = promptFromFile
+		applyPromptFile(&config, promptFromFile)
 	}
 
 	// Process newlines in bot messages
@@ -142,6 +561,93 @@ func Load() (*Config, error) {
 	config.Bot.UnknownCommandMessage = processNewlines(config.Bot.UnknownCommandMessage)
 	config.Bot.ErrorMessage = processNewlines(config.Bot.ErrorMessage)
 	config.Bot.EmptyMessage = processNewlines(config.Bot.EmptyMessage)
+	config.Bot.QuotaExceededMessage = processNewlines(config.Bot.QuotaExceededMessage)
+	config.Bot.ExportUsageMessage = processNewlines(config.Bot.ExportUsageMessage)
+	config.Bot.ExportEmptyMessage = processNewlines(config.Bot.ExportEmptyMessage)
+	config.Bot.ImportUsageMessage = processNewlines(config.Bot.ImportUsageMessage)
+	config.Bot.ImportSuccessMessage = processNewlines(config.Bot.ImportSuccessMessage)
+	config.Bot.ImportFailedMessage = processNewlines(config.Bot.ImportFailedMessage)
+	config.Bot.ImportRestrictedMessage = processNewlines(config.Bot.ImportRestrictedMessage)
+	config.Bot.ForgetMessage = processNewlines(config.Bot.ForgetMessage)
+	config.Bot.AdminOnlyMessage = processNewlines(config.Bot.AdminOnlyMessage)
+	config.Bot.UsageSyntaxMessage = processNewlines(config.Bot.UsageSyntaxMessage)
+	config.Bot.UsageEmptyMessage = processNewlines(config.Bot.UsageEmptyMessage)
+	config.Bot.BroadcastSyntaxMessage = processNewlines(config.Bot.BroadcastSyntaxMessage)
+	config.Bot.ScheduleSyntaxMessage = processNewlines(config.Bot.ScheduleSyntaxMessage)
+	config.Bot.ScheduleInvalidMessage = processNewlines(config.Bot.ScheduleInvalidMessage)
+	config.Bot.ScheduleFrequentMessage = processNewlines(config.Bot.ScheduleFrequentMessage)
+	config.Bot.SchedulePersonaMessage = processNewlines(config.Bot.SchedulePersonaMessage)
+	config.Bot.ScheduleAddedMessage = processNewlines(config.Bot.ScheduleAddedMessage)
+	config.Bot.ScheduleListMessage = processNewlines(config.Bot.ScheduleListMessage)
+	config.Bot.ScheduleEmptyMessage = processNewlines(config.Bot.ScheduleEmptyMessage)
+	config.Bot.ScheduleNotFoundMessage = processNewlines(config.Bot.ScheduleNotFoundMessage)
+	config.Bot.ScheduleRemovedMessage = processNewlines(config.Bot.ScheduleRemovedMessage)
+	config.Bot.BroadcastBusyMessage = processNewlines(config.Bot.BroadcastBusyMessage)
+	config.Bot.BroadcastDryRunMessage = processNewlines(config.Bot.BroadcastDryRunMessage)
+	config.Bot.BroadcastStartedMessage = processNewlines(config.Bot.BroadcastStartedMessage)
+	config.Bot.BroadcastProgressMessage = processNewlines(config.Bot.BroadcastProgressMessage)
+	config.Bot.BroadcastFinishedMessage = processNewlines(config.Bot.BroadcastFinishedMessage)
+	config.Bot.ReloadSuccessMessage = processNewlines(config.Bot.ReloadSuccessMessage)
+	config.Bot.ReloadFailedMessage = processNewlines(config.Bot.ReloadFailedMessage)
+	config.Bot.ReindexSuccessMessage = processNewlines(config.Bot.ReindexSuccessMessage)
+	config.Bot.ReindexEmbeddedMessage = processNewlines(config.Bot.ReindexEmbeddedMessage)
+	config.Bot.ReindexFallbackMessage = processNewlines(config.Bot.ReindexFallbackMessage)
+	config.Bot.ReindexFailedMessage = processNewlines(config.Bot.ReindexFailedMessage)
+	config.Bot.PersonaListMessage = processNewlines(config.Bot.PersonaListMessage)
+	config.Bot.PersonaSwitchedMessage = processNewlines(config.Bot.PersonaSwitchedMessage)
+	config.Bot.PersonaUnknownMessage = processNewlines(config.Bot.PersonaUnknownMessage)
+	config.Bot.PersonaDisabledMessage = processNewlines(config.Bot.PersonaDisabledMessage)
+	config.Bot.LanguageListMessage = processNewlines(config.Bot.LanguageListMessage)
+	config.Bot.LanguageSwitchedMessage = processNewlines(config.Bot.LanguageSwitchedMessage)
+	config.Bot.LanguageUnknownMessage = processNewlines(config.Bot.LanguageUnknownMessage)
+	config.Bot.LanguageDisabledMessage = processNewlines(config.Bot.LanguageDisabledMessage)
+	config.Bot.CommandChatTypeMessage = processNewlines(config.Bot.CommandChatTypeMessage)
+	config.Bot.CommandRateLimitMessage = processNewlines(config.Bot.CommandRateLimitMessage)
+	config.Bot.AccessDeniedMessage = processNewlines(config.Bot.AccessDeniedMessage)
+	config.Bot.AnswerExpiredMessage = processNewlines(config.Bot.AnswerExpiredMessage)
+	config.Bot.AnswerNotYoursMessage = processNewlines(config.Bot.AnswerNotYoursMessage)
+	config.Bot.InlineRateLimitMessage = processNewlines(config.Bot.InlineRateLimitMessage)
+	config.Bot.OperatorConnectedMessage = processNewlines(config.Bot.OperatorConnectedMessage)
+	config.Bot.OperatorWaitingMessage = processNewlines(config.Bot.OperatorWaitingMessage)
+	config.Bot.OperatorClosedMessage = processNewlines(config.Bot.OperatorClosedMessage)
+	config.Bot.TicketUsageMessage = processNewlines(config.Bot.TicketUsageMessage)
+	config.Bot.TicketUndeliveredMessage = processNewlines(config.Bot.TicketUndeliveredMessage)
+	config.Bot.TicketCloseUsageMessage = processNewlines(config.Bot.TicketCloseUsageMessage)
+	config.Bot.TicketClosedMessage = processNewlines(config.Bot.TicketClosedMessage)
+	config.Bot.ReminderSyntaxMessage = processNewlines(config.Bot.ReminderSyntaxMessage)
+	config.Bot.ReminderSetMessage = processNewlines(config.Bot.ReminderSetMessage)
+	config.Bot.ReminderTimeMessage = processNewlines(config.Bot.ReminderTimeMessage)
+	config.Bot.ReminderPastMessage = processNewlines(config.Bot.ReminderPastMessage)
+	config.Bot.ReminderLimitMessage = processNewlines(config.Bot.ReminderLimitMessage)
+	config.Bot.ReminderListMessage = processNewlines(config.Bot.ReminderListMessage)
+	config.Bot.ReminderEmptyMessage = processNewlines(config.Bot.ReminderEmptyMessage)
+	config.Bot.ReminderCanceledMessage = processNewlines(config.Bot.ReminderCanceledMessage)
+	config.Bot.ReminderMessage = processNewlines(config.Bot.ReminderMessage)
+	config.Bot.TimezoneMessage = processNewlines(config.Bot.TimezoneMessage)
+	config.Bot.TimezoneSwitchedMessage = processNewlines(config.Bot.TimezoneSwitchedMessage)
+	config.Bot.TimezoneUnknownMessage = processNewlines(config.Bot.TimezoneUnknownMessage)
+
+	// Parse model prices
+	priceTable, err := usage.ParsePriceTable(config.Usage.Prices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage prices: %w", err)
+	}
+	config.Usage.PriceTable = priceTable
+
+	// Parse custom prompt template values
+	vars, err := prompt.ParseVars(config.AI.PromptVars)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ai prompt vars: %w", err)
+	}
+	config.AI.Vars = vars
+
+	// Embeddings come from the AI provider unless configured otherwise
+	if config.Knowledge.EmbeddingsURL == "" {
+		config.Knowledge.EmbeddingsURL = config.AI.URL
+	}
+	if config.Knowledge.EmbeddingsAPIKey == "" {
+		config.Knowledge.EmbeddingsAPIKey = config.AI.APIKey
+	}
 
 	// Validate required fields
 	if config.Telegram.Token == "" {
@@ -156,17 +662,101 @@ func Load() (*Config, error) {
 	if config.AI.Prompt == "" {
 		return nil, fmt.Errorf("ai prompt is required (either AI_PROMPT or AI_PROMPT_FILE must be set)")
 	}
+	if config.Storage.MaxMessages < 0 {
+		return nil, fmt.Errorf("storage max messages must not be negative")
+	}
+	if config.Bot.BroadcastRate <= 0 {
+		return nil, fmt.Errorf("bot broadcast rate must be positive")
+	}
+	if config.Bot.CommandRateLimit < 0 {
+		return nil, fmt.Errorf("bot command rate limit must not be negative")
+	}
+	if config.Bot.UpdateRateLimit < 0 {
+		return nil, fmt.Errorf("bot update rate limit must not be negative")
+	}
+	if config.Bot.InlineRateLimit < 0 {
+		return nil, fmt.Errorf("bot inline rate limit must not be negative")
+	}
+	if _, err := time.LoadLocation(config.Bot.Timezone); err != nil {
+		return nil, fmt.Errorf("invalid bot timezone %q: %w", config.Bot.Timezone, err)
+	}
+	if config.Bot.ReminderInterval <= 0 {
+		return nil, fmt.Errorf("bot reminder interval must be positive")
+	}
+	if config.Bot.MaxReminders < 0 {
+		return nil, fmt.Errorf("bot max reminders must not be negative")
+	}
+	if config.Bot.ScheduleMinInterval < 0 {
+		return nil, fmt.Errorf("bot schedule min interval must not be negative")
+	}
+	if err := validateScheduleJobs(config.Bot.Schedules); err != nil {
+		return nil, err
+	}
+	if config.AI.ToolMaxIterations <= 0 {
+		return nil, fmt.Errorf("ai tool max iterations must be positive")
+	}
+	if config.Knowledge.ChunkSize <= 0 {
+		return nil, fmt.Errorf("knowledge chunk size must be positive")
+	}
+	if config.Knowledge.TopK < 0 {
+		return nil, fmt.Errorf("knowledge top k must not be negative")
+	}
 
 	return &config, nil
 }
 
-// loadPromptFromFile loads prompt content from a file
-func loadPromptFromFile(filePath string) (string, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read prompt file %s: %w", filePath, err)
+// validateScheduleJobs checks that every job has a unique name, a chat, a
+// schedule and a prompt; schedules are parsed by the bot
+func validateScheduleJobs(jobs []ScheduleJob) error {
+	names := make(map[string]bool, len(jobs))
+	for i, job := range jobs {
+		switch {
+		case job.Name == "":
+			return fmt.Errorf("schedule %d has no name", i+1)
+		case names[job.Name]:
+			return fmt.Errorf("schedule %q is defined twice", job.Name)
+		case job.ChatID == 0:
+			return fmt.Errorf("schedule %q has no chat_id", job.Name)
+		case strings.TrimSpace(job.Schedule) == "":
+			return fmt.Errorf("schedule %q has no schedule", job.Name)
+		case strings.TrimSpace(job.Prompt) == "":
+			return fmt.Errorf("schedule %q has no prompt", job.Name)
+		}
+		names[job.Name] = true
+	}
+	return nil
+}
+
+// FileUsed returns the path of the config file read by Load, or "" when there is none
+func FileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
+// loadPromptFromFile loads prompt content and its optional front-matter from a file
+func loadPromptFromFile(filePath string) (*prompt.File, error) {
+	return prompt.ParseFile(filePath)
+}
+
+// applyPromptFile uses the prompt file body as the system prompt; settings from
+// its front-matter take precedence over environment variables
+func applyPromptFile(config *Config, file *prompt.File) {
+	config.AI.Prompt = file.Body
+	config.AI.Examples = file.Examples
+	if file.Model != "" {
+		config.AI.Model = file.Model
+	}
+	if file.Temperature != nil {
+		config.AI.Temperature = *file.Temperature
+	}
+	if file.MaxTokens > 0 {
+		config.AI.MaxTokens = file.MaxTokens
+	}
+	if file.Welcome != "" {
+		config.Bot.StartMessage = file.Welcome
+	}
+	if len(file.Commands) > 0 {
+		config.Bot.AllowedCommands = file.Commands
 	}
-	return strings.TrimSpace(string(content)), nil
 }
 
 // GetEnv returns environment variable value or default
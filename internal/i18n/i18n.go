@@ -0,0 +1,199 @@
+package i18n
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tgbot-skeleton/internal/config"
+
+	"github.com/go-viper/mapstructure/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// messageSuffix ends the keys of translatable BotConfig fields; other settings
+// such as limits and administrators cannot be changed per language
+const messageSuffix = "_message"
+
+// Catalog holds the bot messages of one language
+type Catalog struct {
+	// Code is the normalized language tag from the file name, e.g. "pt-br"
+	Code string
+	// Name is the display name of the language, e.g. "Русский"
+	Name string
+	// Bot is the bot configuration with the messages of this language
+	Bot *config.BotConfig
+	// Commands maps command names to their descriptions in this language
+	Commands map[string]string
+	// Buttons maps button names, e.g. "regenerate", to their labels in this language
+	Buttons map[string]string
+}
+
+// Label returns the display name of the language
+func (c *Catalog) Label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Code
+}
+
+// catalogFile is the layout of a catalog file
+type catalogFile struct {
+	Name     string            `yaml:"name"`
+	Messages map[string]string `yaml:"messages"`
+	Commands map[string]string `yaml:"commands"`
+	Buttons  map[string]string `yaml:"buttons"`
+}
+
+// Bundle holds the catalogs loaded from a locales directory
+type Bundle struct {
+	catalogs map[string]*Catalog
+	codes    []string
+	// fallback is the language used when none of the user's languages has a catalog
+	fallback string
+}
+
+// Load reads every "<language>.yaml", ".yml" or ".json" file in dir as a catalog.
+// Messages missing from a catalog are taken from base. defaultLanguage, when set,
+// must name one of the catalogs
+func Load(dir string, base *config.BotConfig, defaultLanguage string) (*Bundle, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales directory %s: %w", dir, err)
+	}
+
+	bundle := &Bundle{catalogs: make(map[string]*Catalog), fallback: Normalize(defaultLanguage)}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		ext := filepath.Ext(fileName)
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		code := Normalize(strings.TrimSuffix(fileName, ext))
+		if _, ok := bundle.catalogs[code]; ok {
+			return nil, fmt.Errorf("duplicate catalog for language %q", code)
+		}
+		catalog, err := loadCatalog(filepath.Join(dir, fileName), code, base)
+		if err != nil {
+			return nil, err
+		}
+		bundle.catalogs[code] = catalog
+		bundle.codes = append(bundle.codes, code)
+	}
+
+	if bundle.fallback != "" {
+		if _, ok := bundle.catalogs[bundle.fallback]; !ok {
+			return nil, fmt.Errorf("default language %q has no catalog in %s", bundle.fallback, dir)
+		}
+	}
+
+	sort.Strings(bundle.codes)
+	return bundle, nil
+}
+
+// loadCatalog reads one catalog file and applies its messages to a copy of base
+func loadCatalog(path, code string, base *config.BotConfig) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog %s: %w", path, err)
+	}
+
+	var file catalogFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+
+	bot := *base
+	if err := applyMessages(&bot, file.Messages); err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+	return &Catalog{Code: code, Name: strings.TrimSpace(file.Name), Bot: &bot, Commands: file.Commands, Buttons: file.Buttons}, nil
+}
+
+// applyMessages overrides messages by their configuration key, e.g. "start_message"
+func applyMessages(bot *config.BotConfig, messages map[string]string) error {
+	values := make(map[string]any, len(messages))
+	for key, text := range messages {
+		if !strings.HasSuffix(key, messageSuffix) {
+			return fmt.Errorf("%q is not a message", key)
+		}
+		values[key] = text
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:      bot,
+		ErrorUnused: true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(values); err != nil {
+		return fmt.Errorf("unknown message: %w", err)
+	}
+	return nil
+}
+
+// Normalize lowercases a language tag and uses "-" as separator, so "pt_BR"
+// and "pt-br" are the same language
+func Normalize(code string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "_", "-")
+}
+
+// Resolve returns the catalog for a language, falling back from a regional
+// variant to the base language and then to the default language, e.g.
+// "pt-BR" → "pt" → default. It returns nil when none of them has a catalog
+func (b *Bundle) Resolve(code string) *Catalog {
+	if b == nil {
+		return nil
+	}
+
+	code = Normalize(code)
+	for code != "" {
+		if catalog, ok := b.catalogs[code]; ok {
+			return catalog
+		}
+		i := strings.LastIndex(code, "-")
+		if i < 0 {
+			break
+		}
+		code = code[:i]
+	}
+	return b.catalogs[b.fallback]
+}
+
+// Get returns the catalog of exactly this language
+func (b *Bundle) Get(code string) (*Catalog, bool) {
+	if b == nil {
+		return nil, false
+	}
+	catalog, ok := b.catalogs[Normalize(code)]
+	return catalog, ok
+}
+
+// List returns all catalogs ordered by language code
+func (b *Bundle) List() []*Catalog {
+	if b == nil {
+		return nil
+	}
+	list := make([]*Catalog, 0, len(b.codes))
+	for _, code := range b.codes {
+		list = append(list, b.catalogs[code])
+	}
+	return list
+}
+
+// Len returns the number of catalogs
+func (b *Bundle) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.codes)
+}
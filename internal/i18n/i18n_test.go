@@ -0,0 +1,152 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tgbot-skeleton/internal/config"
+)
+
+// writeFiles creates files with the given contents in a temporary directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	return dir
+}
+
+// testBase returns default messages and settings catalogs are built on
+func testBase() *config.BotConfig {
+	return &config.BotConfig{
+		StartMessage: "Hello",
+		HelpMessage:  "Help",
+		AdminIDs:     []int64{1},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"ru.yaml":    "name: Русский\nmessages:\n  start_message: Привет\ncommands:\n  help: Справка\nbuttons:\n  regenerate: Заново\n",
+		"pt_BR.json": `{"name": "Português", "messages": {"help_message": "Ajuda"}}`,
+		"en.yml":     "# uses the default messages\n",
+		"README.md":  "ignored",
+	})
+
+	bundle, err := Load(dir, testBase(), "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if bundle.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", bundle.Len())
+	}
+
+	ru, ok := bundle.Get("RU")
+	if !ok {
+		t.Fatalf("Get(RU) not found")
+	}
+	if ru.Bot.StartMessage != "Привет" || ru.Bot.HelpMessage != "Help" || ru.Label() != "Русский" {
+		t.Errorf("ru = %+v, want translated start and default help", ru.Bot)
+	}
+	if ru.Commands["help"] != "Справка" {
+		t.Errorf("ru commands = %v, want translated help", ru.Commands)
+	}
+	if ru.Buttons["regenerate"] != "Заново" {
+		t.Errorf("ru buttons = %v, want translated regenerate", ru.Buttons)
+	}
+	if len(ru.Bot.AdminIDs) != 1 {
+		t.Errorf("ru settings = %+v, want settings from base", ru.Bot)
+	}
+
+	pt, ok := bundle.Get("pt-br")
+	if !ok || pt.Bot.HelpMessage != "Ajuda" {
+		t.Errorf("Get(pt-br) = %+v, %v, want JSON catalog", pt, ok)
+	}
+}
+
+func TestBundle_Resolve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"en.yaml": "name: English\n",
+		"pt.yaml": "messages:\n  start_message: Olá\n",
+		"ru.yaml": "messages:\n  start_message: Привет\n",
+	})
+
+	tests := []struct {
+		name            string
+		defaultLanguage string
+		code            string
+		expected        string
+	}{
+		{name: "Exact match", code: "ru", expected: "ru"},
+		{name: "Regional variant", code: "pt-BR", expected: "pt"},
+		{name: "Underscore separator", code: "pt_PT", expected: "pt"},
+		{name: "Unknown without default", code: "de", expected: ""},
+		{name: "Empty without default", code: "", expected: ""},
+		{name: "Unknown with default", defaultLanguage: "ru", code: "de", expected: "ru"},
+		{name: "Empty with default", defaultLanguage: "EN", code: "", expected: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle, err := Load(dir, testBase(), tt.defaultLanguage)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			got := ""
+			if catalog := bundle.Resolve(tt.code); catalog != nil {
+				got = catalog.Code
+			}
+			if got != tt.expected {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.code, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           map[string]string
+		defaultLanguage string
+	}{
+		{name: "Unknown message", files: map[string]string{"ru.yaml": "messages:\n  stat_message: x\n"}},
+		{name: "Setting instead of message", files: map[string]string{"ru.yaml": "messages:\n  daily_message_limit: 5\n"}},
+		{name: "Unknown top-level key", files: map[string]string{"ru.yaml": "title: Русский\n"}},
+		{name: "Invalid YAML", files: map[string]string{"ru.yaml": "messages: [\n"}},
+		{name: "Duplicate language", files: map[string]string{"ru.yaml": "name: a\n", "ru.yml": "name: b\n"}},
+		{name: "Default without catalog", files: map[string]string{"ru.yaml": "name: a\n"}, defaultLanguage: "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeFiles(t, tt.files), testBase(), tt.defaultLanguage); err == nil {
+				t.Errorf("Load() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestNilBundle(t *testing.T) {
+	var bundle *Bundle
+	if bundle.Len() != 0 || len(bundle.List()) != 0 || bundle.Resolve("en") != nil {
+		t.Errorf("nil bundle is not empty")
+	}
+	if _, ok := bundle.Get("en"); ok {
+		t.Errorf("nil bundle Get() found a catalog")
+	}
+}
+
+func TestShippedCatalogs(t *testing.T) {
+	bundle, err := Load("../../locales", testBase(), "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok := bundle.Get("ru"); !ok {
+		t.Errorf("shipped catalogs have no Russian translation")
+	}
+}
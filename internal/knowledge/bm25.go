@@ -0,0 +1,108 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: term frequency saturation and document length normalization
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// posting is the frequency of a term in a chunk
+type posting struct {
+	chunk int
+	freq  int
+}
+
+// bm25Index is an inverted index scoring chunks against queries with Okapi BM25
+type bm25Index struct {
+	postings map[string][]posting
+	lengths  []int
+	avgLen   float64
+}
+
+// newBM25Index indexes the text of chunks, including their source and heading
+func newBM25Index(chunks []Chunk) *bm25Index {
+	index := &bm25Index{postings: make(map[string][]posting), lengths: make([]int, len(chunks))}
+	total := 0
+	for i, chunk := range chunks {
+		terms := tokenize(chunk.Label() + " " + chunk.Text)
+		freqs := make(map[string]int, len(terms))
+		for _, term := range terms {
+			freqs[term]++
+		}
+		for term, freq := range freqs {
+			index.postings[term] = append(index.postings[term], posting{chunk: i, freq: freq})
+		}
+		index.lengths[i] = len(terms)
+		total += len(terms)
+	}
+	if len(chunks) > 0 {
+		index.avgLen = float64(total) / float64(len(chunks))
+	}
+	return index
+}
+
+// search returns the indexes of up to limit chunks matching query, best first
+func (idx *bm25Index) search(query string, limit int) []int {
+	n := float64(len(idx.lengths))
+	scores := make(map[int]float64)
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.freq)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(idx.lengths[p.chunk])/idx.avgLen)
+			scores[p.chunk] += idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+	return topScores(scores, limit)
+}
+
+// topScores returns the keys of up to limit best scores, ties broken by key
+func topScores(scores map[int]float64, limit int) []int {
+	ranked := make([]int, 0, len(scores))
+	for i := range scores {
+		ranked = append(ranked, i)
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if scores[ranked[a]] != scores[ranked[b]] {
+			return scores[ranked[a]] > scores[ranked[b]]
+		}
+		return ranked[a] < ranked[b]
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// tokenize lowercases text and splits it into words of letters and digits;
+// single characters other than digits are dropped
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) > 1 || unicode.IsDigit([]rune(word)[0]) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,187 @@
+package knowledge
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// documentExts are the extensions of files indexed from the documents directory
+var documentExts = map[string]bool{".md": true, ".markdown": true, ".txt": true}
+
+// Chunk is a piece of a document small enough to be quoted in a prompt
+type Chunk struct {
+	// Source is the path of the document relative to the documents directory
+	Source string
+	// Heading is the Markdown heading the chunk belongs to, if any
+	Heading string
+	Text    string
+}
+
+// Label names the chunk's source for citations, e.g. "faq.md › Refunds"
+func (c Chunk) Label() string {
+	if c.Heading == "" {
+		return c.Source
+	}
+	return c.Source + " › " + c.Heading
+}
+
+// LoadDir reads the Markdown and text files under dir, recursively, and splits
+// them into chunks of at most chunkSize characters. It returns the chunks and
+// the number of files read
+func LoadDir(dir string, chunkSize int) ([]Chunk, int, error) {
+	var chunks []Chunk
+	files := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Hidden directories such as .git are not documents
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !documentExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read document %s: %w", path, err)
+		}
+		source, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, Split(filepath.ToSlash(source), string(content), chunkSize)...)
+		files++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load documents from %s: %w", dir, err)
+	}
+	return chunks, files, nil
+}
+
+// Split cuts a document into chunks at Markdown headings and paragraphs. A
+// chunk holds whole paragraphs of one section up to chunkSize characters;
+// longer paragraphs are cut between words
+func Split(source, content string, chunkSize int) []Chunk {
+	var chunks []Chunk
+	heading := ""
+	var current []string
+	size := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, Chunk{Source: source, Heading: heading, Text: strings.Join(current, "\n\n")})
+		}
+		current, size = nil, 0
+	}
+	add := func(paragraph string) {
+		for _, part := range splitWords(paragraph, chunkSize) {
+			n := utf8.RuneCountInString(part)
+			if size > 0 && size+2+n > chunkSize {
+				flush()
+			}
+			current = append(current, part)
+			size += n + 2
+		}
+	}
+
+	for _, paragraph := range paragraphs(content) {
+		if title, ok := markdownHeading(paragraph); ok {
+			flush()
+			heading = title
+			continue
+		}
+		add(paragraph)
+	}
+	flush()
+	return chunks
+}
+
+// paragraphs returns the non-empty blocks of text separated by blank lines;
+// headings are blocks of their own
+func paragraphs(content string) []string {
+	var blocks []string
+	var block []string
+	end := func() {
+		if text := strings.TrimSpace(strings.Join(block, "\n")); text != "" {
+			blocks = append(blocks, text)
+		}
+		block = nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			end()
+		case isHeadingLine(line):
+			end()
+			blocks = append(blocks, strings.TrimSpace(line))
+		default:
+			block = append(block, line)
+		}
+	}
+	end()
+	return blocks
+}
+
+// isHeadingLine reports whether line is an ATX Markdown heading, e.g. "## Refunds"
+func isHeadingLine(line string) bool {
+	trimmed := strings.TrimLeft(line, "#")
+	level := len(line) - len(trimmed)
+	return level >= 1 && level <= 6 && (trimmed == "" || trimmed[0] == ' ')
+}
+
+// markdownHeading returns the title of a heading block
+func markdownHeading(block string) (string, bool) {
+	if strings.Contains(block, "\n") || !isHeadingLine(block) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.Trim(block, "#")), true
+}
+
+// splitWords cuts text into pieces of at most limit characters between words;
+// a single word longer than limit is cut as it is
+func splitWords(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	var pieces []string
+	var piece strings.Builder
+	size := 0
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > limit {
+			runes := []rune(word)
+			if size > 0 {
+				pieces = append(pieces, piece.String())
+				piece.Reset()
+				size = 0
+			}
+			pieces = append(pieces, string(runes[:limit]))
+			word = string(runes[limit:])
+		}
+		n := utf8.RuneCountInString(word)
+		if size > 0 && size+1+n > limit {
+			pieces = append(pieces, piece.String())
+			piece.Reset()
+			size = 0
+		}
+		if size > 0 {
+			piece.WriteByte(' ')
+			size++
+		}
+		piece.WriteString(word)
+		size += n
+	}
+	if size > 0 {
+		pieces = append(pieces, piece.String())
+	}
+	return pieces
+}
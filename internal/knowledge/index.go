@@ -0,0 +1,161 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// embedBatchSize is the number of chunks embedded per request
+const embedBatchSize = 64
+
+// rrfK dampens the weight of top ranks when keyword and embedding rankings
+// are merged with reciprocal rank fusion
+const rrfK = 60
+
+// candidateFactor is how many candidates per requested result each ranking
+// contributes to the merge
+const candidateFactor = 4
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Index searches chunks by keywords with BM25 and, once embedded, by meaning
+type Index struct {
+	chunks []Chunk
+	files  int
+	bm25   *bm25Index
+
+	embedder Embedder
+	vectors  [][]float32
+}
+
+// NewIndex builds the keyword index of chunks read from files documents
+func NewIndex(chunks []Chunk, files int) *Index {
+	return &Index{chunks: chunks, files: files, bm25: newBM25Index(chunks)}
+}
+
+// Embed computes the embeddings of all chunks and returns a copy of the index
+// that also ranks chunks by meaning; idx itself is left unchanged
+func (idx *Index) Embed(ctx context.Context, embedder Embedder) (*Index, error) {
+	vectors := make([][]float32, 0, len(idx.chunks))
+	for start := 0; start < len(idx.chunks); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(idx.chunks))
+		texts := make([]string, 0, end-start)
+		for _, chunk := range idx.chunks[start:end] {
+			texts = append(texts, chunk.Label()+"\n"+chunk.Text)
+		}
+		batch, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed chunks: %w", err)
+		}
+		if len(batch) != len(texts) {
+			return nil, fmt.Errorf("failed to embed chunks: got %d embeddings for %d chunks", len(batch), len(texts))
+		}
+		vectors = append(vectors, batch...)
+	}
+	embedded := *idx
+	embedded.embedder, embedded.vectors = embedder, vectors
+	return &embedded, nil
+}
+
+// Chunks returns the number of indexed chunks
+func (idx *Index) Chunks() int {
+	return len(idx.chunks)
+}
+
+// Files returns the number of documents the chunks were read from
+func (idx *Index) Files() int {
+	return idx.files
+}
+
+// Embedded reports whether searches use embeddings
+func (idx *Index) Embedded() bool {
+	return idx.embedder != nil
+}
+
+// Search returns up to limit chunks relevant to query, best first. With
+// embeddings, the keyword and embedding rankings are merged; if the query
+// cannot be embedded, the keyword results are returned along with the error
+func (idx *Index) Search(ctx context.Context, query string, limit int) ([]Chunk, error) {
+	if limit <= 0 || len(idx.chunks) == 0 {
+		return nil, nil
+	}
+	candidates := limit * candidateFactor
+	keyword := idx.bm25.search(query, candidates)
+	if idx.embedder == nil {
+		return idx.pick(keyword, limit), nil
+	}
+
+	vectors, err := idx.embedder.Embed(ctx, []string{query})
+	if err != nil || len(vectors) != 1 {
+		if err == nil {
+			err = fmt.Errorf("got %d embeddings for 1 query", len(vectors))
+		}
+		return idx.pick(keyword, limit), fmt.Errorf("failed to embed query: %w", err)
+	}
+	similarities := make(map[int]float64, len(idx.vectors))
+	for i, vector := range idx.vectors {
+		similarities[i] = cosine(vectors[0], vector)
+	}
+	semantic := topScores(similarities, candidates)
+
+	fused := make(map[int]float64)
+	for _, ranking := range [][]int{keyword, semantic} {
+		for rank, i := range ranking {
+			fused[i] += 1 / float64(rrfK+rank+1)
+		}
+	}
+	return idx.pick(topScores(fused, limit), limit), nil
+}
+
+// pick returns the chunks at the first limit indexes
+func (idx *Index) pick(indexes []int, limit int) []Chunk {
+	if len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+	chunks := make([]Chunk, len(indexes))
+	for i, index := range indexes {
+		chunks[i] = idx.chunks[index]
+	}
+	return chunks
+}
+
+// cosine returns the cosine similarity of two vectors, 0 when either is empty
+// or their lengths differ
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Instructions presents chunks to the model as reference material it should
+// answer from and cite
+func Instructions(chunks []Chunk) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Excerpts from the knowledge base that may be relevant to the user's message are below. ")
+	b.WriteString("Base facts about the product on them rather than on assumptions, ignore excerpts that are not relevant, ")
+	b.WriteString("and say so when they do not answer the question. ")
+	b.WriteString("When you use an excerpt, cite its source in square brackets, e.g. [faq.md › Refunds].")
+	for _, chunk := range chunks {
+		fmt.Fprintf(&b, "\n\n[%s]\n%s", chunk.Label(), chunk.Text)
+	}
+	return b.String()
+}
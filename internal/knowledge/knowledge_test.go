@@ -0,0 +1,257 @@
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		chunkSize int
+		expected  []Chunk
+	}{
+		{
+			name:      "Plain text",
+			content:   "First paragraph.\n\nSecond paragraph.",
+			chunkSize: 100,
+			expected:  []Chunk{{Source: "a.txt", Text: "First paragraph.\n\nSecond paragraph."}},
+		},
+		{
+			name:      "Sections",
+			content:   "# Shop\nIntro.\n\n## Refunds\nWithin 30 days.\n\n## Shipping\nTwo days.",
+			chunkSize: 100,
+			expected: []Chunk{
+				{Source: "a.txt", Heading: "Shop", Text: "Intro."},
+				{Source: "a.txt", Heading: "Refunds", Text: "Within 30 days."},
+				{Source: "a.txt", Heading: "Shipping", Text: "Two days."},
+			},
+		},
+		{
+			name:      "Paragraphs over the chunk size",
+			content:   "aaaa bbbb\n\ncccc dddd\n\neeee",
+			chunkSize: 12,
+			expected: []Chunk{
+				{Source: "a.txt", Text: "aaaa bbbb"},
+				{Source: "a.txt", Text: "cccc dddd"},
+				{Source: "a.txt", Text: "eeee"},
+			},
+		},
+		{
+			name:      "Long paragraph",
+			content:   "one two three four five",
+			chunkSize: 9,
+			expected: []Chunk{
+				{Source: "a.txt", Text: "one two"},
+				{Source: "a.txt", Text: "three"},
+				{Source: "a.txt", Text: "four five"},
+			},
+		},
+		{
+			name:      "Hash without space is not a heading",
+			content:   "#hashtag text",
+			chunkSize: 100,
+			expected:  []Chunk{{Source: "a.txt", Text: "#hashtag text"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split("a.txt", tt.content, tt.chunkSize)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Split() = %+v, want %+v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Split()[%d] = %+v, want %+v", i, got[i], tt.expected[i])
+				}
+				if n := utf8.RuneCountInString(got[i].Text); n > tt.chunkSize {
+					t.Errorf("Split()[%d] has %d characters, want at most %d", i, n, tt.chunkSize)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"faq.md":             "# Refunds\nMoney back within 30 days.",
+		"guides/setup.txt":   "Plug the device in.",
+		"logo.png":           "not a document",
+		".git/description":   "not a document",
+		".drafts/secret.md":  "not published",
+		"guides/README.MD":   "Guides overview.",
+		"guides/empty.txt":   "",
+		"guides/notes.jsonl": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	chunks, count, err := LoadDir(dir, 800)
+	if err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+	if count != 4 {
+		t.Errorf("LoadDir() read %d files, want 4", count)
+	}
+	var labels []string
+	for _, chunk := range chunks {
+		labels = append(labels, chunk.Label())
+	}
+	if got, want := strings.Join(labels, ","), "faq.md › Refunds,guides/README.MD,guides/setup.txt"; got != want {
+		t.Errorf("LoadDir() chunks = %s, want %s", got, want)
+	}
+
+	if _, _, err := LoadDir(filepath.Join(dir, "missing"), 800); err == nil {
+		t.Error("LoadDir() of a missing directory succeeded")
+	}
+}
+
+// testChunks is a small knowledge base about a shop
+var testChunks = []Chunk{
+	{Source: "faq.md", Heading: "Refunds", Text: "Refunds are issued within 30 days of purchase. Send the receipt to support."},
+	{Source: "faq.md", Heading: "Shipping", Text: "We ship worldwide. Delivery takes two to five business days."},
+	{Source: "products.md", Heading: "Widget Pro", Text: "The Widget Pro has a 12 hour battery and costs 99 dollars."},
+	{Source: "company.txt", Text: "The shop was founded in 2015 and is based in Berlin."},
+}
+
+// topicEmbedder embeds texts as counts of topic words, so that synonyms of a
+// topic are close even when they share no keywords
+type topicEmbedder struct {
+	err error
+}
+
+// topics lists the words of each vector dimension
+var topics = [][]string{
+	{"refund", "refunds", "money", "reimburse"},
+	{"ship", "delivery", "parcel", "arrive"},
+	{"battery", "widget", "charge"},
+}
+
+func (e topicEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, len(topics))
+		for _, word := range tokenize(text) {
+			for dim, words := range topics {
+				for _, topicWord := range words {
+					if word == topicWord {
+						vector[dim]++
+					}
+				}
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func TestIndex_Search(t *testing.T) {
+	index := NewIndex(testChunks, 3)
+
+	tests := []struct {
+		name     string
+		query    string
+		limit    int
+		expected []string
+	}{
+		{name: "Single keyword", query: "How long does delivery take?", limit: 1, expected: []string{"faq.md › Shipping"}},
+		{name: "Keyword in heading", query: "widget pro price", limit: 1, expected: []string{"products.md › Widget Pro"}},
+		{name: "Case and punctuation", query: "REFUNDS!!", limit: 2, expected: []string{"faq.md › Refunds"}},
+		{name: "Numbers", query: "founded 2015", limit: 4, expected: []string{"company.txt"}},
+		{name: "No match", query: "quantum entanglement", limit: 4, expected: nil},
+		{name: "No results requested", query: "refunds", limit: 0, expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := index.Search(context.Background(), tt.query, tt.limit)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if got, want := labels(chunks), strings.Join(tt.expected, ","); got != want {
+				t.Errorf("Search() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestIndex_SearchEmbeddings(t *testing.T) {
+	keywordOnly := NewIndex(testChunks, 3)
+	index, err := keywordOnly.Embed(context.Background(), topicEmbedder{})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if !index.Embedded() || keywordOnly.Embedded() {
+		t.Fatal("Embed() must return an embedded copy and leave the index unchanged")
+	}
+
+	// "parcel" and "arrive" only match the shipping chunk by meaning
+	query := "when will my parcel arrive"
+	if chunks, _ := keywordOnly.Search(context.Background(), query, 1); len(chunks) != 0 {
+		t.Errorf("keyword Search() = %s, want no results", labels(chunks))
+	}
+	chunks, err := index.Search(context.Background(), query, 1)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if got := labels(chunks); got != "faq.md › Shipping" {
+		t.Errorf("Search() = %s, want faq.md › Shipping", got)
+	}
+
+	// Without the embeddings provider, searches fall back to keywords
+	offline, err := keywordOnly.Embed(context.Background(), topicEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline.embedder = topicEmbedder{err: errors.New("connection refused")}
+	chunks, err = offline.Search(context.Background(), "refunds", 1)
+	if err == nil {
+		t.Error("Search() error = nil, want the embedding error")
+	}
+	if got := labels(chunks); got != "faq.md › Refunds" {
+		t.Errorf("Search() = %s, want the keyword result faq.md › Refunds", got)
+	}
+
+	if _, err := keywordOnly.Embed(context.Background(), topicEmbedder{err: errors.New("unauthorized")}); err == nil {
+		t.Error("Embed() error = nil, want the embedding error")
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	if got := Instructions(nil); got != "" {
+		t.Errorf("Instructions(nil) = %q, want empty", got)
+	}
+	got := Instructions(testChunks[:2])
+	for _, want := range []string{"cite its source", "[faq.md › Refunds]\nRefunds are issued", "[faq.md › Shipping]\nWe ship"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Instructions() = %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+// labels joins the labels of chunks with commas
+func labels(chunks []Chunk) string {
+	names := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		names[i] = chunk.Label()
+	}
+	return strings.Join(names, ",")
+}
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -31,3 +33,19 @@ func New(level string) (*zap.Logger, error) {
 
 	return config.Build()
 }
+
+// contextKey is the context key of a request-scoped logger
+type contextKey struct{}
+
+// WithContext stores a logger, e.g. one with a correlation ID, in ctx
+func WithContext(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// FromContext returns the logger stored by WithContext, or fallback
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if log, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return log
+	}
+	return fallback
+}
@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tgbot"
+
+// Registry holds all bot metrics along with Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// UpdatesReceived counts incoming Telegram updates by type
+	UpdatesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_received_total",
+		Help:      "Telegram updates received, by update type.",
+	}, []string{"type"})
+
+	// UpdateDuration observes how long updates take to process
+	UpdateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "update_duration_seconds",
+		Help:      "Time spent processing Telegram updates, by update type.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"type"})
+
+	// UpdatesDropped counts updates that were not processed
+	UpdatesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_dropped_total",
+		Help:      "Telegram updates dropped before processing, by reason (duplicate, access_denied, rate_limited).",
+	}, []string{"reason"})
+
+	// UpdatePanics counts panics recovered while processing updates
+	UpdatePanics = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "update_panics_total",
+		Help:      "Panics recovered while processing Telegram updates.",
+	})
+
+	// CommandsHandled counts handled bot commands
+	CommandsHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_handled_total",
+		Help:      "Bot commands handled, by command.",
+	}, []string{"command"})
+
+	// InlineQueries counts inline queries by outcome
+	InlineQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inline_queries_total",
+		Help:      "Inline queries, by outcome (answered, cached, debounced, rate_limited, quota_exceeded, error).",
+	}, []string{"outcome"})
+
+	// AIRequestDuration observes AI provider request latency
+	AIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ai_request_duration_seconds",
+		Help:      "AI provider request latency, by model and status.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"model", "status"})
+
+	// AITokens counts prompt ("in") and completion ("out") tokens
+	AITokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_tokens_total",
+		Help:      "Tokens reported by the AI provider, by model and direction (in, out).",
+	}, []string{"model", "direction"})
+
+	// AICost counts the cost of AI requests according to the configured price table
+	AICost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_cost_total",
+		Help:      "Cost of AI requests in the configured currency, by model.",
+	}, []string{"model"})
+
+	// AIToolCalls counts tools called by the model
+	AIToolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_tool_calls_total",
+		Help:      "Tools called by the AI model, by tool and result (success, error).",
+	}, []string{"tool", "result"})
+
+	// Reminders counts reminders handled by the scheduler
+	Reminders = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminders_total",
+		Help:      "Reminders handled by the scheduler, by result (delivered, blocked, failed).",
+	}, []string{"result"})
+
+	// ScheduledPosts counts runs of scheduled jobs
+	ScheduledPosts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduled_posts_total",
+		Help:      "Runs of scheduled jobs, by result (sent, blocked, failed).",
+	}, []string{"result"})
+
+	// TelegramSendErrors counts failed Telegram API calls
+	TelegramSendErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_send_errors_total",
+		Help:      "Failed Telegram API calls, by method.",
+	}, []string{"method"})
+
+	// MarkdownFallbacks counts messages resent as plain text after Telegram rejected their Markdown
+	MarkdownFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "markdown_fallbacks_total",
+		Help:      "Messages resent as plain text because Telegram could not parse their Markdown.",
+	})
+
+	// ConfigReloads counts reloads of prompts and bot messages
+	ConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Reloads of prompts and bot messages, by result (success, error).",
+	}, []string{"result"})
+
+	// ActiveWorkers tracks updates currently being processed
+	ActiveWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_workers",
+		Help:      "Updates currently being processed.",
+	})
+
+	// QueueDepth tracks updates waiting in the long polling queue
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Updates waiting to be processed in the long polling queue.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		UpdatesReceived,
+		UpdateDuration,
+		UpdatesDropped,
+		UpdatePanics,
+		CommandsHandled,
+		InlineQueries,
+		AIRequestDuration,
+		AITokens,
+		AICost,
+		AIToolCalls,
+		Reminders,
+		ScheduledPosts,
+		TelegramSendErrors,
+		MarkdownFallbacks,
+		ConfigReloads,
+		ActiveWorkers,
+		QueueDepth,
+	)
+}
+
+// Handler returns an HTTP handler serving metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
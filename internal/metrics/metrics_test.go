@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	UpdatesReceived.WithLabelValues("message").Inc()
+	AIRequestDuration.WithLabelValues("test-model", "200").Observe(0.3)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		`tgbot_updates_received_total{type="message"} 1`,
+		`tgbot_ai_request_duration_seconds_count{model="test-model",status="200"} 1`,
+		"tgbot_active_workers 0",
+		"tgbot_queue_depth 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
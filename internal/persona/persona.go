@@ -0,0 +1,133 @@
+package persona
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tgbot-skeleton/internal/prompt"
+)
+
+// promptExt is the extension of prompt files in the prompts directory
+const promptExt = ".txt"
+
+// maxNameLength keeps persona names short enough for inline button callback data
+const maxNameLength = 48
+
+// welcomeSuffix marks a file holding the welcome text of the persona with the same name
+const welcomeSuffix = ".welcome" + promptExt
+
+// Persona is a named system prompt with the settings from its front-matter
+type Persona struct {
+	// Name is derived from the file name, e.g. "customer-support"
+	Name   string
+	Prompt *prompt.Template
+	prompt.Meta
+}
+
+// Label returns the display name of the persona
+func (p *Persona) Label() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return p.Name
+}
+
+// Library holds the personas loaded from a prompts directory
+type Library struct {
+	personas map[string]*Persona
+	names    []string
+}
+
+// Load reads every "<name>.txt" file in dir as a persona; "<name>.welcome.txt"
+// may hold the welcome text instead of the front-matter. Prompts are compiled
+// as templates with the custom variables vars
+func Load(dir string, vars map[string]string) (*Library, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompts directory %s: %w", dir, err)
+	}
+
+	lib := &Library{personas: make(map[string]*Persona)}
+	welcomes := make(map[string]string)
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, promptExt) {
+			continue
+		}
+
+		path := filepath.Join(dir, fileName)
+		if name, ok := strings.CutSuffix(fileName, welcomeSuffix); ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read welcome file %s: %w", path, err)
+			}
+			welcomes[name] = strings.TrimSpace(string(content))
+			continue
+		}
+
+		name := strings.TrimSuffix(fileName, promptExt)
+		if len(name) > maxNameLength {
+			return nil, fmt.Errorf("persona name %q is longer than %d bytes", name, maxNameLength)
+		}
+		file, err := prompt.ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if file.Body == "" {
+			return nil, fmt.Errorf("prompt file %s is empty", fileName)
+		}
+		tmpl, err := prompt.Compile(name, file.Body, vars)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt file %s: %w", fileName, err)
+		}
+		lib.personas[name] = &Persona{Name: name, Prompt: tmpl, Meta: file.Meta}
+		lib.names = append(lib.names, name)
+	}
+
+	for name, welcome := range welcomes {
+		p, ok := lib.personas[name]
+		if !ok {
+			return nil, fmt.Errorf("welcome file for unknown persona %q", name)
+		}
+		if p.Welcome != "" {
+			return nil, fmt.Errorf("persona %q has a welcome text in both its front-matter and %s%s", name, name, welcomeSuffix)
+		}
+		p.Welcome = welcome
+	}
+
+	sort.Strings(lib.names)
+	return lib, nil
+}
+
+// Get returns the persona with the given name
+func (l *Library) Get(name string) (*Persona, bool) {
+	if l == nil {
+		return nil, false
+	}
+	p, ok := l.personas[name]
+	return p, ok
+}
+
+// List returns all personas ordered by name
+func (l *Library) List() []*Persona {
+	if l == nil {
+		return nil
+	}
+	list := make([]*Persona, 0, len(l.names))
+	for _, name := range l.names {
+		list = append(list, l.personas[name])
+	}
+	return list
+}
+
+// Len returns the number of personas
+func (l *Library) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.names)
+}
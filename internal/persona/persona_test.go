@@ -0,0 +1,94 @@
+package persona
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles creates files with the given contents in a temporary directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"teacher.txt":         "You are a teacher.\n",
+		"teacher.welcome.txt": "  Hello, student!  ",
+		"support.txt":         "---\nname: Support\nmodel: gpt-4o-mini\nwelcome: How can I help?\n---\nYou are support.",
+		"notes.md":            "ignored",
+	})
+
+	lib, err := Load(dir, nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if lib.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", lib.Len())
+	}
+
+	list := lib.List()
+	if list[0].Name != "support" || list[1].Name != "teacher" {
+		t.Errorf("List() = %s, %s, want sorted by name", list[0].Name, list[1].Name)
+	}
+
+	teacher, ok := lib.Get("teacher")
+	if !ok {
+		t.Fatalf("Get(teacher) not found")
+	}
+	if teacher.Prompt.Text() != "You are a teacher." || teacher.Welcome != "Hello, student!" {
+		t.Errorf("teacher = %+v, want trimmed prompt and welcome", teacher)
+	}
+	support, _ := lib.Get("support")
+	if support.Prompt.Text() != "You are support." || support.Label() != "Support" || support.Model != "gpt-4o-mini" || support.Welcome != "How can I help?" {
+		t.Errorf("support = %+v, want front-matter applied", support)
+	}
+	if teacher.Label() != "teacher" {
+		t.Errorf("Label() = %q, want file name without title", teacher.Label())
+	}
+	if _, ok := lib.Get("notes"); ok {
+		t.Errorf("Get(notes) found a non-prompt file")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{name: "Empty prompt", files: map[string]string{"empty.txt": "  \n"}},
+		{name: "Orphan welcome", files: map[string]string{"ghost.welcome.txt": "Boo"}},
+		{name: "Duplicate welcome", files: map[string]string{"a.txt": "---\nwelcome: Hi\n---\nPrompt", "a.welcome.txt": "Hello"}},
+		{name: "Invalid front-matter", files: map[string]string{"a.txt": "---\nmodle: x\n---\nPrompt"}},
+		{name: "Invalid template", files: map[string]string{"a.txt": "Hello {{.Vars.company}}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeFiles(t, tt.files), nil); err == nil {
+				t.Errorf("Load() error = nil, want error")
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Errorf("Load() of missing directory error = nil, want error")
+	}
+}
+
+func TestNilLibrary(t *testing.T) {
+	var lib *Library
+	if lib.Len() != 0 || len(lib.List()) != 0 {
+		t.Errorf("nil library is not empty")
+	}
+	if _, ok := lib.Get("any"); ok {
+		t.Errorf("nil library Get() found a persona")
+	}
+}
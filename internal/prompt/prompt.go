@@ -0,0 +1,137 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter opens and closes the YAML front-matter block
+const frontMatterDelimiter = "---"
+
+// Meta holds the optional front-matter of a prompt file
+type Meta struct {
+	// Title is the display name of the persona
+	Title       string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Model       string   `yaml:"model"`
+	Temperature *float64 `yaml:"temperature"`
+	MaxTokens   int      `yaml:"max_tokens"`
+	Welcome     string   `yaml:"welcome"`
+	// Commands lists the bot commands available with this prompt; empty allows all
+	Commands []string  `yaml:"commands"`
+	Examples []Example `yaml:"examples"`
+}
+
+// Example is a few-shot conversation turn sent before the real conversation
+type Example struct {
+	User      string `yaml:"user"`
+	Assistant string `yaml:"assistant"`
+}
+
+// File is a parsed prompt file: metadata and the system prompt body
+type File struct {
+	Meta
+	Body string
+}
+
+// Parse splits an optional YAML front-matter block from the prompt body:
+//
+//	---
+//	name: Support
+//	temperature: 0.3
+//	---
+//	You are a support assistant.
+func Parse(data []byte) (*File, error) {
+	text := strings.TrimLeft(string(bytes.TrimPrefix(data, []byte("\ufeff"))), "\r\n")
+
+	file := &File{}
+	if rest, ok := cutDelimiterLine(text); ok {
+		header, body, found := cutFrontMatter(rest)
+		if !found {
+			return nil, fmt.Errorf("front-matter is not closed with %q", frontMatterDelimiter)
+		}
+		if err := decodeMeta(header, &file.Meta); err != nil {
+			return nil, err
+		}
+		text = body
+	}
+
+	file.Body = strings.TrimSpace(text)
+	if err := file.validate(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// ParseFile reads and parses a prompt file
+func ParseFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt file %s: %w", path, err)
+	}
+	file, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// validate checks the metadata for values the AI provider would reject
+func (f *File) validate() error {
+	if f.Temperature != nil && (*f.Temperature < 0 || *f.Temperature > 2) {
+		return fmt.Errorf("temperature %v is outside 0..2", *f.Temperature)
+	}
+	if f.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	for i, example := range f.Examples {
+		if strings.TrimSpace(example.User) == "" || strings.TrimSpace(example.Assistant) == "" {
+			return fmt.Errorf("example %d needs both user and assistant text", i+1)
+		}
+	}
+	for i, command := range f.Commands {
+		f.Commands[i] = strings.TrimPrefix(strings.TrimSpace(command), "/")
+	}
+	return nil
+}
+
+// cutDelimiterLine removes a leading "---" line
+func cutDelimiterLine(text string) (string, bool) {
+	line, rest, _ := strings.Cut(text, "\n")
+	if !isDelimiter(line) {
+		return text, false
+	}
+	return rest, true
+}
+
+// cutFrontMatter splits text at the closing "---" line
+func cutFrontMatter(text string) (header, body string, found bool) {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if isDelimiter(line) {
+			return strings.Join(lines[:i], ""), strings.Join(lines[i+1:], ""), true
+		}
+	}
+	return "", "", false
+}
+
+// isDelimiter reports whether a line is a front-matter delimiter
+func isDelimiter(line string) bool {
+	return strings.TrimRight(line, " \t\r\n") == frontMatterDelimiter
+}
+
+// decodeMeta decodes the front-matter, rejecting unknown keys to catch typos
+func decodeMeta(header string, meta *Meta) error {
+	decoder := yaml.NewDecoder(strings.NewReader(header))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(meta); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid front-matter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package prompt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	input := `---
+name: Support
+description: Friendly customer support
+model: gpt-4o-mini
+temperature: 0.3
+max_tokens: 500
+welcome: |
+  Hi! How can I help?
+commands: [start, /help, export]
+examples:
+  - user: My order is late
+    assistant: Sorry to hear that! What is your order number?
+---
+You are a support assistant.
+`
+
+	file, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if file.Body != "You are a support assistant." {
+		t.Errorf("Body = %q", file.Body)
+	}
+	if file.Title != "Support" || file.Description != "Friendly customer support" || file.Model != "gpt-4o-mini" {
+		t.Errorf("Meta = %+v", file.Meta)
+	}
+	if file.Temperature == nil || *file.Temperature != 0.3 || file.MaxTokens != 500 {
+		t.Errorf("sampling = %v/%d, want 0.3/500", file.Temperature, file.MaxTokens)
+	}
+	if file.Welcome != "Hi! How can I help?\n" {
+		t.Errorf("Welcome = %q", file.Welcome)
+	}
+	if len(file.Commands) != 3 || file.Commands[1] != "help" {
+		t.Errorf("Commands = %v, want slashes stripped", file.Commands)
+	}
+	if len(file.Examples) != 1 || file.Examples[0].User != "My order is late" {
+		t.Errorf("Examples = %+v", file.Examples)
+	}
+}
+
+func TestParse_WithoutFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Plain prompt", input: "\nYou are helpful.\n", expected: "You are helpful."},
+		{name: "Separator inside body", input: "Intro\n---\nMore", expected: "Intro\n---\nMore"},
+		{name: "Empty front-matter", input: "---\n---\nBody", expected: "Body"},
+		{name: "Windows line endings", input: "---\r\nname: X\r\n---\r\nBody\r\n", expected: "Body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if file.Body != tt.expected {
+				t.Errorf("Body = %q, want %q", file.Body, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Unclosed", input: "---\nname: X\nBody"},
+		{name: "Unknown key", input: "---\ntemprature: 0.5\n---\nBody"},
+		{name: "Temperature out of range", input: "---\ntemperature: 3\n---\nBody"},
+		{name: "Negative max tokens", input: "---\nmax_tokens: -1\n---\nBody"},
+		{name: "Incomplete example", input: "---\nexamples:\n  - user: hi\n---\nBody"},
+		{name: "Invalid YAML", input: "---\nname: [\n---\nBody"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.input)); err == nil {
+				t.Errorf("Parse() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl, err := Compile("test", "Hi {{.UserFirstName}} ({{.LanguageCode}}), today is {{.Date}}. Contact {{.Vars.email}}.",
+		map[string]string{"email": "help@example.com"})
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	data := Data{UserFirstName: "Jane", LanguageCode: "de", Vars: map[string]string{"email": "help@example.com"}}
+	data.SetTime(time.Date(2025, 3, 14, 9, 30, 0, 0, time.UTC))
+	got, err := tmpl.Render(data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "Hi Jane (de), today is 2025-03-14. Contact help@example.com."; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		vars    map[string]string
+		wantErr bool
+	}{
+		{name: "Plain text", text: "You are helpful. {not a template}"},
+		{name: "Built-in variables", text: "{{.BotUsername}} {{.ChatTitle}} {{.Weekday}} {{.Time}}"},
+		{name: "Custom variable", text: "{{.Vars.company}}", vars: map[string]string{"company": "Acme"}},
+		{name: "Conditional", text: "{{if .UserFirstName}}Hi {{.UserFirstName}}{{end}}"},
+		{name: "Syntax error", text: "{{.UserFirstName", wantErr: true},
+		{name: "Unknown field", text: "{{.FirstName}}", wantErr: true},
+		{name: "Missing custom variable", text: "{{.Vars.company}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile("test", tt.text, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := ParseVars(" company = Acme , email=help@acme.com,,")
+	if err != nil {
+		t.Fatalf("ParseVars() error = %v", err)
+	}
+	if len(vars) != 2 || vars["company"] != "Acme" || vars["email"] != "help@acme.com" {
+		t.Errorf("ParseVars() = %v", vars)
+	}
+
+	if _, err := ParseVars("company"); err == nil {
+		t.Errorf("ParseVars() error = nil for entry without value")
+	}
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DateFormat is the layout of the Date template variable
+const DateFormat = "2006-01-02"
+
+// timeFormat is the layout of the Time template variable
+const timeFormat = "15:04"
+
+// Data holds the values available to prompt templates, e.g. {{.UserFirstName}}
+// or {{.Vars.company}} for custom values from the configuration
+type Data struct {
+	UserFirstName string
+	UserLastName  string
+	Username      string
+	LanguageCode  string
+	ChatTitle     string
+	ChatType      string
+	BotUsername   string
+	Date          string
+	Weekday       string
+	Time          string
+	Vars          map[string]string
+}
+
+// SetTime fills the date and time variables
+func (d *Data) SetTime(now time.Time) {
+	d.Date = now.Format(DateFormat)
+	d.Weekday = now.Weekday().String()
+	d.Time = now.Format(timeFormat)
+}
+
+// Template is a system prompt that may contain text/template placeholders
+type Template struct {
+	text string
+	// tmpl is nil for prompts without placeholders, which are used as is
+	tmpl *template.Template
+}
+
+// Compile parses a prompt template and renders it once with placeholder values,
+// so unknown fields and custom variables missing from vars are reported at startup
+func Compile(name, text string, vars map[string]string) (*Template, error) {
+	t := &Template{text: text}
+	if !strings.Contains(text, "{{") {
+		return t, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	t.tmpl = tmpl
+
+	if _, err := t.Render(sampleData(vars)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Text returns the unrendered prompt
+func (t *Template) Text() string {
+	return t.text
+}
+
+// Render executes the template with per-request data
+func (t *Template) Render(data Data) (string, error) {
+	if t.tmpl == nil {
+		return t.text, nil
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// ParseVars parses custom template variables such as "company=Acme,support_email=help@acme.com"
+func ParseVars(spec string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q: expected key=value", entry)
+		}
+		vars[key] = strings.TrimSpace(value)
+	}
+	return vars, nil
+}
+
+// sampleData returns values for every variable, used to validate templates
+func sampleData(vars map[string]string) Data {
+	data := Data{
+		UserFirstName: "Jane",
+		UserLastName:  "Doe",
+		Username:      "jane",
+		LanguageCode:  "en",
+		ChatTitle:     "Chat",
+		ChatType:      "private",
+		BotUsername:   "bot",
+		Vars:          vars,
+	}
+	data.SetTime(time.Now())
+	return data
+}
@@ -0,0 +1,224 @@
+package redact
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Kind identifies a category of personal or secret data
+type Kind string
+
+// Supported kinds of sensitive data
+const (
+	KindEmail  Kind = "EMAIL"
+	KindPhone  Kind = "PHONE"
+	KindCard   Kind = "CARD"
+	KindIBAN   Kind = "IBAN"
+	KindSecret Kind = "SECRET"
+)
+
+// detector finds one kind of sensitive data
+type detector struct {
+	kind    Kind
+	pattern *regexp.Regexp
+	// valid optionally filters regexp matches, e.g. with a checksum
+	valid func(match string) bool
+}
+
+// detectors run in order; more specific patterns come before generic ones so
+// that, for example, card numbers are not reported as phone numbers
+var detectors = []detector{
+	{
+		kind:    KindEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		kind: KindSecret,
+		pattern: regexp.MustCompile(`\b(?:` +
+			`(?:sk|pk|rk)-[A-Za-z0-9_\-]{16,}` + // OpenAI-style keys
+			`|gh[pousr]_[A-Za-z0-9]{20,}` + // GitHub tokens
+			`|AKIA[0-9A-Z]{16}` + // AWS access key IDs
+			`|xox[abprs]-[A-Za-z0-9\-]{10,}` + // Slack tokens
+			`|AIza[0-9A-Za-z_\-]{35}` + // Google API keys
+			`|\d{8,10}:[A-Za-z0-9_\-]{35}` + // Telegram bot tokens
+			`)`),
+	},
+	{
+		kind:    KindIBAN,
+		pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+		valid:   validIBAN,
+	},
+	{
+		kind:    KindCard,
+		pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		valid:   validLuhn,
+	},
+	{
+		kind:    KindPhone,
+		pattern: regexp.MustCompile(`(?:\+|\b)\d[\d \-().]{7,}\d\b`),
+		valid:   validPhone,
+	},
+	{
+		kind:    KindSecret,
+		pattern: regexp.MustCompile(`\b[A-Za-z0-9_\-]{32,}\b`),
+		valid:   looksLikeKey,
+	},
+}
+
+// Redact replaces all sensitive data in text with [KIND] markers. It is meant
+// for log output, where the original values must never be recoverable.
+func Redact(text string) string {
+	return replace(text, func(kind Kind, _ string) string {
+		return "[" + string(kind) + "]"
+	})
+}
+
+// Masker replaces sensitive data with numbered placeholders like [EMAIL_1] and
+// can restore the original values later, e.g. in an AI response
+type Masker struct {
+	mu       sync.Mutex
+	values   map[string]string
+	byValue  map[string]string
+	counters map[Kind]int
+}
+
+// NewMasker creates an empty masker
+func NewMasker() *Masker {
+	return &Masker{
+		values:   make(map[string]string),
+		byValue:  make(map[string]string),
+		counters: make(map[Kind]int),
+	}
+}
+
+// Mask replaces sensitive data with placeholders; the same value always maps to
+// the same placeholder within one masker
+func (m *Masker) Mask(text string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return replace(text, func(kind Kind, match string) string {
+		if placeholder, ok := m.byValue[match]; ok {
+			return placeholder
+		}
+		m.counters[kind]++
+		placeholder := fmt.Sprintf("[%s_%d]", kind, m.counters[kind])
+		m.values[placeholder] = match
+		m.byValue[match] = placeholder
+		return placeholder
+	})
+}
+
+// Restore puts the original values back in place of placeholders
+func (m *Masker) Restore(text string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.values) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(m.values)*2)
+	for placeholder, value := range m.values {
+		pairs = append(pairs, placeholder, value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// replace runs every detector over text and substitutes matches using fn
+func replace(text string, fn func(kind Kind, match string) string) string {
+	for _, d := range detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			return fn(d.kind, match)
+		})
+	}
+	return text
+}
+
+// digitsOnly returns the digits of s
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validLuhn reports whether the digits of s form a 13-19 digit number passing the Luhn checksum
+func validLuhn(s string) bool {
+	digits := digitsOnly(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether s passes the ISO 13616 mod-97 check
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// Move the country code and check digits to the end and convert letters to numbers
+	rearranged := iban[4:] + iban[:4]
+	var numeric strings.Builder
+	for _, r := range rearranged {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&numeric, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validPhone reports whether s holds a plausible phone number of 10-15 digits
+func validPhone(s string) bool {
+	n := len(digitsOnly(s))
+	return n >= 10 && n <= 15
+}
+
+// looksLikeKey reports whether a long token mixes letters and digits like an API key
+func looksLikeKey(s string) bool {
+	var letters, digits bool
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits = true
+		case unicode.IsLetter(r):
+			letters = true
+		}
+	}
+	return letters && digits
+}
@@ -0,0 +1,775 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// Bucket names
+var (
+	bucketMeta          = []byte("meta")
+	bucketConversations = []byte("conversations")
+	bucketUsers         = []byte("users")
+	bucketQuotas        = []byte("quotas")
+	bucketCounters      = []byte("counters")
+	bucketUsage         = []byte("usage")
+	bucketChats         = []byte("chats")
+	bucketTickets       = []byte("tickets")
+	bucketOpenTickets   = []byte("open_tickets")
+	bucketReminders     = []byte("reminders")
+	bucketSchedules     = []byte("schedules")
+)
+
+// pingKey is the meta bucket key written by Ping
+var pingKey = []byte("ping")
+
+// BoltStore persists data in an embedded bbolt database file
+type BoltStore struct {
+	db          *bolt.DB
+	logger      *zap.Logger
+	maxMessages int
+}
+
+// NewBoltStore opens (or creates) the database at path and migrates it to the latest schema
+func NewBoltStore(path string, logger *zap.Logger) (*BoltStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database %s: %w", path, err)
+	}
+
+	store := &BoltStore{db: db, logger: logger}
+	if err := store.migrate(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to migrate bolt database: %w", err)
+	}
+
+	return store, nil
+}
+
+// SetMaxMessages limits how many messages a conversation keeps (0 = unlimited)
+func (s *BoltStore) SetMaxMessages(n int) {
+	s.maxMessages = n
+}
+
+// GetConversation returns the conversation for a chat
+func (s *BoltStore) GetConversation(_ context.Context, chatID int64) (*Conversation, error) {
+	conv := &Conversation{ChatID: chatID}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketConversations), int64Key(chatID), conv)
+	})
+	if err == ErrNotFound {
+		return conv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// AppendMessages appends messages to the conversation for a chat
+func (s *BoltStore) AppendMessages(_ context.Context, chatID int64, messages ...ConversationMessage) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketConversations)
+		conv := &Conversation{ChatID: chatID}
+		if err := getJSON(bucket, int64Key(chatID), conv); err != nil && err != ErrNotFound {
+			return err
+		}
+		conv.Messages = append(conv.Messages, messages...)
+		conv.trim(s.maxMessages)
+		conv.UpdatedAt = time.Now()
+		return putJSON(bucket, int64Key(chatID), conv)
+	})
+}
+
+// SaveConversation replaces the stored conversation
+func (s *BoltStore) SaveConversation(_ context.Context, conversation *Conversation) error {
+	conv := conversation.clone()
+	conv.trim(s.maxMessages)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketConversations), int64Key(conv.ChatID), conv)
+	})
+}
+
+// DeleteConversation removes the conversation for a chat
+func (s *BoltStore) DeleteConversation(_ context.Context, chatID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketConversations).Delete(int64Key(chatID))
+	})
+}
+
+// GetUserSettings returns the settings for a user
+func (s *BoltStore) GetUserSettings(_ context.Context, userID int64) (*UserSettings, error) {
+	var settings UserSettings
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketUsers), int64Key(userID), &settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveUserSettings stores the settings for a user
+func (s *BoltStore) SaveUserSettings(_ context.Context, settings *UserSettings) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketUsers), int64Key(settings.UserID), settings)
+	})
+}
+
+// GetQuota returns the usage of a user within a period
+func (s *BoltStore) GetQuota(_ context.Context, userID int64, period string) (*Quota, error) {
+	quota := &Quota{UserID: userID, Period: period}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketQuotas), []byte(quotaKey(userID, period)), quota)
+	})
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// IncrementQuota adds requests and tokens to a user's usage within a period
+func (s *BoltStore) IncrementQuota(_ context.Context, userID int64, period string, requests, tokens int) (*Quota, error) {
+	quota := &Quota{UserID: userID, Period: period}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketQuotas)
+		key := []byte(quotaKey(userID, period))
+		if err := getJSON(bucket, key, quota); err != nil && err != ErrNotFound {
+			return err
+		}
+		quota.Requests += requests
+		quota.Tokens += tokens
+		return putJSON(bucket, key, quota)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// GetCounter returns the value of a named counter
+func (s *BoltStore) GetCounter(_ context.Context, name string) (int64, error) {
+	var value int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		value, err = readCounter(tx.Bucket(bucketCounters), name)
+		return err
+	})
+	return value, err
+}
+
+// IncrementCounter adds delta to a named counter
+func (s *BoltStore) IncrementCounter(_ context.Context, name string, delta int64) (int64, error) {
+	var value int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketCounters)
+		current, err := readCounter(bucket, name)
+		if err != nil {
+			return err
+		}
+		value = current + delta
+		return bucket.Put([]byte(name), []byte(strconv.FormatInt(value, 10)))
+	})
+	return value, err
+}
+
+// TouchChat records a chat the bot has seen
+func (s *BoltStore) TouchChat(_ context.Context, chat ChatInfo) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketChats)
+		key := int64Key(chat.ChatID)
+
+		var existing ChatInfo
+		err := getJSON(bucket, key, &existing)
+		switch {
+		case err == ErrNotFound:
+			existing = chat
+		case err != nil:
+			return err
+		default:
+			existing.touch(chat)
+		}
+		return putJSON(bucket, key, existing)
+	})
+}
+
+// GetChat returns a known chat
+func (s *BoltStore) GetChat(_ context.Context, chatID int64) (*ChatInfo, error) {
+	var chat ChatInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketChats), int64Key(chatID), &chat)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// SetChatBlocked marks a chat as blocked or unblocked
+func (s *BoltStore) SetChatBlocked(_ context.Context, chatID int64, blocked bool) error {
+	err := s.updateChat(chatID, func(chat *ChatInfo) {
+		chat.Blocked = blocked
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// SetChatPersona selects the persona of a known chat
+func (s *BoltStore) SetChatPersona(_ context.Context, chatID int64, persona string) error {
+	return s.updateChat(chatID, func(chat *ChatInfo) {
+		chat.Persona = persona
+	})
+}
+
+// updateChat applies fn to a stored chat, returning ErrNotFound if it is unknown
+func (s *BoltStore) updateChat(chatID int64, fn func(chat *ChatInfo)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketChats)
+		key := int64Key(chatID)
+
+		var chat ChatInfo
+		if err := getJSON(bucket, key, &chat); err != nil {
+			return err
+		}
+		fn(&chat)
+		return putJSON(bucket, key, chat)
+	})
+}
+
+// ListChats returns every known chat ordered by ID
+func (s *BoltStore) ListChats(_ context.Context) ([]ChatInfo, error) {
+	var chats []ChatInfo
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChats).ForEach(func(key, value []byte) error {
+			var chat ChatInfo
+			if err := json.Unmarshal(value, &chat); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			chats = append(chats, chat)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys are decimal strings, so bucket order is not numeric order
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ChatID < chats[j].ChatID })
+	return chats, nil
+}
+
+// SaveTicket stores a ticket, assigning the next ID to a new one
+func (s *BoltStore) SaveTicket(_ context.Context, ticket *Ticket) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTickets)
+		if ticket.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			ticket.ID = int64(id)
+		}
+		if err := putJSON(bucket, int64Key(ticket.ID), ticket); err != nil {
+			return err
+		}
+		return indexOpenTicket(tx.Bucket(bucketOpenTickets), ticket)
+	})
+}
+
+// indexOpenTicket records an open ticket as its chat's open ticket, and
+// removes a closed one from the index
+func indexOpenTicket(index *bolt.Bucket, ticket *Ticket) error {
+	key, id := int64Key(ticket.ChatID), int64Key(ticket.ID)
+	if ticket.Status == TicketOpen {
+		return index.Put(key, id)
+	}
+	if bytes.Equal(index.Get(key), id) {
+		return index.Delete(key)
+	}
+	return nil
+}
+
+// OpenTicket returns the open ticket of a chat
+func (s *BoltStore) OpenTicket(_ context.Context, chatID int64) (*Ticket, error) {
+	var ticket Ticket
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(bucketOpenTickets).Get(int64Key(chatID))
+		if id == nil {
+			return ErrNotFound
+		}
+		return getJSON(tx.Bucket(bucketTickets), id, &ticket)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// GetTicket returns a ticket
+func (s *BoltStore) GetTicket(_ context.Context, id int64) (*Ticket, error) {
+	var ticket Ticket
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketTickets), int64Key(id), &ticket)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// ListTickets returns the tickets with the given status ordered by ID; open
+// tickets are read through the index, without scanning closed ones
+func (s *BoltStore) ListTickets(_ context.Context, status string) ([]Ticket, error) {
+	var tickets []Ticket
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if status == TicketOpen {
+			bucket := tx.Bucket(bucketTickets)
+			return tx.Bucket(bucketOpenTickets).ForEach(func(_, id []byte) error {
+				var ticket Ticket
+				if err := getJSON(bucket, id, &ticket); err != nil {
+					return fmt.Errorf("failed to read ticket %s: %w", id, err)
+				}
+				tickets = append(tickets, ticket)
+				return nil
+			})
+		}
+		return tx.Bucket(bucketTickets).ForEach(func(key, value []byte) error {
+			var ticket Ticket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			if status == "" || ticket.Status == status {
+				tickets = append(tickets, ticket)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].ID < tickets[j].ID })
+	return tickets, nil
+}
+
+// SaveReminder stores a reminder, assigning the next ID to a new one
+func (s *BoltStore) SaveReminder(_ context.Context, reminder *Reminder) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketReminders)
+		if reminder.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			reminder.ID = int64(id)
+		}
+		return putJSON(bucket, int64Key(reminder.ID), reminder)
+	})
+}
+
+// GetReminder returns a reminder
+func (s *BoltStore) GetReminder(_ context.Context, id int64) (*Reminder, error) {
+	var reminder Reminder
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getJSON(tx.Bucket(bucketReminders), int64Key(id), &reminder)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// DeleteReminder removes a reminder
+func (s *BoltStore) DeleteReminder(_ context.Context, id int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketReminders)
+		if bucket.Get(int64Key(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(int64Key(id))
+	})
+}
+
+// ListReminders returns the reminders of a user ordered by due time
+func (s *BoltStore) ListReminders(_ context.Context, userID int64) ([]Reminder, error) {
+	return s.listReminders(func(reminder *Reminder) bool { return reminder.UserID == userID })
+}
+
+// DueReminders returns the reminders due at or before now ordered by due time
+func (s *BoltStore) DueReminders(_ context.Context, now time.Time) ([]Reminder, error) {
+	return s.listReminders(func(reminder *Reminder) bool { return !reminder.DueAt.After(now) })
+}
+
+// listReminders returns the reminders matching a filter ordered by due time
+func (s *BoltStore) listReminders(match func(reminder *Reminder) bool) ([]Reminder, error) {
+	var reminders []Reminder
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketReminders).ForEach(func(key, value []byte) error {
+			var reminder Reminder
+			if err := json.Unmarshal(value, &reminder); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			if match(&reminder) {
+				reminders = append(reminders, reminder)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortReminders(reminders)
+	return reminders, nil
+}
+
+// SaveSchedule stores a scheduled job, assigning the next ID to a new one
+func (s *BoltStore) SaveSchedule(_ context.Context, schedule *Schedule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketSchedules)
+		if schedule.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			schedule.ID = int64(id)
+		}
+		return putJSON(bucket, int64Key(schedule.ID), schedule)
+	})
+}
+
+// DeleteSchedule removes a scheduled job
+func (s *BoltStore) DeleteSchedule(_ context.Context, id int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketSchedules)
+		if bucket.Get(int64Key(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(int64Key(id))
+	})
+}
+
+// ListSchedules returns the scheduled jobs ordered by ID
+func (s *BoltStore) ListSchedules(_ context.Context) ([]Schedule, error) {
+	var schedules []Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSchedules).ForEach(func(key, value []byte) error {
+			var schedule Schedule
+			if err := json.Unmarshal(value, &schedule); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			schedules = append(schedules, schedule)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules, nil
+}
+
+// RecordUsage adds a request to its usage aggregate
+func (s *BoltStore) RecordUsage(_ context.Context, record UsageRecord) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUsage)
+		key := []byte(usageKey(record))
+
+		var existing UsageRecord
+		err := getJSON(bucket, key, &existing)
+		switch {
+		case err == ErrNotFound:
+			existing = record
+		case err != nil:
+			return err
+		default:
+			existing.add(record)
+		}
+		return putJSON(bucket, key, existing)
+	})
+}
+
+// ListUsage returns the usage aggregates of the days from..to, inclusive
+func (s *BoltStore) ListUsage(_ context.Context, from, to string) ([]UsageRecord, error) {
+	var records []UsageRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Keys start with the day, so the range is a contiguous cursor scan
+		c := tx.Bucket(bucketUsage).Cursor()
+		for key, value := c.Seek([]byte(from)); key != nil && usageDay(key) <= to; key, value = c.Next() {
+			var record UsageRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// DeleteUserData erases everything stored about a user
+func (s *BoltStore) DeleteUserData(_ context.Context, userID int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketUsers).Delete(int64Key(userID)); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(bucketChats).Delete(int64Key(userID)); err != nil {
+			return err
+		}
+
+		conversations := tx.Bucket(bucketConversations)
+		if err := conversations.Delete(int64Key(userID)); err != nil {
+			return err
+		}
+		err := updateConversations(conversations, func(conv *Conversation) bool {
+			return conv.removeUserMessages(userID)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = deleteMatching(tx.Bucket(bucketQuotas), func(_, value []byte) (bool, error) {
+			var quota Quota
+			if err := json.Unmarshal(value, &quota); err != nil {
+				return false, err
+			}
+			return quota.UserID == userID, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = deleteMatching(tx.Bucket(bucketUsage), func(_, value []byte) (bool, error) {
+			var record UsageRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return false, err
+			}
+			return record.UserID == userID, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = deleteMatching(tx.Bucket(bucketTickets), func(_, value []byte) (bool, error) {
+			var ticket Ticket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return false, err
+			}
+			return ticket.UserID == userID, nil
+		})
+		if err != nil {
+			return err
+		}
+		tickets := tx.Bucket(bucketTickets)
+		err = deleteMatching(tx.Bucket(bucketOpenTickets), func(_, id []byte) (bool, error) {
+			return tickets.Get(id) == nil, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return deleteMatching(tx.Bucket(bucketReminders), func(_, value []byte) (bool, error) {
+			var reminder Reminder
+			if err := json.Unmarshal(value, &reminder); err != nil {
+				return false, err
+			}
+			return reminder.UserID == userID, nil
+		})
+	})
+}
+
+// PurgeBefore removes conversation messages and quotas older than cutoff
+func (s *BoltStore) PurgeBefore(_ context.Context, cutoff time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		removed = 0
+		err := updateConversations(tx.Bucket(bucketConversations), func(conv *Conversation) bool {
+			n := conv.removeMessagesBefore(cutoff)
+			removed += n
+			return n > 0
+		})
+		if err != nil {
+			return err
+		}
+
+		err = deleteMatching(tx.Bucket(bucketQuotas), func(_, value []byte) (bool, error) {
+			var quota Quota
+			if err := json.Unmarshal(value, &quota); err != nil {
+				return false, err
+			}
+			return periodBefore(quota.Period, cutoff), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = deleteMatching(tx.Bucket(bucketUsage), func(key, _ []byte) (bool, error) {
+			return periodBefore(usageDay(key), cutoff), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return deleteMatching(tx.Bucket(bucketTickets), func(_, value []byte) (bool, error) {
+			var ticket Ticket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return false, err
+			}
+			return ticket.closedBefore(cutoff), nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Ping verifies the database accepts writes
+func (s *BoltStore) Ping(_ context.Context) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(pingKey, []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+}
+
+// Close closes the database file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// updateConversations applies fn to every stored conversation, saving the ones it
+// changed and deleting the ones left without messages
+func updateConversations(bucket *bolt.Bucket, fn func(conv *Conversation) bool) error {
+	type change struct {
+		key  []byte
+		conv *Conversation
+	}
+	var changes []change
+
+	err := bucket.ForEach(func(key, value []byte) error {
+		var conv Conversation
+		if err := json.Unmarshal(value, &conv); err != nil {
+			return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+		}
+		if fn(&conv) {
+			changes = append(changes, change{key: append([]byte(nil), key...), conv: &conv})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Buckets must not be modified while iterating, so apply changes afterwards
+	for _, c := range changes {
+		if len(c.conv.Messages) == 0 {
+			err = bucket.Delete(c.key)
+		} else {
+			err = putJSON(bucket, c.key, c.conv)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteMatching deletes every key of the bucket for which match returns true
+func deleteMatching(bucket *bolt.Bucket, match func(key, value []byte) (bool, error)) error {
+	var keys [][]byte
+	err := bucket.ForEach(func(key, value []byte) error {
+		ok, err := match(key, value)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", key, err)
+		}
+		if ok {
+			keys = append(keys, append([]byte(nil), key...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCounter parses a counter value, treating a missing key as zero
+func readCounter(bucket *bolt.Bucket, name string) (int64, error) {
+	raw := bucket.Get([]byte(name))
+	if raw == nil {
+		return 0, nil
+	}
+	value, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse counter %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// getJSON decodes the value stored under key into v
+func getJSON(bucket *bolt.Bucket, key []byte, v any) error {
+	raw := bucket.Get(key)
+	if raw == nil {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+	}
+	return nil
+}
+
+// putJSON encodes v and stores it under key
+func putJSON(bucket *bolt.Bucket, key []byte, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	return bucket.Put(key, raw)
+}
+
+// int64Key encodes an ID as a bucket key
+func int64Key(id int64) []byte {
+	return []byte(strconv.FormatInt(id, 10))
+}
+
+// usageKey builds the key of a usage aggregate; it starts with the day so
+// aggregates are ordered by date
+func usageKey(record UsageRecord) string {
+	return fmt.Sprintf("%s|%d|%d|%s", record.Day, record.UserID, record.ChatID, record.Model)
+}
+
+// usageDay extracts the day from a usage key
+func usageDay(key []byte) string {
+	day, _, _ := strings.Cut(string(key), "|")
+	return day
+}
+
+// quotaKey builds the key of a user's quota within a period
+func quotaKey(userID int64, period string) string {
+	return strconv.FormatInt(userID, 10) + ":" + period
+}
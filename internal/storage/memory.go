@@ -0,0 +1,518 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps all data in memory; it is lost on restart
+type MemoryStore struct {
+	mu             sync.RWMutex
+	conversations  map[int64]*Conversation
+	users          map[int64]*UserSettings
+	quotas         map[string]*Quota
+	counters       map[string]int64
+	usage          map[string]*UsageRecord
+	chats          map[int64]*ChatInfo
+	tickets        map[int64]*Ticket
+	lastTicketID   int64
+	openTickets    map[int64]int64
+	reminders      map[int64]*Reminder
+	lastReminderID int64
+	schedules      map[int64]*Schedule
+	lastScheduleID int64
+	maxMessages    int
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[int64]*Conversation),
+		users:         make(map[int64]*UserSettings),
+		quotas:        make(map[string]*Quota),
+		counters:      make(map[string]int64),
+		usage:         make(map[string]*UsageRecord),
+		chats:         make(map[int64]*ChatInfo),
+		tickets:       make(map[int64]*Ticket),
+		openTickets:   make(map[int64]int64),
+		reminders:     make(map[int64]*Reminder),
+		schedules:     make(map[int64]*Schedule),
+	}
+}
+
+// SetMaxMessages limits how many messages a conversation keeps (0 = unlimited)
+func (s *MemoryStore) SetMaxMessages(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxMessages = n
+}
+
+// GetConversation returns the conversation for a chat
+func (s *MemoryStore) GetConversation(_ context.Context, chatID int64) (*Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if conv, ok := s.conversations[chatID]; ok {
+		return conv.clone(), nil
+	}
+	return &Conversation{ChatID: chatID}, nil
+}
+
+// AppendMessages appends messages to the conversation for a chat
+func (s *MemoryStore) AppendMessages(_ context.Context, chatID int64, messages ...ConversationMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, ok := s.conversations[chatID]
+	if !ok {
+		conv = &Conversation{ChatID: chatID}
+		s.conversations[chatID] = conv
+	}
+	conv.Messages = append(conv.Messages, messages...)
+	conv.trim(s.maxMessages)
+	conv.UpdatedAt = time.Now()
+	return nil
+}
+
+// SaveConversation replaces the stored conversation
+func (s *MemoryStore) SaveConversation(_ context.Context, conversation *Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := conversation.clone()
+	conv.trim(s.maxMessages)
+	s.conversations[conversation.ChatID] = conv
+	return nil
+}
+
+// DeleteConversation removes the conversation for a chat
+func (s *MemoryStore) DeleteConversation(_ context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conversations, chatID)
+	return nil
+}
+
+// GetUserSettings returns the settings for a user
+func (s *MemoryStore) GetUserSettings(_ context.Context, userID int64) (*UserSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *settings
+	return &cp, nil
+}
+
+// SaveUserSettings stores the settings for a user
+func (s *MemoryStore) SaveUserSettings(_ context.Context, settings *UserSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *settings
+	s.users[settings.UserID] = &cp
+	return nil
+}
+
+// GetQuota returns the usage of a user within a period
+func (s *MemoryStore) GetQuota(_ context.Context, userID int64, period string) (*Quota, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if quota, ok := s.quotas[quotaKey(userID, period)]; ok {
+		cp := *quota
+		return &cp, nil
+	}
+	return &Quota{UserID: userID, Period: period}, nil
+}
+
+// IncrementQuota adds requests and tokens to a user's usage within a period
+func (s *MemoryStore) IncrementQuota(_ context.Context, userID int64, period string, requests, tokens int) (*Quota, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := quotaKey(userID, period)
+	quota, ok := s.quotas[key]
+	if !ok {
+		quota = &Quota{UserID: userID, Period: period}
+		s.quotas[key] = quota
+	}
+	quota.Requests += requests
+	quota.Tokens += tokens
+
+	cp := *quota
+	return &cp, nil
+}
+
+// GetCounter returns the value of a named counter
+func (s *MemoryStore) GetCounter(_ context.Context, name string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.counters[name], nil
+}
+
+// IncrementCounter adds delta to a named counter
+func (s *MemoryStore) IncrementCounter(_ context.Context, name string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[name] += delta
+	return s.counters[name], nil
+}
+
+// TouchChat records a chat the bot has seen
+func (s *MemoryStore) TouchChat(_ context.Context, chat ChatInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.chats[chat.ChatID]; ok {
+		existing.touch(chat)
+		return nil
+	}
+	cp := chat
+	s.chats[chat.ChatID] = &cp
+	return nil
+}
+
+// GetChat returns a known chat
+func (s *MemoryStore) GetChat(_ context.Context, chatID int64) (*ChatInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *chat
+	return &cp, nil
+}
+
+// SetChatBlocked marks a chat as blocked or unblocked
+func (s *MemoryStore) SetChatBlocked(_ context.Context, chatID int64, blocked bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if chat, ok := s.chats[chatID]; ok {
+		chat.Blocked = blocked
+	}
+	return nil
+}
+
+// SetChatPersona selects the persona of a known chat
+func (s *MemoryStore) SetChatPersona(_ context.Context, chatID int64, persona string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return ErrNotFound
+	}
+	chat.Persona = persona
+	return nil
+}
+
+// ListChats returns every known chat ordered by ID
+func (s *MemoryStore) ListChats(_ context.Context) ([]ChatInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chats := make([]ChatInfo, 0, len(s.chats))
+	for _, chat := range s.chats {
+		chats = append(chats, *chat)
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ChatID < chats[j].ChatID })
+	return chats, nil
+}
+
+// SaveTicket stores a ticket, assigning the next ID to a new one
+func (s *MemoryStore) SaveTicket(_ context.Context, ticket *Ticket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ticket.ID == 0 {
+		s.lastTicketID++
+		ticket.ID = s.lastTicketID
+	}
+	cp := *ticket
+	cp.MessageIDs = slices.Clone(ticket.MessageIDs)
+	s.tickets[ticket.ID] = &cp
+	if ticket.Status == TicketOpen {
+		s.openTickets[ticket.ChatID] = ticket.ID
+	} else if s.openTickets[ticket.ChatID] == ticket.ID {
+		delete(s.openTickets, ticket.ChatID)
+	}
+	return nil
+}
+
+// OpenTicket returns the open ticket of a chat
+func (s *MemoryStore) OpenTicket(_ context.Context, chatID int64) (*Ticket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ticket, ok := s.tickets[s.openTickets[chatID]]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *ticket
+	cp.MessageIDs = slices.Clone(ticket.MessageIDs)
+	return &cp, nil
+}
+
+// GetTicket returns a ticket
+func (s *MemoryStore) GetTicket(_ context.Context, id int64) (*Ticket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ticket, ok := s.tickets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *ticket
+	cp.MessageIDs = slices.Clone(ticket.MessageIDs)
+	return &cp, nil
+}
+
+// ListTickets returns the tickets with the given status ordered by ID
+func (s *MemoryStore) ListTickets(_ context.Context, status string) ([]Ticket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tickets := make([]Ticket, 0, len(s.tickets))
+	for _, ticket := range s.tickets {
+		if status == "" || ticket.Status == status {
+			cp := *ticket
+			cp.MessageIDs = slices.Clone(ticket.MessageIDs)
+			tickets = append(tickets, cp)
+		}
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].ID < tickets[j].ID })
+	return tickets, nil
+}
+
+// SaveReminder stores a reminder, assigning the next ID to a new one
+func (s *MemoryStore) SaveReminder(_ context.Context, reminder *Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reminder.ID == 0 {
+		s.lastReminderID++
+		reminder.ID = s.lastReminderID
+	}
+	cp := *reminder
+	s.reminders[reminder.ID] = &cp
+	return nil
+}
+
+// GetReminder returns a reminder
+func (s *MemoryStore) GetReminder(_ context.Context, id int64) (*Reminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reminder, ok := s.reminders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *reminder
+	return &cp, nil
+}
+
+// DeleteReminder removes a reminder
+func (s *MemoryStore) DeleteReminder(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reminders[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.reminders, id)
+	return nil
+}
+
+// ListReminders returns the reminders of a user ordered by due time
+func (s *MemoryStore) ListReminders(_ context.Context, userID int64) ([]Reminder, error) {
+	return s.listReminders(func(reminder *Reminder) bool { return reminder.UserID == userID }), nil
+}
+
+// DueReminders returns the reminders due at or before now ordered by due time
+func (s *MemoryStore) DueReminders(_ context.Context, now time.Time) ([]Reminder, error) {
+	return s.listReminders(func(reminder *Reminder) bool { return !reminder.DueAt.After(now) }), nil
+}
+
+// listReminders returns the reminders matching a filter ordered by due time
+func (s *MemoryStore) listReminders(match func(reminder *Reminder) bool) []Reminder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reminders []Reminder
+	for _, reminder := range s.reminders {
+		if match(reminder) {
+			reminders = append(reminders, *reminder)
+		}
+	}
+	sortReminders(reminders)
+	return reminders
+}
+
+// SaveSchedule stores a scheduled job, assigning the next ID to a new one
+func (s *MemoryStore) SaveSchedule(_ context.Context, schedule *Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if schedule.ID == 0 {
+		s.lastScheduleID++
+		schedule.ID = s.lastScheduleID
+	}
+	cp := *schedule
+	s.schedules[schedule.ID] = &cp
+	return nil
+}
+
+// DeleteSchedule removes a scheduled job
+func (s *MemoryStore) DeleteSchedule(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.schedules, id)
+	return nil
+}
+
+// ListSchedules returns the scheduled jobs ordered by ID
+func (s *MemoryStore) ListSchedules(_ context.Context) ([]Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	schedules := make([]Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		schedules = append(schedules, *schedule)
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules, nil
+}
+
+// RecordUsage adds a request to its usage aggregate
+func (s *MemoryStore) RecordUsage(_ context.Context, record UsageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := usageKey(record)
+	if existing, ok := s.usage[key]; ok {
+		existing.add(record)
+		return nil
+	}
+	cp := record
+	s.usage[key] = &cp
+	return nil
+}
+
+// ListUsage returns the usage aggregates of the days from..to, inclusive
+func (s *MemoryStore) ListUsage(_ context.Context, from, to string) ([]UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.usage))
+	for key, record := range s.usage {
+		if record.Day >= from && record.Day <= to {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	records := make([]UsageRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, *s.usage[key])
+	}
+	return records, nil
+}
+
+// DeleteUserData erases everything stored about a user
+func (s *MemoryStore) DeleteUserData(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, userID)
+	delete(s.conversations, userID)
+	delete(s.chats, userID)
+
+	for chatID, conv := range s.conversations {
+		if conv.removeUserMessages(userID) && len(conv.Messages) == 0 {
+			delete(s.conversations, chatID)
+		}
+	}
+	for key, quota := range s.quotas {
+		if quota.UserID == userID {
+			delete(s.quotas, key)
+		}
+	}
+	for key, record := range s.usage {
+		if record.UserID == userID {
+			delete(s.usage, key)
+		}
+	}
+	for id, ticket := range s.tickets {
+		if ticket.UserID == userID {
+			delete(s.tickets, id)
+			if s.openTickets[ticket.ChatID] == id {
+				delete(s.openTickets, ticket.ChatID)
+			}
+		}
+	}
+	for id, reminder := range s.reminders {
+		if reminder.UserID == userID {
+			delete(s.reminders, id)
+		}
+	}
+
+	return nil
+}
+
+// PurgeBefore removes conversation messages and quotas older than cutoff
+func (s *MemoryStore) PurgeBefore(_ context.Context, cutoff time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for chatID, conv := range s.conversations {
+		removed += conv.removeMessagesBefore(cutoff)
+		if len(conv.Messages) == 0 {
+			delete(s.conversations, chatID)
+		}
+	}
+	for key, quota := range s.quotas {
+		if periodBefore(quota.Period, cutoff) {
+			delete(s.quotas, key)
+		}
+	}
+	for key, record := range s.usage {
+		if periodBefore(record.Day, cutoff) {
+			delete(s.usage, key)
+		}
+	}
+	for id, ticket := range s.tickets {
+		if ticket.closedBefore(cutoff) {
+			delete(s.tickets, id)
+		}
+	}
+
+	return removed, nil
+}
+
+// Ping always succeeds for the in-memory store
+func (s *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+// Close is a no-op for the in-memory store
+func (s *MemoryStore) Close() error {
+	return nil
+}
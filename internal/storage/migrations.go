@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// schemaVersionKey is the meta bucket key holding the applied schema version
+var schemaVersionKey = []byte("schema_version")
+
+// migration upgrades the bolt schema to version
+type migration struct {
+	version int
+	name    string
+	up      func(tx *bolt.Tx) error
+}
+
+// migrations lists every schema change in order; append new ones at the end
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create initial buckets",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketConversations, bucketUsers, bucketQuotas, bucketCounters)
+		},
+	},
+	{
+		version: 2,
+		name:    "create usage bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketUsage)
+		},
+	},
+	{
+		version: 3,
+		name:    "create chats bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketChats)
+		},
+	},
+	{
+		version: 4,
+		name:    "create tickets bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketTickets)
+		},
+	},
+	{
+		version: 5,
+		name:    "create reminders bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketReminders)
+		},
+	},
+	{
+		version: 6,
+		name:    "create schedules bucket",
+		up: func(tx *bolt.Tx) error {
+			return createBuckets(tx, bucketSchedules)
+		},
+	},
+	{
+		version: 7,
+		name:    "index open tickets by chat",
+		up: func(tx *bolt.Tx) error {
+			if err := createBuckets(tx, bucketOpenTickets); err != nil {
+				return err
+			}
+			index := tx.Bucket(bucketOpenTickets)
+			return tx.Bucket(bucketTickets).ForEach(func(key, value []byte) error {
+				var ticket Ticket
+				if err := json.Unmarshal(value, &ticket); err != nil {
+					return fmt.Errorf("failed to unmarshal %s: %w", key, err)
+				}
+				return indexOpenTicket(index, &ticket)
+			})
+		},
+	},
+}
+
+// SchemaVersion returns the latest schema version known to this build
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies pending migrations, each in its own transaction
+func (s *BoltStore) migrate() error {
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return createBuckets(tx, bucketMeta)
+	}); err != nil {
+		return err
+	}
+
+	current, err := s.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > SchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, SchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Bucket(bucketMeta).Put(schemaVersionKey, []byte(strconv.Itoa(m.version)))
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+
+		s.logger.Info("applied storage migration",
+			zap.Int("version", m.version),
+			zap.String("name", m.name),
+		)
+	}
+
+	return nil
+}
+
+// schemaVersion reads the applied schema version, 0 for a new database
+func (s *BoltStore) schemaVersion() (int, error) {
+	var version int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketMeta).Get(schemaVersionKey)
+		if raw == nil {
+			return nil
+		}
+		var err error
+		version, err = strconv.Atoi(string(raw))
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// createBuckets creates the named buckets if they do not exist
+func createBuckets(tx *bolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", name, err)
+		}
+	}
+	return nil
+}
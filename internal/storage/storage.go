@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Supported storage backends
+const (
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// Store persists conversations, user settings, quotas and counters
+type Store interface {
+	// GetConversation returns the conversation for a chat, or an empty one if none is stored
+	GetConversation(ctx context.Context, chatID int64) (*Conversation, error)
+	// AppendMessages appends messages to the conversation for a chat
+	AppendMessages(ctx context.Context, chatID int64, messages ...ConversationMessage) error
+	// SaveConversation replaces the stored conversation
+	SaveConversation(ctx context.Context, conversation *Conversation) error
+	// DeleteConversation removes the conversation for a chat
+	DeleteConversation(ctx context.Context, chatID int64) error
+
+	// GetUserSettings returns the settings for a user or ErrNotFound
+	GetUserSettings(ctx context.Context, userID int64) (*UserSettings, error)
+	// SaveUserSettings stores the settings for a user
+	SaveUserSettings(ctx context.Context, settings *UserSettings) error
+
+	// GetQuota returns the usage of a user within a period, e.g. a day "2006-01-02"
+	GetQuota(ctx context.Context, userID int64, period string) (*Quota, error)
+	// IncrementQuota adds requests and tokens to a user's usage within a period
+	IncrementQuota(ctx context.Context, userID int64, period string, requests, tokens int) (*Quota, error)
+
+	// GetCounter returns the value of a named counter
+	GetCounter(ctx context.Context, name string) (int64, error)
+	// IncrementCounter adds delta to a named counter and returns the new value
+	IncrementCounter(ctx context.Context, name string, delta int64) (int64, error)
+
+	// TouchChat records a chat the bot has seen, updating its details and last
+	// activity and clearing its blocked flag
+	TouchChat(ctx context.Context, chat ChatInfo) error
+	// GetChat returns a known chat or ErrNotFound
+	GetChat(ctx context.Context, chatID int64) (*ChatInfo, error)
+	// SetChatBlocked marks a chat as blocked, e.g. after the user blocked the bot
+	SetChatBlocked(ctx context.Context, chatID int64, blocked bool) error
+	// SetChatPersona selects the persona of a known chat; empty means the default
+	SetChatPersona(ctx context.Context, chatID int64, persona string) error
+	// ListChats returns every known chat ordered by ID
+	ListChats(ctx context.Context) ([]ChatInfo, error)
+
+	// SaveTicket stores a ticket, assigning the next ID to a new one
+	SaveTicket(ctx context.Context, ticket *Ticket) error
+	// GetTicket returns a ticket or ErrNotFound
+	GetTicket(ctx context.Context, id int64) (*Ticket, error)
+	// OpenTicket returns the open ticket of a chat or ErrNotFound
+	OpenTicket(ctx context.Context, chatID int64) (*Ticket, error)
+	// ListTickets returns the tickets with the given status ordered by ID; an
+	// empty status lists all tickets
+	ListTickets(ctx context.Context, status string) ([]Ticket, error)
+
+	// SaveReminder stores a reminder, assigning the next ID to a new one
+	SaveReminder(ctx context.Context, reminder *Reminder) error
+	// GetReminder returns a reminder or ErrNotFound
+	GetReminder(ctx context.Context, id int64) (*Reminder, error)
+	// DeleteReminder removes a delivered or canceled reminder
+	DeleteReminder(ctx context.Context, id int64) error
+	// ListReminders returns the reminders of a user ordered by due time
+	ListReminders(ctx context.Context, userID int64) ([]Reminder, error)
+	// DueReminders returns the reminders due at or before now ordered by due time
+	DueReminders(ctx context.Context, now time.Time) ([]Reminder, error)
+
+	// SaveSchedule stores a scheduled job, assigning the next ID to a new one
+	SaveSchedule(ctx context.Context, schedule *Schedule) error
+	// DeleteSchedule removes a scheduled job or returns ErrNotFound
+	DeleteSchedule(ctx context.Context, id int64) error
+	// ListSchedules returns the scheduled jobs ordered by ID
+	ListSchedules(ctx context.Context) ([]Schedule, error)
+
+	// RecordUsage adds a request to the usage aggregate of its day, user, chat and model
+	RecordUsage(ctx context.Context, record UsageRecord) error
+	// ListUsage returns the usage aggregates of the days from..to ("2006-01-02"), inclusive
+	ListUsage(ctx context.Context, from, to string) ([]UsageRecord, error)
+
+	// DeleteUserData erases everything stored about a user: settings, quotas, usage,
+	// tickets, reminders, their private chat and conversation and their messages in group conversations
+	DeleteUserData(ctx context.Context, userID int64) error
+	// PurgeBefore removes conversation messages, quotas, usage and tickets closed
+	// before cutoff and returns the number of removed messages
+	PurgeBefore(ctx context.Context, cutoff time.Time) (int, error)
+
+	// Ping verifies that the store is reachable and writable
+	Ping(ctx context.Context) error
+	// Close releases resources held by the store
+	Close() error
+}
+
+// Conversation holds the message history of a chat
+type Conversation struct {
+	ChatID    int64                 `json:"chat_id"`
+	Messages  []ConversationMessage `json:"messages"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// ConversationMessage represents a single stored message
+type ConversationMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Model   string `json:"model,omitempty"`
+	UserID  int64  `json:"user_id,omitempty"`
+	// MessageID is the Telegram message that shows an assistant answer
+	MessageID int       `json:"message_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UserSettings holds per-user profile and preferences
+type UserSettings struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"username,omitempty"`
+	FirstName    string `json:"first_name,omitempty"`
+	LanguageCode string `json:"language_code,omitempty"`
+	// Language is the language chosen with /language, overriding LanguageCode
+	Language string `json:"language,omitempty"`
+	// Timezone is the IANA timezone chosen with /timezone, e.g. "Europe/Berlin"
+	Timezone  string    `json:"timezone,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Quota holds the usage of a user within a period
+type Quota struct {
+	UserID   int64  `json:"user_id"`
+	Period   string `json:"period"`
+	Requests int    `json:"requests"`
+	Tokens   int    `json:"tokens"`
+}
+
+// ChatInfo describes a chat the bot has seen
+type ChatInfo struct {
+	ChatID    int64     `json:"chat_id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Blocked   bool      `json:"blocked,omitempty"`
+	Persona   string    `json:"persona,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// touch merges newer details of a chat into the stored record
+func (c *ChatInfo) touch(update ChatInfo) {
+	c.Type = update.Type
+	c.Title = update.Title
+	c.Username = update.Username
+	c.Blocked = false
+	c.LastSeen = update.LastSeen
+}
+
+// Ticket statuses
+const (
+	TicketOpen   = "open"
+	TicketClosed = "closed"
+)
+
+// Ticket is a conversation handed off to a human operator
+type Ticket struct {
+	ID     int64  `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+	// Reason tells how the ticket was opened, e.g. "command" or "escalation"
+	Reason string `json:"reason"`
+	// ThreadID is the forum topic of the ticket in the operator chat, or the
+	// message starting its reply thread when the chat has no topics
+	ThreadID int  `json:"thread_id"`
+	Forum    bool `json:"forum,omitempty"`
+	// MessageIDs are the messages relayed to the operator chat, which operators reply to
+	MessageIDs []int     `json:"message_ids,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ClosedAt   time.Time `json:"closed_at,omitempty"`
+	// ClosedBy is the operator who closed the ticket
+	ClosedBy int64 `json:"closed_by,omitempty"`
+}
+
+// HasMessage reports whether a message of the operator chat belongs to the ticket
+func (t *Ticket) HasMessage(messageID int) bool {
+	return messageID != 0 && (messageID == t.ThreadID || slices.Contains(t.MessageIDs, messageID))
+}
+
+// closedBefore reports whether the ticket was closed before cutoff
+func (t *Ticket) closedBefore(cutoff time.Time) bool {
+	return t.Status == TicketClosed && t.ClosedAt.Before(cutoff)
+}
+
+// Reminder is a message the bot sends to a chat at a set time
+type Reminder struct {
+	ID     int64     `json:"id"`
+	ChatID int64     `json:"chat_id"`
+	UserID int64     `json:"user_id"`
+	Text   string    `json:"text"`
+	DueAt  time.Time `json:"due_at"`
+	// MessageID is the message that set the reminder, which the reminder replies to
+	MessageID int `json:"message_id,omitempty"`
+	// Attempts counts failed deliveries
+	Attempts  int       `json:"attempts,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// sortReminders orders reminders by due time, then by ID
+func sortReminders(reminders []Reminder) {
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].DueAt.Equal(reminders[j].DueAt) {
+			return reminders[i].DueAt.Before(reminders[j].DueAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+}
+
+// Schedule is a job added with /schedule that posts an AI answer to a prompt
+// to a chat on a cron schedule
+type Schedule struct {
+	ID     int64  `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	Spec   string `json:"spec"`
+	Prompt string `json:"prompt"`
+	// Persona answers the prompt; empty uses the chat's persona
+	Persona   string    `json:"persona,omitempty"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UsageRecord aggregates the AI usage of a user in a chat with a model on a day
+type UsageRecord struct {
+	Day              string  `json:"day"`
+	UserID           int64   `json:"user_id"`
+	ChatID           int64   `json:"chat_id"`
+	Model            string  `json:"model"`
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	// LatencyMs is the total AI response time of the requests in milliseconds
+	LatencyMs int64 `json:"latency_ms"`
+}
+
+// add accumulates another record of the same aggregate
+func (r *UsageRecord) add(other UsageRecord) {
+	r.Requests += other.Requests
+	r.PromptTokens += other.PromptTokens
+	r.CompletionTokens += other.CompletionTokens
+	r.Cost += other.Cost
+	r.LatencyMs += other.LatencyMs
+}
+
+// New creates a store for the given backend and runs pending schema migrations.
+// Conversations keep at most maxMessages messages (0 = unlimited).
+func New(backend, path string, maxMessages int, logger *zap.Logger) (Store, error) {
+	switch backend {
+	case "", BackendMemory:
+		logger.Info("using in-memory storage")
+		store := NewMemoryStore()
+		store.SetMaxMessages(maxMessages)
+		return store, nil
+	case BackendBolt:
+		logger.Info("using bolt storage", zap.String("path", path))
+		store, err := NewBoltStore(path, logger)
+		if err != nil {
+			return nil, err
+		}
+		store.SetMaxMessages(maxMessages)
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Tail returns the last n messages of the conversation, or all of them if n <= 0
+func (c *Conversation) Tail(n int) []ConversationMessage {
+	if n <= 0 || len(c.Messages) <= n {
+		return c.Messages
+	}
+	return c.Messages[len(c.Messages)-n:]
+}
+
+// trim drops the oldest messages beyond the last n; n <= 0 keeps all of them
+func (c *Conversation) trim(n int) {
+	if n > 0 && len(c.Messages) > n {
+		c.Messages = append([]ConversationMessage(nil), c.Tail(n)...)
+	}
+}
+
+// AnswerIndex returns the index of the assistant message shown as the Telegram
+// message messageID, or -1
+func (c *Conversation) AnswerIndex(messageID int) int {
+	if messageID == 0 {
+		return -1
+	}
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "assistant" && c.Messages[i].MessageID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// QuestionIndex returns the index of the user message an answer replies to: the
+// closest user message before it, or -1
+func (c *Conversation) QuestionIndex(answer int) int {
+	for i := answer - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeUserMessages drops the user's messages and the assistant replies that
+// directly follow them. It reports whether anything was removed.
+func (c *Conversation) removeUserMessages(userID int64) bool {
+	kept := c.Messages[:0]
+	removed := false
+	dropReply := false
+
+	for _, msg := range c.Messages {
+		switch {
+		case msg.Role == "user" && msg.UserID == userID:
+			removed, dropReply = true, true
+			continue
+		case msg.Role == "assistant" && dropReply:
+			dropReply = false
+			continue
+		}
+		dropReply = false
+		kept = append(kept, msg)
+	}
+
+	c.Messages = kept
+	return removed
+}
+
+// removeMessagesBefore drops messages older than cutoff and returns how many were removed
+func (c *Conversation) removeMessagesBefore(cutoff time.Time) int {
+	kept := c.Messages[:0]
+	for _, msg := range c.Messages {
+		if msg.Timestamp.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, msg)
+	}
+
+	removed := len(c.Messages) - len(kept)
+	c.Messages = kept
+	return removed
+}
+
+// periodBefore reports whether a quota period ("2006-01-02") ends before cutoff
+func periodBefore(period string, cutoff time.Time) bool {
+	day, err := time.Parse("2006-01-02", period)
+	if err != nil {
+		return false
+	}
+	return day.AddDate(0, 0, 1).Before(cutoff)
+}
+
+// clone returns a deep copy of the conversation
+func (c *Conversation) clone() *Conversation {
+	cp := *c
+	cp.Messages = append([]ConversationMessage(nil), c.Messages...)
+	return &cp
+}
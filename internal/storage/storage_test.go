@@ -0,0 +1,654 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// newTestStores returns every backend under test
+func newTestStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "bot.db"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewBoltStore() error = %v", err)
+	}
+	t.Cleanup(func() { _ = bolt.Close() })
+
+	return map[string]Store{
+		BackendMemory: NewMemoryStore(),
+		BackendBolt:   bolt,
+	}
+}
+
+func TestStore_Conversations(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			conv, err := store.GetConversation(ctx, 42)
+			if err != nil {
+				t.Fatalf("GetConversation() error = %v", err)
+			}
+			if len(conv.Messages) != 0 {
+				t.Errorf("new conversation has %d messages, want 0", len(conv.Messages))
+			}
+
+			now := time.Now().UTC()
+			err = store.AppendMessages(ctx, 42,
+				ConversationMessage{Role: "user", Content: "hi", Timestamp: now},
+				ConversationMessage{Role: "assistant", Content: "hello", Model: "test-model", Timestamp: now},
+			)
+			if err != nil {
+				t.Fatalf("AppendMessages() error = %v", err)
+			}
+
+			conv, err = store.GetConversation(ctx, 42)
+			if err != nil {
+				t.Fatalf("GetConversation() error = %v", err)
+			}
+			if len(conv.Messages) != 2 || conv.Messages[1].Model != "test-model" {
+				t.Errorf("GetConversation() = %+v, want 2 messages", conv.Messages)
+			}
+			if tail := conv.Tail(1); len(tail) != 1 || tail[0].Content != "hello" {
+				t.Errorf("Tail(1) = %+v, want last message", tail)
+			}
+
+			// Mutating the returned value must not affect the store
+			conv.Messages[0].Content = "changed"
+			again, _ := store.GetConversation(ctx, 42)
+			if again.Messages[0].Content != "hi" {
+				t.Errorf("stored conversation was mutated through returned value")
+			}
+
+			if err := store.DeleteConversation(ctx, 42); err != nil {
+				t.Fatalf("DeleteConversation() error = %v", err)
+			}
+			conv, _ = store.GetConversation(ctx, 42)
+			if len(conv.Messages) != 0 {
+				t.Errorf("conversation not deleted")
+			}
+		})
+	}
+}
+
+func TestStore_MaxMessages(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.(interface{ SetMaxMessages(int) }).SetMaxMessages(3)
+
+			for _, content := range []string{"1", "2", "3", "4"} {
+				if err := store.AppendMessages(ctx, 42, ConversationMessage{Role: "user", Content: content}); err != nil {
+					t.Fatalf("AppendMessages() error = %v", err)
+				}
+			}
+			conv, err := store.GetConversation(ctx, 42)
+			if err != nil || len(conv.Messages) != 3 || conv.Messages[0].Content != "2" {
+				t.Errorf("GetConversation() = %+v, %v, want the last 3 messages", conv, err)
+			}
+
+			// Imported conversations are capped as well
+			imported := &Conversation{ChatID: 43}
+			for _, content := range []string{"a", "b", "c", "d", "e"} {
+				imported.Messages = append(imported.Messages, ConversationMessage{Role: "user", Content: content})
+			}
+			if err := store.SaveConversation(ctx, imported); err != nil {
+				t.Fatalf("SaveConversation() error = %v", err)
+			}
+			conv, err = store.GetConversation(ctx, 43)
+			if err != nil || len(conv.Messages) != 3 || conv.Messages[0].Content != "c" {
+				t.Errorf("GetConversation() = %+v, %v, want the last 3 imported messages", conv, err)
+			}
+			if len(imported.Messages) != 5 {
+				t.Errorf("SaveConversation() modified its argument")
+			}
+		})
+	}
+}
+
+func TestStore_UserSettings(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.GetUserSettings(ctx, 7); err != ErrNotFound {
+				t.Errorf("GetUserSettings() error = %v, want ErrNotFound", err)
+			}
+
+			settings := &UserSettings{UserID: 7, Username: "alice", LanguageCode: "en"}
+			if err := store.SaveUserSettings(ctx, settings); err != nil {
+				t.Fatalf("SaveUserSettings() error = %v", err)
+			}
+
+			got, err := store.GetUserSettings(ctx, 7)
+			if err != nil {
+				t.Fatalf("GetUserSettings() error = %v", err)
+			}
+			if got.Username != "alice" || got.LanguageCode != "en" {
+				t.Errorf("GetUserSettings() = %+v, want %+v", got, settings)
+			}
+		})
+	}
+}
+
+func TestStore_QuotasAndCounters(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.IncrementQuota(ctx, 1, "2024-01-01", 1, 100); err != nil {
+				t.Fatalf("IncrementQuota() error = %v", err)
+			}
+			quota, err := store.IncrementQuota(ctx, 1, "2024-01-01", 1, 50)
+			if err != nil {
+				t.Fatalf("IncrementQuota() error = %v", err)
+			}
+			if quota.Requests != 2 || quota.Tokens != 150 {
+				t.Errorf("IncrementQuota() = %+v, want 2 requests and 150 tokens", quota)
+			}
+
+			other, err := store.GetQuota(ctx, 1, "2024-01-02")
+			if err != nil {
+				t.Fatalf("GetQuota() error = %v", err)
+			}
+			if other.Requests != 0 {
+				t.Errorf("GetQuota() for other period = %+v, want empty", other)
+			}
+
+			if _, err := store.IncrementCounter(ctx, "messages", 3); err != nil {
+				t.Fatalf("IncrementCounter() error = %v", err)
+			}
+			value, err := store.GetCounter(ctx, "messages")
+			if err != nil || value != 3 {
+				t.Errorf("GetCounter() = %d, %v, want 3", value, err)
+			}
+		})
+	}
+}
+
+func TestBoltStore_Migrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	store, err := NewBoltStore(path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewBoltStore() error = %v", err)
+	}
+	version, err := store.schemaVersion()
+	if err != nil || version != SchemaVersion() {
+		t.Errorf("schemaVersion() = %d, %v, want %d", version, err, SchemaVersion())
+	}
+	if err := store.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if _, err := store.IncrementCounter(context.Background(), "persisted", 1); err != nil {
+		t.Fatalf("IncrementCounter() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Reopening an up-to-date database keeps data and does not fail
+	store, err = NewBoltStore(path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen NewBoltStore() error = %v", err)
+	}
+	defer store.Close()
+
+	value, err := store.GetCounter(context.Background(), "persisted")
+	if err != nil || value != 1 {
+		t.Errorf("GetCounter() after reopen = %d, %v, want 1", value, err)
+	}
+}
+
+func TestStore_DeleteUserData(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// Private chat of user 1 and a group chat shared with user 2
+			_ = store.AppendMessages(ctx, 1,
+				ConversationMessage{Role: "user", Content: "private", UserID: 1, Timestamp: now},
+				ConversationMessage{Role: "assistant", Content: "reply", Timestamp: now},
+			)
+			_ = store.AppendMessages(ctx, -100,
+				ConversationMessage{Role: "user", Content: "from 1", UserID: 1, Timestamp: now},
+				ConversationMessage{Role: "assistant", Content: "answer to 1", Timestamp: now},
+				ConversationMessage{Role: "user", Content: "from 2", UserID: 2, Timestamp: now},
+				ConversationMessage{Role: "assistant", Content: "answer to 2", Timestamp: now},
+			)
+			_ = store.SaveUserSettings(ctx, &UserSettings{UserID: 1})
+			_, _ = store.IncrementQuota(ctx, 1, "2024-01-01", 1, 0)
+			_, _ = store.IncrementQuota(ctx, 2, "2024-01-01", 1, 0)
+
+			if err := store.DeleteUserData(ctx, 1); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+
+			if conv, _ := store.GetConversation(ctx, 1); len(conv.Messages) != 0 {
+				t.Errorf("private conversation not deleted: %+v", conv.Messages)
+			}
+			group, _ := store.GetConversation(ctx, -100)
+			if len(group.Messages) != 2 || group.Messages[0].Content != "from 2" {
+				t.Errorf("group conversation = %+v, want only user 2 exchange", group.Messages)
+			}
+			if _, err := store.GetUserSettings(ctx, 1); err != ErrNotFound {
+				t.Errorf("GetUserSettings() error = %v, want ErrNotFound", err)
+			}
+			if quota, _ := store.GetQuota(ctx, 1, "2024-01-01"); quota.Requests != 0 {
+				t.Errorf("quota of deleted user = %+v, want empty", quota)
+			}
+			if quota, _ := store.GetQuota(ctx, 2, "2024-01-01"); quota.Requests != 1 {
+				t.Errorf("quota of other user = %+v, want kept", quota)
+			}
+		})
+	}
+}
+
+func TestStore_PurgeBefore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	old := now.AddDate(0, 0, -40)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_ = store.AppendMessages(ctx, 1,
+				ConversationMessage{Role: "user", Content: "old", Timestamp: old},
+				ConversationMessage{Role: "user", Content: "new", Timestamp: now},
+			)
+			_ = store.AppendMessages(ctx, 2,
+				ConversationMessage{Role: "user", Content: "old", Timestamp: old},
+			)
+			_, _ = store.IncrementQuota(ctx, 1, old.UTC().Format("2006-01-02"), 1, 0)
+			_, _ = store.IncrementQuota(ctx, 1, now.UTC().Format("2006-01-02"), 1, 0)
+
+			removed, err := store.PurgeBefore(ctx, now.AddDate(0, 0, -30))
+			if err != nil {
+				t.Fatalf("PurgeBefore() error = %v", err)
+			}
+			if removed != 2 {
+				t.Errorf("PurgeBefore() removed %d messages, want 2", removed)
+			}
+
+			if conv, _ := store.GetConversation(ctx, 1); len(conv.Messages) != 1 || conv.Messages[0].Content != "new" {
+				t.Errorf("conversation 1 = %+v, want only the new message", conv.Messages)
+			}
+			if conv, _ := store.GetConversation(ctx, 2); len(conv.Messages) != 0 {
+				t.Errorf("conversation 2 = %+v, want empty", conv.Messages)
+			}
+			if quota, _ := store.GetQuota(ctx, 1, old.UTC().Format("2006-01-02")); quota.Requests != 0 {
+				t.Errorf("old quota = %+v, want purged", quota)
+			}
+			if quota, _ := store.GetQuota(ctx, 1, now.UTC().Format("2006-01-02")); quota.Requests != 1 {
+				t.Errorf("current quota = %+v, want kept", quota)
+			}
+		})
+	}
+}
+
+func TestStore_Usage(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			records := []UsageRecord{
+				{Day: "2024-05-01", UserID: 1, ChatID: 1, Model: "gpt-4o", Requests: 1, PromptTokens: 100, CompletionTokens: 50, Cost: 0.5},
+				{Day: "2024-05-01", UserID: 1, ChatID: 1, Model: "gpt-4o", Requests: 1, PromptTokens: 10, CompletionTokens: 5, Cost: 0.25},
+				{Day: "2024-05-02", UserID: 2, ChatID: -100, Model: "gpt-4o-mini", Requests: 1, PromptTokens: 20, CompletionTokens: 10, Cost: 0.1},
+				{Day: "2024-06-01", UserID: 1, ChatID: 1, Model: "gpt-4o", Requests: 1, PromptTokens: 1, CompletionTokens: 1, Cost: 0.01},
+			}
+			for _, record := range records {
+				if err := store.RecordUsage(ctx, record); err != nil {
+					t.Fatalf("RecordUsage() error = %v", err)
+				}
+			}
+
+			got, err := store.ListUsage(ctx, "2024-05-01", "2024-05-31")
+			if err != nil {
+				t.Fatalf("ListUsage() error = %v", err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("ListUsage() = %+v, want 2 aggregates", got)
+			}
+			if got[0].Requests != 2 || got[0].PromptTokens != 110 || got[0].CompletionTokens != 55 || got[0].Cost != 0.75 {
+				t.Errorf("aggregate = %+v, want 2 requests, 110/55 tokens, cost 0.75", got[0])
+			}
+			if got[1].UserID != 2 || got[1].Model != "gpt-4o-mini" {
+				t.Errorf("second aggregate = %+v, want user 2 on gpt-4o-mini", got[1])
+			}
+
+			if err := store.DeleteUserData(ctx, 2); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+			if _, err := store.PurgeBefore(ctx, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+				t.Fatalf("PurgeBefore() error = %v", err)
+			}
+			got, _ = store.ListUsage(ctx, "2024-01-01", "2024-12-31")
+			if len(got) != 1 || got[0].Day != "2024-06-01" {
+				t.Errorf("ListUsage() after delete and purge = %+v, want only the June aggregate", got)
+			}
+		})
+	}
+}
+
+func TestStore_Chats(t *testing.T) {
+	ctx := context.Background()
+	first := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	later := first.Add(time.Hour)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			_ = store.TouchChat(ctx, ChatInfo{ChatID: 10, Type: "private", Username: "alice", FirstSeen: first, LastSeen: first})
+			_ = store.TouchChat(ctx, ChatInfo{ChatID: -100, Type: "group", Title: "Team", FirstSeen: first, LastSeen: first})
+			if err := store.SetChatBlocked(ctx, 10, true); err != nil {
+				t.Fatalf("SetChatBlocked() error = %v", err)
+			}
+
+			chats, err := store.ListChats(ctx)
+			if err != nil {
+				t.Fatalf("ListChats() error = %v", err)
+			}
+			if len(chats) != 2 || chats[0].ChatID != -100 || !chats[1].Blocked {
+				t.Fatalf("ListChats() = %+v, want group first and blocked private chat", chats)
+			}
+
+			if err := store.SetChatPersona(ctx, 10, "teacher"); err != nil {
+				t.Fatalf("SetChatPersona() error = %v", err)
+			}
+			if err := store.SetChatPersona(ctx, 99, "teacher"); err != ErrNotFound {
+				t.Errorf("SetChatPersona() for unknown chat error = %v, want ErrNotFound", err)
+			}
+
+			// A new message from the chat clears the blocked flag and keeps FirstSeen and persona
+			_ = store.TouchChat(ctx, ChatInfo{ChatID: 10, Type: "private", Username: "alice2", FirstSeen: later, LastSeen: later})
+			c, err := store.GetChat(ctx, 10)
+			if err != nil {
+				t.Fatalf("GetChat() error = %v", err)
+			}
+			if c.Blocked || c.Username != "alice2" || c.Persona != "teacher" || !c.FirstSeen.Equal(first) || !c.LastSeen.Equal(later) {
+				t.Errorf("touched chat = %+v, want unblocked with updated details", c)
+			}
+
+			if err := store.DeleteUserData(ctx, 10); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+			if chats, _ = store.ListChats(ctx); len(chats) != 1 {
+				t.Errorf("ListChats() after DeleteUserData = %+v, want only the group", chats)
+			}
+			if _, err := store.GetChat(ctx, 10); err != ErrNotFound {
+				t.Errorf("GetChat() after DeleteUserData error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestStore_Tickets(t *testing.T) {
+	ctx := context.Background()
+	opened := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := &Ticket{ChatID: 10, UserID: 10, Status: TicketOpen, ThreadID: 100, CreatedAt: opened}
+			second := &Ticket{ChatID: 20, UserID: 20, Status: TicketOpen, ThreadID: 200, CreatedAt: opened}
+			for _, ticket := range []*Ticket{first, second} {
+				if err := store.SaveTicket(ctx, ticket); err != nil {
+					t.Fatalf("SaveTicket() error = %v", err)
+				}
+			}
+			if first.ID == 0 || second.ID <= first.ID {
+				t.Fatalf("ticket IDs = %d, %d, want increasing IDs", first.ID, second.ID)
+			}
+
+			first.MessageIDs = append(first.MessageIDs, 101)
+			first.Status, first.ClosedAt = TicketClosed, opened.Add(time.Hour)
+			if err := store.SaveTicket(ctx, first); err != nil {
+				t.Fatalf("SaveTicket() error = %v", err)
+			}
+			got, err := store.GetTicket(ctx, first.ID)
+			if err != nil {
+				t.Fatalf("GetTicket() error = %v", err)
+			}
+			if got.Status != TicketClosed || !got.HasMessage(101) || !got.HasMessage(100) || got.HasMessage(200) {
+				t.Errorf("GetTicket() = %+v, want the closed ticket with its messages", got)
+			}
+			if _, err := store.GetTicket(ctx, 99); err != ErrNotFound {
+				t.Errorf("GetTicket() for unknown ticket error = %v, want ErrNotFound", err)
+			}
+
+			open, err := store.ListTickets(ctx, TicketOpen)
+			if err != nil {
+				t.Fatalf("ListTickets() error = %v", err)
+			}
+			if len(open) != 1 || open[0].ID != second.ID {
+				t.Errorf("ListTickets(open) = %+v, want the second ticket", open)
+			}
+			if all, _ := store.ListTickets(ctx, ""); len(all) != 2 {
+				t.Errorf("ListTickets() = %+v, want both tickets", all)
+			}
+			if _, err := store.OpenTicket(ctx, 10); err != ErrNotFound {
+				t.Errorf("OpenTicket() of a chat with a closed ticket error = %v, want ErrNotFound", err)
+			}
+			if got, err := store.OpenTicket(ctx, 20); err != nil || got.ID != second.ID || got.ThreadID != 200 {
+				t.Errorf("OpenTicket() = %+v, %v, want the second ticket", got, err)
+			}
+
+			// Retention removes closed tickets only
+			if _, err := store.PurgeBefore(ctx, opened.Add(2*time.Hour)); err != nil {
+				t.Fatalf("PurgeBefore() error = %v", err)
+			}
+			if all, _ := store.ListTickets(ctx, ""); len(all) != 1 || all[0].ID != second.ID {
+				t.Errorf("ListTickets() after PurgeBefore = %+v, want the open ticket", all)
+			}
+
+			if err := store.DeleteUserData(ctx, 20); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+			if all, _ := store.ListTickets(ctx, ""); len(all) != 0 {
+				t.Errorf("ListTickets() after DeleteUserData = %+v, want none", all)
+			}
+			if _, err := store.OpenTicket(ctx, 20); err != ErrNotFound {
+				t.Errorf("OpenTicket() after DeleteUserData error = %v, want ErrNotFound", err)
+			}
+			if open, err := store.ListTickets(ctx, TicketOpen); err != nil || len(open) != 0 {
+				t.Errorf("ListTickets(open) after DeleteUserData = %+v, %v, want none", open, err)
+			}
+		})
+	}
+}
+
+func TestBoltStore_OpenTicketsMigration(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	store, err := NewBoltStore(path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewBoltStore() error = %v", err)
+	}
+	for _, ticket := range []*Ticket{
+		{ChatID: 10, Status: TicketClosed},
+		{ChatID: 10, Status: TicketOpen},
+		{ChatID: 20, Status: TicketClosed},
+	} {
+		if err := store.SaveTicket(ctx, ticket); err != nil {
+			t.Fatalf("SaveTicket() error = %v", err)
+		}
+	}
+	// Tickets saved before version 7 have no index
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketOpenTickets); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketMeta).Put(schemaVersionKey, []byte("6"))
+	})
+	if err != nil {
+		t.Fatalf("downgrade error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewBoltStore(path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("reopen NewBoltStore() error = %v", err)
+	}
+	defer store.Close()
+	if ticket, err := store.OpenTicket(ctx, 10); err != nil || ticket.ID != 2 {
+		t.Errorf("OpenTicket() after migration = %+v, %v, want ticket 2", ticket, err)
+	}
+	if _, err := store.OpenTicket(ctx, 20); err != ErrNotFound {
+		t.Errorf("OpenTicket() of a chat without open tickets error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Reminders(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			later := &Reminder{ChatID: 10, UserID: 10, Text: "later", DueAt: now.Add(time.Hour), CreatedAt: now}
+			due := &Reminder{ChatID: 10, UserID: 10, Text: "due", DueAt: now.Add(-time.Minute), CreatedAt: now}
+			other := &Reminder{ChatID: -100, UserID: 20, Text: "other", DueAt: now, CreatedAt: now}
+			for _, reminder := range []*Reminder{later, due, other} {
+				if err := store.SaveReminder(ctx, reminder); err != nil {
+					t.Fatalf("SaveReminder() error = %v", err)
+				}
+			}
+			if later.ID == 0 || due.ID <= later.ID {
+				t.Fatalf("reminder IDs = %d, %d, want increasing IDs", later.ID, due.ID)
+			}
+
+			list, err := store.ListReminders(ctx, 10)
+			if err != nil {
+				t.Fatalf("ListReminders() error = %v", err)
+			}
+			if len(list) != 2 || list[0].ID != due.ID || list[1].ID != later.ID {
+				t.Errorf("ListReminders() = %+v, want the user's reminders by due time", list)
+			}
+			dueList, err := store.DueReminders(ctx, now)
+			if err != nil {
+				t.Fatalf("DueReminders() error = %v", err)
+			}
+			if len(dueList) != 2 || dueList[0].ID != due.ID || dueList[1].ID != other.ID {
+				t.Errorf("DueReminders() = %+v, want the due and the other reminder", dueList)
+			}
+
+			due.Attempts = 1
+			if err := store.SaveReminder(ctx, due); err != nil {
+				t.Fatalf("SaveReminder() error = %v", err)
+			}
+			if got, err := store.GetReminder(ctx, due.ID); err != nil || got.Attempts != 1 || got.Text != "due" {
+				t.Errorf("GetReminder() = %+v, %v, want the updated reminder", got, err)
+			}
+
+			if err := store.DeleteReminder(ctx, due.ID); err != nil {
+				t.Fatalf("DeleteReminder() error = %v", err)
+			}
+			if err := store.DeleteReminder(ctx, due.ID); err != ErrNotFound {
+				t.Errorf("DeleteReminder() twice error = %v, want ErrNotFound", err)
+			}
+			if _, err := store.GetReminder(ctx, due.ID); err != ErrNotFound {
+				t.Errorf("GetReminder() after delete error = %v, want ErrNotFound", err)
+			}
+
+			// Pending reminders survive retention but not the user's deletion
+			if _, err := store.PurgeBefore(ctx, now.Add(24*time.Hour)); err != nil {
+				t.Fatalf("PurgeBefore() error = %v", err)
+			}
+			if err := store.DeleteUserData(ctx, 20); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+			if all, _ := store.DueReminders(ctx, now.Add(24*time.Hour)); len(all) != 1 || all[0].ID != later.ID {
+				t.Errorf("DueReminders() = %+v, want only the later reminder", all)
+			}
+		})
+	}
+}
+
+func TestConversation_AnswerIndex(t *testing.T) {
+	conv := &Conversation{Messages: []ConversationMessage{
+		{Role: "user", Content: "q1", UserID: 1},
+		{Role: "assistant", Content: "a1", MessageID: 10},
+		{Role: "user", Content: "q2", UserID: 2},
+		{Role: "assistant", Content: "a2", MessageID: 20},
+		{Role: "assistant", Content: "a2, continued", MessageID: 21},
+	}}
+
+	tests := []struct {
+		name      string
+		messageID int
+		answer    int
+		question  int
+	}{
+		{name: "First answer", messageID: 10, answer: 1, question: 0},
+		{name: "Later answer", messageID: 20, answer: 3, question: 2},
+		{name: "Continuation", messageID: 21, answer: 4, question: 2},
+		{name: "Unknown message", messageID: 99, answer: -1, question: -1},
+		{name: "Answer without message", messageID: 0, answer: -1, question: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := conv.AnswerIndex(tt.messageID)
+			if answer != tt.answer {
+				t.Fatalf("AnswerIndex(%d) = %d, want %d", tt.messageID, answer, tt.answer)
+			}
+			if got := conv.QuestionIndex(answer); got != tt.question {
+				t.Errorf("QuestionIndex(%d) = %d, want %d", answer, got, tt.question)
+			}
+		})
+	}
+}
+
+func TestStore_Schedules(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			daily := &Schedule{ChatID: -100, Spec: "0 9 * * *", Prompt: "Word of the day", Persona: "english-teacher", CreatedBy: 1, CreatedAt: now}
+			hourly := &Schedule{ChatID: 10, Spec: "@hourly", Prompt: "News", CreatedBy: 1, CreatedAt: now}
+			for _, schedule := range []*Schedule{daily, hourly} {
+				if err := store.SaveSchedule(ctx, schedule); err != nil {
+					t.Fatalf("SaveSchedule() error = %v", err)
+				}
+			}
+			if daily.ID == 0 || hourly.ID <= daily.ID {
+				t.Fatalf("schedule IDs = %d, %d, want increasing IDs", daily.ID, hourly.ID)
+			}
+
+			list, err := store.ListSchedules(ctx)
+			if err != nil {
+				t.Fatalf("ListSchedules() error = %v", err)
+			}
+			if len(list) != 2 || list[0] != *daily || list[1] != *hourly {
+				t.Errorf("ListSchedules() = %+v, want both schedules by ID", list)
+			}
+
+			if err := store.DeleteSchedule(ctx, daily.ID); err != nil {
+				t.Fatalf("DeleteSchedule() error = %v", err)
+			}
+			if err := store.DeleteSchedule(ctx, daily.ID); err != ErrNotFound {
+				t.Errorf("DeleteSchedule() twice error = %v, want ErrNotFound", err)
+			}
+
+			// Schedules belong to chats, not users, and are kept until removed
+			if _, err := store.PurgeBefore(ctx, now.Add(24*time.Hour)); err != nil {
+				t.Fatalf("PurgeBefore() error = %v", err)
+			}
+			if err := store.DeleteUserData(ctx, 1); err != nil {
+				t.Fatalf("DeleteUserData() error = %v", err)
+			}
+			if list, _ := store.ListSchedules(ctx); len(list) != 1 || list[0].ID != hourly.ID {
+				t.Errorf("ListSchedules() = %+v, want only the hourly schedule", list)
+			}
+		})
+	}
+}
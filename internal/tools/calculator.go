@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits of calculator expressions
+const (
+	maxExpressionLength = 256
+	maxExpressionDepth  = 32
+)
+
+// calculatorFunctions are the functions expressions may call, by number of arguments
+var calculatorFunctions = map[string]struct {
+	args int
+	fn   func(args []float64) float64
+}{
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"log2":  {1, func(a []float64) float64 { return math.Log2(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+// calculatorConstants are the named values expressions may use
+var calculatorConstants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+// Calculator evaluates arithmetic expressions, so the model does not have to
+// do arithmetic itself. Expressions are parsed, never executed as code
+type Calculator struct{}
+
+// NewCalculator creates the calculator tool
+func NewCalculator() *Calculator {
+	return &Calculator{}
+}
+
+// Name implements ai.Tool
+func (c *Calculator) Name() string {
+	return "calculator"
+}
+
+// Description implements ai.Tool
+func (c *Calculator) Description() string {
+	return "Evaluates an arithmetic expression exactly. Use it for any calculation instead of computing in your head. " +
+		"Supports + - * / % ^, parentheses, the constants pi and e, and the functions " +
+		"sqrt, abs, round, floor, ceil, sin, cos, tan, asin, acos, atan, ln, log (base 10), log2, exp, pow, min and max."
+}
+
+// Parameters implements ai.Tool
+func (c *Calculator) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "expression": {"type": "string", "description": "Expression to evaluate, e.g. (12.5 * 4) / 3 or sqrt(2) ^ 2"}
+  },
+  "required": ["expression"]
+}`)
+}
+
+// Call implements ai.Tool
+func (c *Calculator) Call(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	value, err := Evaluate(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(value, 'g', 15, 64), nil
+}
+
+// Evaluate computes the value of an arithmetic expression
+func Evaluate(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+	p := &parser{input: []rune(strings.ReplaceAll(expression, "**", "^"))}
+	value, err := p.expression(0)
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+// parser is a recursive descent parser evaluating expressions as it reads them
+type parser struct {
+	input []rune
+	pos   int
+}
+
+// expression parses terms joined by + and -
+func (p *parser) expression(depth int) (float64, error) {
+	if depth > maxExpressionDepth {
+		return 0, fmt.Errorf("expression is nested too deeply")
+	}
+	value, err := p.term(depth)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			right, err := p.term(depth)
+			if err != nil {
+				return 0, err
+			}
+			value += right
+		case '-':
+			p.pos++
+			right, err := p.term(depth)
+			if err != nil {
+				return 0, err
+			}
+			value -= right
+		default:
+			return value, nil
+		}
+	}
+}
+
+// term parses factors joined by *, / and %
+func (p *parser) term(depth int) (float64, error) {
+	value, err := p.unary(depth)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return value, nil
+		}
+		p.pos++
+		right, err := p.unary(depth)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case op == '*':
+			value *= right
+		case right == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == '/':
+			value /= right
+		default:
+			value = math.Mod(value, right)
+		}
+	}
+}
+
+// unary parses a signed power; -2^2 is -(2^2)
+func (p *parser) unary(depth int) (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.unary(depth + 1)
+		return -value, err
+	case '+':
+		p.pos++
+		return p.unary(depth + 1)
+	}
+	return p.power(depth)
+}
+
+// power parses a primary raised to a right-associative exponent
+func (p *parser) power(depth int) (float64, error) {
+	base, err := p.primary(depth)
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.unary(depth + 1)
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+// primary parses a number, constant, function call or parenthesized expression
+func (p *parser) primary(depth int) (float64, error) {
+	r := p.peek()
+	switch {
+	case r == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	case r == '(':
+		p.pos++
+		value, err := p.expression(depth + 1)
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return value, nil
+	case unicode.IsDigit(r) || r == '.':
+		return p.number()
+	case unicode.IsLetter(r):
+		return p.identifier(depth)
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", r, p.pos+1)
+}
+
+// number parses a decimal number with an optional exponent, e.g. 1.5e3
+func (p *parser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		next := p.pos + 1
+		if next < len(p.input) && (p.input[next] == '+' || p.input[next] == '-') {
+			next++
+		}
+		if next < len(p.input) && unicode.IsDigit(p.input[next]) {
+			p.pos = next
+			for p.pos < len(p.input) && unicode.IsDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+	text := string(p.input[start:p.pos])
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return value, nil
+}
+
+// identifier parses a constant or a function call
+func (p *parser) identifier(depth int) (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(p.input[p.pos]) || unicode.IsDigit(p.input[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(string(p.input[start:p.pos]))
+
+	if p.peek() != '(' {
+		if value, ok := calculatorConstants[name]; ok {
+			return value, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	function, ok := calculatorFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+	p.pos++
+
+	var args []float64
+	for {
+		value, err := p.expression(depth + 1)
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, value)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if p.peek() != ')' {
+		return 0, fmt.Errorf("missing closing parenthesis after arguments of %s", name)
+	}
+	p.pos++
+	if len(args) != function.args {
+		return 0, fmt.Errorf("%s takes %d argument(s), got %d", name, function.args, len(args))
+	}
+	return function.fn(args), nil
+}
+
+// peek skips spaces and returns the next character, or 0 at the end
+func (p *parser) peek() rune {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// skipSpace moves past whitespace
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
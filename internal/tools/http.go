@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Limits of pages fetched by the HTTP tool
+const (
+	httpTimeout      = 10 * time.Second
+	maxResponseBytes = 512 << 10
+	maxResultLength  = 8000
+	maxRedirects     = 5
+)
+
+// Patterns stripping HTML down to its text
+var (
+	htmlHidden     = regexp.MustCompile(`(?is)<(script|style|noscript|svg|head)\b.*?</(script|style|noscript|svg|head)>`)
+	htmlBlock      = regexp.MustCompile(`(?i)</?(p|div|br|li|ul|ol|h[1-6]|tr|table|section|article|header|footer|nav|main)\b[^>]*>`)
+	htmlTag        = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlBlankLines = regexp.MustCompile(`\n\s*\n+`)
+	htmlSpaces     = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// HTTPGet fetches pages from an allowlist of domains, e.g. the company's
+// website or status page
+type HTTPGet struct {
+	client  *http.Client
+	domains []string
+}
+
+// NewHTTPGet creates the HTTP tool for the domains and their subdomains
+func NewHTTPGet(domains []string) *HTTPGet {
+	t := &HTTPGet{}
+	for _, domain := range domains {
+		if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+			t.domains = append(t.domains, domain)
+		}
+	}
+	t.client = &http.Client{
+		Timeout:   httpTimeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return t.check(req.URL)
+		},
+	}
+	return t
+}
+
+// Name implements ai.Tool
+func (t *HTTPGet) Name() string {
+	return "http_get"
+}
+
+// Description implements ai.Tool
+func (t *HTTPGet) Description() string {
+	return "Fetches a web page or API response with HTTP GET and returns its text. Only these domains and their subdomains can be fetched: " +
+		strings.Join(t.domains, ", ") + "."
+}
+
+// Parameters implements ai.Tool
+func (t *HTTPGet) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "url": {"type": "string", "description": "Absolute http or https URL on an allowed domain"}
+  },
+  "required": ["url"]
+}`)
+}
+
+// Call implements ai.Tool
+func (t *HTTPGet) Call(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		URL string `json:"url"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	target, err := url.Parse(args.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := t.check(target); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html, text/plain, application/json;q=0.9, */*;q=0.5")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(body) == maxResponseBytes {
+		body = trimPartialRune(body)
+	}
+	if !utf8.Valid(body) {
+		return "", fmt.Errorf("response of %s is not text", target)
+	}
+	text := string(body)
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		text = htmlText(text)
+	}
+	if runes := []rune(text); len(runes) > maxResultLength {
+		text = string(runes[:maxResultLength]) + "\n[truncated]"
+	}
+	return fmt.Sprintf("HTTP %d\n\n%s", resp.StatusCode, text), nil
+}
+
+// trimPartialRune drops the start of a multi-byte character cut off at the
+// end of body
+func trimPartialRune(body []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(body); i++ {
+		if utf8.RuneStart(body[len(body)-i]) {
+			if !utf8.FullRune(body[len(body)-i:]) {
+				return body[:len(body)-i]
+			}
+			break
+		}
+	}
+	return body
+}
+
+// check rejects URLs that are not http(s) or outside the allowed domains
+func (t *HTTPGet) check(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs can be fetched")
+	}
+	host := strings.TrimSuffix(strings.ToLower(target.Hostname()), ".")
+	for _, domain := range t.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %q is not allowed, allowed domains: %s", host, strings.Join(t.domains, ", "))
+}
+
+// htmlText reduces an HTML page to its visible text
+func htmlText(page string) string {
+	text := htmlHidden.ReplaceAllString(page, " ")
+	text = htmlBlock.ReplaceAllString(text, "\n")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = htmlSpaces.ReplaceAllString(text, " ")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(htmlBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n"))
+}
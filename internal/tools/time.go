@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Time tells the model the current date and time in a timezone
+type Time struct {
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewTime creates the time tool; location is used when the model names no timezone
+func NewTime(location *time.Location) *Time {
+	return &Time{location: location, now: time.Now}
+}
+
+// Name implements ai.Tool
+func (t *Time) Name() string {
+	return "current_time"
+}
+
+// Description implements ai.Tool
+func (t *Time) Description() string {
+	return fmt.Sprintf("Returns the current date, time and weekday. Use it whenever the answer depends on today's date or the time. "+
+		"The default timezone is %s.", t.location)
+}
+
+// Parameters implements ai.Tool
+func (t *Time) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "timezone": {"type": "string", "description": "IANA timezone, e.g. Europe/Berlin or America/New_York; omit for the default timezone"}
+  }
+}`)
+}
+
+// timeResult is the answer of the time tool
+type timeResult struct {
+	DateTime  string `json:"datetime"`
+	Date      string `json:"date"`
+	Time      string `json:"time"`
+	Weekday   string `json:"weekday"`
+	Timezone  string `json:"timezone"`
+	UTCOffset string `json:"utc_offset"`
+	ISOWeek   int    `json:"iso_week"`
+}
+
+// Call implements ai.Tool
+func (t *Time) Call(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	location := t.location
+	if args.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(args.Timezone); err != nil {
+			return "", fmt.Errorf("unknown timezone %q", args.Timezone)
+		}
+	}
+
+	now := t.now().In(location)
+	_, week := now.ISOWeek()
+	return encodeResult(timeResult{
+		DateTime:  now.Format(time.RFC3339),
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("15:04:05"),
+		Weekday:   now.Weekday().String(),
+		Timezone:  location.String(),
+		UTCOffset: now.Format("-07:00"),
+		ISOWeek:   week,
+	})
+}
@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// decodeArguments unmarshals the arguments chosen by the model into v
+func decodeArguments(arguments json.RawMessage, v any) error {
+	if err := json.Unmarshal(arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// encodeResult returns v as JSON for the model
+func encodeResult(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result: %w", err)
+	}
+	return string(data), nil
+}
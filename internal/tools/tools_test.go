@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   float64
+		wantErr    bool
+	}{
+		{expression: "1 + 2 * 3", expected: 7},
+		{expression: "(1 + 2) * 3", expected: 9},
+		{expression: "10 / 4", expected: 2.5},
+		{expression: "10 % 4", expected: 2},
+		{expression: "2 ^ 3 ^ 2", expected: 512},
+		{expression: "2 ** 10", expected: 1024},
+		{expression: "-2 ^ 2", expected: -4},
+		{expression: "--3", expected: 3},
+		{expression: "1.5e3 + .5", expected: 1500.5},
+		{expression: "sqrt(16) + abs(-2)", expected: 6},
+		{expression: "max(3, min(10, 7))", expected: 7},
+		{expression: "round(2 * pi)", expected: 6},
+		{expression: "ln(e)", expected: 1},
+		{expression: "", wantErr: true},
+		{expression: "1 / 0", wantErr: true},
+		{expression: "(1 + 2", wantErr: true},
+		{expression: "1 + ", wantErr: true},
+		{expression: "2 3", wantErr: true},
+		{expression: "os.exit(1)", wantErr: true},
+		{expression: "sqrt(1, 2)", wantErr: true},
+		{expression: "sqrt(-1)", wantErr: true},
+		{expression: "10 ^ 400", wantErr: true},
+		{expression: strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40), wantErr: true},
+		{expression: strings.Repeat("1+", 200) + "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := Evaluate(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		from, to string
+		expected float64
+		wantErr  bool
+	}{
+		{name: "Miles to kilometers", value: 10, from: "mi", to: "km", expected: 16.09344},
+		{name: "Names and case", value: 2, from: "Pounds", to: "KG", expected: 0.90718474},
+		{name: "Gallons to liters", value: 1, from: "gallon", to: "l", expected: 3.785411784},
+		{name: "Speed", value: 36, from: "km/h", to: "m/s", expected: 10},
+		{name: "Binary data", value: 1, from: "GiB", to: "MB", expected: 1073.741824},
+		{name: "Fahrenheit to Celsius", value: 212, from: "°F", to: "C", expected: 100},
+		{name: "Celsius to Kelvin", value: -273.15, from: "celsius", to: "kelvin", expected: 0},
+		{name: "Different dimensions", value: 1, from: "kg", to: "m", wantErr: true},
+		{name: "Temperature and length", value: 1, from: "c", to: "m", wantErr: true},
+		{name: "Unknown unit", value: 1, from: "furlong", to: "m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertUnits(tt.value, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConvertUnits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("ConvertUnits() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnitConverter_Call(t *testing.T) {
+	got, err := NewUnitConverter().Call(context.Background(), json.RawMessage(`{"value": 1, "from": "mi", "to": "km"}`))
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if got != "1 mi = 1.609344 km" {
+		t.Errorf("Call() = %q, want %q", got, "1 mi = 1.609344 km")
+	}
+}
+
+func TestTime_Call(t *testing.T) {
+	tool := NewTime(time.UTC)
+	tool.now = func() time.Time { return time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name      string
+		arguments string
+		expected  timeResult
+		wantErr   bool
+	}{
+		{
+			name:      "Default timezone",
+			arguments: `{}`,
+			expected:  timeResult{DateTime: "2026-10-18T22:30:00Z", Date: "2026-10-18", Time: "22:30:00", Weekday: "Sunday", Timezone: "UTC", UTCOffset: "+00:00", ISOWeek: 42},
+		},
+		{
+			name:      "Other timezone on the next day",
+			arguments: `{"timezone": "Asia/Tokyo"}`,
+			expected:  timeResult{DateTime: "2026-10-19T07:30:00+09:00", Date: "2026-10-19", Time: "07:30:00", Weekday: "Monday", Timezone: "Asia/Tokyo", UTCOffset: "+09:00", ISOWeek: 43},
+		},
+		{name: "Unknown timezone", arguments: `{"timezone": "Mars/Olympus"}`, wantErr: true},
+		{name: "Invalid arguments", arguments: `[]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tool.Call(context.Background(), json.RawMessage(tt.arguments))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var result timeResult
+			if err := json.Unmarshal([]byte(got), &result); err != nil {
+				t.Fatalf("Call() = %q is not JSON: %v", got, err)
+			}
+			if result != tt.expected {
+				t.Errorf("Call() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTTPGet_Call(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte(`<html><head><title>x</title><style>p{}</style></head><body><h1>Status</h1><p>All systems <b>operational</b> &amp; fast.</p><script>alert(1)</script></body></html>`))
+		case "/long":
+			// The byte limit falls inside a two-byte character
+			_, _ = w.Write([]byte("a" + strings.Repeat("é", maxResponseBytes)))
+		case "/binary":
+			_, _ = w.Write([]byte{0xff, 0xfe, 0x00, 0x01})
+		case "/away":
+			http.Redirect(w, r, "http://example.org/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	tool := NewHTTPGet([]string{"127.0.0.1", " Example.COM. "})
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "HTML page", url: server.URL + "/page", expected: "HTTP 200\n\nStatus\nAll systems operational & fast."},
+		{name: "Error status", url: server.URL + "/missing", expected: "HTTP 404\n\n404 page not found\n"},
+		{name: "Body cut inside a character", url: server.URL + "/long", expected: "HTTP 200\n\na" + strings.Repeat("é", maxResultLength-1) + "\n[truncated]"},
+		{name: "Binary body", url: server.URL + "/binary", wantErr: true},
+		{name: "Domain not allowed", url: "https://evil.example.net/", wantErr: true},
+		{name: "Allowed domain as user info", url: "https://example.com@evil.example.net/", wantErr: true},
+		{name: "Suffix that is not a subdomain", url: "https://notexample.com/", wantErr: true},
+		{name: "Scheme not allowed", url: "file:///etc/passwd", wantErr: true},
+		{name: "Redirect to another domain", url: server.URL + "/away", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arguments, _ := json.Marshal(map[string]string{"url": tt.url})
+			got, err := tool.Call(context.Background(), arguments)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Call() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if err := tool.check(mustParseURL(t, "https://docs.example.com/a")); err != nil {
+		t.Errorf("check() of a subdomain error = %v", err)
+	}
+}
+
+// mustParseURL parses a URL or fails the test
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
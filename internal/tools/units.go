@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unit is a unit of measurement: value * factor is the value in the base unit
+// of its dimension
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+// units maps unit names and symbols, lowercased, to units
+var units = map[string]unit{}
+
+// Temperatures are not proportional and are converted separately
+var temperatureUnits = map[string]string{
+	"c": "c", "°c": "c", "celsius": "c",
+	"f": "f", "°f": "f", "fahrenheit": "f",
+	"k": "k", "kelvin": "k",
+}
+
+func init() {
+	define := func(dimension string, factor float64, names ...string) {
+		for _, name := range names {
+			units[name] = unit{dimension: dimension, factor: factor}
+		}
+	}
+
+	// Length, in meters
+	define("length", 1, "m", "meter", "meters", "metre", "metres")
+	define("length", 1000, "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	define("length", 0.01, "cm", "centimeter", "centimeters", "centimetre", "centimetres")
+	define("length", 0.001, "mm", "millimeter", "millimeters", "millimetre", "millimetres")
+	define("length", 1609.344, "mi", "mile", "miles")
+	define("length", 0.9144, "yd", "yard", "yards")
+	define("length", 0.3048, "ft", "foot", "feet")
+	define("length", 0.0254, "in", "inch", "inches")
+	define("length", 1852, "nmi", "nautical mile", "nautical miles")
+
+	// Mass, in kilograms
+	define("mass", 1, "kg", "kilogram", "kilograms")
+	define("mass", 0.001, "g", "gram", "grams")
+	define("mass", 1e-6, "mg", "milligram", "milligrams")
+	define("mass", 1000, "t", "tonne", "tonnes", "metric ton", "metric tons")
+	define("mass", 0.45359237, "lb", "lbs", "pound", "pounds")
+	define("mass", 0.028349523125, "oz", "ounce", "ounces")
+	define("mass", 6.35029318, "st", "stone", "stones")
+
+	// Volume, in liters
+	define("volume", 1, "l", "liter", "liters", "litre", "litres")
+	define("volume", 0.001, "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	define("volume", 1000, "m3", "m³", "cubic meter", "cubic meters")
+	define("volume", 3.785411784, "gal", "gallon", "gallons")
+	define("volume", 0.946352946, "qt", "quart", "quarts")
+	define("volume", 0.473176473, "pt", "pint", "pints")
+	define("volume", 0.2365882365, "cup", "cups")
+	define("volume", 0.0295735295625, "fl oz", "floz", "fluid ounce", "fluid ounces")
+
+	// Area, in square meters
+	define("area", 1, "m2", "m²", "square meter", "square meters")
+	define("area", 1e6, "km2", "km²", "square kilometer", "square kilometers")
+	define("area", 0.09290304, "ft2", "ft²", "square foot", "square feet")
+	define("area", 10000, "ha", "hectare", "hectares")
+	define("area", 4046.8564224, "acre", "acres")
+
+	// Speed, in meters per second
+	define("speed", 1, "m/s", "meters per second")
+	define("speed", 1/3.6, "km/h", "kmh", "kph", "kilometers per hour")
+	define("speed", 0.44704, "mph", "miles per hour")
+	define("speed", 1852/3600.0, "kn", "knot", "knots")
+
+	// Time, in seconds
+	define("time", 1, "s", "sec", "second", "seconds")
+	define("time", 60, "min", "minute", "minutes")
+	define("time", 3600, "h", "hr", "hour", "hours")
+	define("time", 86400, "d", "day", "days")
+	define("time", 604800, "wk", "week", "weeks")
+
+	// Data, in bytes
+	define("data", 1, "b", "byte", "bytes")
+	define("data", 1e3, "kb", "kilobyte", "kilobytes")
+	define("data", 1e6, "mb", "megabyte", "megabytes")
+	define("data", 1e9, "gb", "gigabyte", "gigabytes")
+	define("data", 1e12, "tb", "terabyte", "terabytes")
+	define("data", 1<<10, "kib", "kibibyte", "kibibytes")
+	define("data", 1<<20, "mib", "mebibyte", "mebibytes")
+	define("data", 1<<30, "gib", "gibibyte", "gibibytes")
+	define("data", 1<<40, "tib", "tebibyte", "tebibytes")
+}
+
+// UnitConverter converts values between units of length, mass, volume, area,
+// speed, time, data and temperature
+type UnitConverter struct{}
+
+// NewUnitConverter creates the unit converter tool
+func NewUnitConverter() *UnitConverter {
+	return &UnitConverter{}
+}
+
+// Name implements ai.Tool
+func (u *UnitConverter) Name() string {
+	return "convert_units"
+}
+
+// Description implements ai.Tool
+func (u *UnitConverter) Description() string {
+	return "Converts a value between units of length, mass, volume, area, speed, time, data size and temperature, " +
+		"e.g. miles to km, lb to kg, gallons to liters, °F to °C or GiB to GB. US customary units are used for gallons, quarts, pints and cups."
+}
+
+// Parameters implements ai.Tool
+func (u *UnitConverter) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "value": {"type": "number", "description": "Value to convert"},
+    "from": {"type": "string", "description": "Unit of the value, e.g. mi, kg, °F, gal"},
+    "to": {"type": "string", "description": "Unit to convert to, e.g. km, lb, °C, l"}
+  },
+  "required": ["value", "from", "to"]
+}`)
+}
+
+// Call implements ai.Tool
+func (u *UnitConverter) Call(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Value float64 `json:"value"`
+		From  string  `json:"from"`
+		To    string  `json:"to"`
+	}
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	value, err := ConvertUnits(args.Value, args.From, args.To)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s = %s %s", formatNumber(args.Value), args.From, formatNumber(value), args.To), nil
+}
+
+// ConvertUnits converts value from one unit to another of the same dimension
+func ConvertUnits(value float64, from, to string) (float64, error) {
+	from, to = normalizeUnit(from), normalizeUnit(to)
+
+	fromTemp, fromIsTemp := temperatureUnits[from]
+	toTemp, toIsTemp := temperatureUnits[to]
+	if fromIsTemp && toIsTemp {
+		return fromKelvin(toKelvin(value, fromTemp), toTemp), nil
+	}
+
+	source, ok := units[from]
+	if !ok && !fromIsTemp {
+		return 0, fmt.Errorf("unknown unit %q, known units: %s", from, knownUnits())
+	}
+	target, ok := units[to]
+	if !ok && !toIsTemp {
+		return 0, fmt.Errorf("unknown unit %q, known units: %s", to, knownUnits())
+	}
+	if fromIsTemp || toIsTemp || source.dimension != target.dimension {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return value * source.factor / target.factor, nil
+}
+
+// normalizeUnit lowercases a unit and collapses its spaces
+func normalizeUnit(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// toKelvin converts a temperature in unit c, f or k to kelvins
+func toKelvin(value float64, unit string) float64 {
+	switch unit {
+	case "c":
+		return value + 273.15
+	case "f":
+		return (value-32)*5/9 + 273.15
+	}
+	return value
+}
+
+// fromKelvin converts kelvins to a temperature in unit c, f or k
+func fromKelvin(kelvin float64, unit string) float64 {
+	switch unit {
+	case "c":
+		return kelvin - 273.15
+	case "f":
+		return (kelvin-273.15)*9/5 + 32
+	}
+	return kelvin
+}
+
+// knownUnits lists the short unit names, for error messages the model can act on
+func knownUnits() string {
+	var names []string
+	for name := range units {
+		if len(name) <= 4 {
+			names = append(names, name)
+		}
+	}
+	names = append(names, "c", "f", "k")
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// formatNumber prints a value without float noise, e.g. 1.60934 instead of 1.6093440000000001
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1e9)/1e9, 'g', 12, 64)
+}
@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DayFormat is the layout of the days usage is aggregated by
+const DayFormat = "2006-01-02"
+
+// Period is an inclusive range of days
+type Period struct {
+	From string
+	To   string
+}
+
+// String returns a human-readable description of the period
+func (p Period) String() string {
+	if p.From == p.To {
+		return p.From
+	}
+	return p.From + " – " + p.To
+}
+
+// CurrentMonth returns the period from the first day of now's month until now
+func CurrentMonth(now time.Time) Period {
+	now = now.UTC()
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Period{From: first.Format(DayFormat), To: now.Format(DayFormat)}
+}
+
+// ParsePeriod parses a period argument relative to now (UTC). Supported forms:
+// today, yesterday, week (last 7 days), month (current month), YYYY-MM,
+// YYYY-MM-DD and FROM..TO with days
+func ParsePeriod(arg string, now time.Time) (Period, error) {
+	now = now.UTC()
+	today := now.Format(DayFormat)
+
+	switch strings.ToLower(arg) {
+	case "today":
+		return Period{From: today, To: today}, nil
+	case "yesterday":
+		day := now.AddDate(0, 0, -1).Format(DayFormat)
+		return Period{From: day, To: day}, nil
+	case "week":
+		return Period{From: now.AddDate(0, 0, -6).Format(DayFormat), To: today}, nil
+	case "month":
+		return CurrentMonth(now), nil
+	}
+
+	if from, to, ok := strings.Cut(arg, ".."); ok {
+		if !validDay(from) || !validDay(to) || from > to {
+			return Period{}, fmt.Errorf("invalid period %q", arg)
+		}
+		return Period{From: from, To: to}, nil
+	}
+
+	if validDay(arg) {
+		return Period{From: arg, To: arg}, nil
+	}
+
+	if month, err := time.Parse("2006-01", arg); err == nil {
+		last := month.AddDate(0, 1, -1)
+		return Period{From: month.Format(DayFormat), To: last.Format(DayFormat)}, nil
+	}
+
+	return Period{}, fmt.Errorf("invalid period %q", arg)
+}
+
+// validDay reports whether s is a day in DayFormat
+func validDay(s string) bool {
+	_, err := time.Parse(DayFormat, s)
+	return err == nil
+}
@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Price is the cost of one million prompt (input) and completion (output) tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// PriceTable maps model names to their prices
+type PriceTable map[string]Price
+
+// ParsePriceTable parses a price list such as "gpt-4o=2.5/10,gpt-4o-mini=0.15/0.6",
+// where each model is followed by its input and output price per million tokens
+func ParsePriceTable(spec string) (PriceTable, error) {
+	table := make(PriceTable)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, prices, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid price entry %q: expected model=input/output", entry)
+		}
+		input, output, ok := strings.Cut(prices, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid price entry %q: expected model=input/output", entry)
+		}
+
+		price, err := parsePrice(input, output)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price for %s: %w", model, err)
+		}
+		table[strings.ToLower(model)] = price
+	}
+	return table, nil
+}
+
+// Cost returns the price of a request, or zero if the model has no price
+func (t PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := t[strings.ToLower(model)]
+	if !ok {
+		return 0
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1_000_000
+}
+
+// Has reports whether the table contains a price for the model
+func (t PriceTable) Has(model string) bool {
+	_, ok := t[strings.ToLower(model)]
+	return ok
+}
+
+// parsePrice parses the input and output prices of a model
+func parsePrice(input, output string) (Price, error) {
+	in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+	if err != nil || in < 0 {
+		return Price{}, fmt.Errorf("invalid input price %q", input)
+	}
+	out, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil || out < 0 {
+		return Price{}, fmt.Errorf("invalid output price %q", output)
+	}
+	return Price{Input: in, Output: out}, nil
+}
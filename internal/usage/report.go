@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"tgbot-skeleton/internal/storage"
+)
+
+// Totals sums the usage of several aggregates
+type Totals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// Add accumulates a usage aggregate
+func (t *Totals) Add(record storage.UsageRecord) {
+	t.Requests += record.Requests
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.Cost += record.Cost
+}
+
+// Group is the usage total of one value of a breakdown, e.g. one model
+type Group struct {
+	Key string
+	Totals
+}
+
+// Sum returns the totals of all records
+func Sum(records []storage.UsageRecord) Totals {
+	var totals Totals
+	for _, record := range records {
+		totals.Add(record)
+	}
+	return totals
+}
+
+// GroupBy sums records by the key returned for each of them, most expensive first
+func GroupBy(records []storage.UsageRecord, key func(storage.UsageRecord) string) []Group {
+	index := make(map[string]int)
+	var groups []Group
+	for _, record := range records {
+		k := key(record)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, Group{Key: k})
+		}
+		groups[i].Add(record)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Cost != groups[j].Cost {
+			return groups[i].Cost > groups[j].Cost
+		}
+		return groups[i].Requests > groups[j].Requests
+	})
+	return groups
+}
+
+// FilterUser returns the records of a single user
+func FilterUser(records []storage.UsageRecord, userID int64) []storage.UsageRecord {
+	var filtered []storage.UsageRecord
+	for _, record := range records {
+		if record.UserID == userID {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// csvHeader lists the columns written by WriteCSV
+var csvHeader = []string{"day", "user_id", "chat_id", "model", "requests", "prompt_tokens", "completion_tokens", "cost"}
+
+// WriteCSV writes one row per usage aggregate
+func WriteCSV(w io.Writer, records []storage.UsageRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, record := range records {
+		row := []string{
+			record.Day,
+			strconv.FormatInt(record.UserID, 10),
+			strconv.FormatInt(record.ChatID, 10),
+			record.Model,
+			strconv.Itoa(record.Requests),
+			strconv.Itoa(record.PromptTokens),
+			strconv.Itoa(record.CompletionTokens),
+			strconv.FormatFloat(record.Cost, 'f', 6, 64),
+		}
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"tgbot-skeleton/internal/storage"
+)
+
+func TestParsePriceTable(t *testing.T) {
+	table, err := ParsePriceTable("gpt-4o=2.5/10, GPT-4o-mini = 0.15/0.6,")
+	if err != nil {
+		t.Fatalf("ParsePriceTable() error = %v", err)
+	}
+
+	tests := []struct {
+		model      string
+		prompt     int
+		completion int
+		expected   float64
+	}{
+		{model: "gpt-4o", prompt: 1_000_000, completion: 0, expected: 2.5},
+		{model: "gpt-4o", prompt: 1000, completion: 500, expected: 0.0075},
+		{model: "gpt-4o-mini", prompt: 2_000_000, completion: 1_000_000, expected: 0.9},
+		{model: "unknown", prompt: 1000, completion: 1000, expected: 0},
+	}
+	for _, tt := range tests {
+		if got := table.Cost(tt.model, tt.prompt, tt.completion); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("Cost(%q, %d, %d) = %v, want %v", tt.model, tt.prompt, tt.completion, got, tt.expected)
+		}
+	}
+
+	for _, spec := range []string{"gpt-4o", "gpt-4o=2.5", "=1/2", "gpt-4o=a/1", "gpt-4o=1/-2"} {
+		if _, err := ParsePriceTable(spec); err == nil {
+			t.Errorf("ParsePriceTable(%q) error = nil, want error", spec)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		arg      string
+		expected Period
+		wantErr  bool
+	}{
+		{arg: "today", expected: Period{From: "2024-05-15", To: "2024-05-15"}},
+		{arg: "yesterday", expected: Period{From: "2024-05-14", To: "2024-05-14"}},
+		{arg: "week", expected: Period{From: "2024-05-09", To: "2024-05-15"}},
+		{arg: "month", expected: Period{From: "2024-05-01", To: "2024-05-15"}},
+		{arg: "2024-02", expected: Period{From: "2024-02-01", To: "2024-02-29"}},
+		{arg: "2024-04-30", expected: Period{From: "2024-04-30", To: "2024-04-30"}},
+		{arg: "2024-04-01..2024-04-10", expected: Period{From: "2024-04-01", To: "2024-04-10"}},
+		{arg: "2024-04-10..2024-04-01", wantErr: true},
+		{arg: "last-year", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := ParsePeriod(tt.arg, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParsePeriod() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGroupByAndCSV(t *testing.T) {
+	records := []storage.UsageRecord{
+		{Day: "2024-05-01", UserID: 1, ChatID: 1, Model: "a", Requests: 1, PromptTokens: 10, CompletionTokens: 5, Cost: 0.1},
+		{Day: "2024-05-01", UserID: 2, ChatID: 2, Model: "b", Requests: 3, PromptTokens: 30, CompletionTokens: 15, Cost: 0.5},
+		{Day: "2024-05-02", UserID: 1, ChatID: 1, Model: "b", Requests: 1, PromptTokens: 10, CompletionTokens: 5, Cost: 0.2},
+	}
+
+	groups := GroupBy(records, func(r storage.UsageRecord) string { return r.Model })
+	if len(groups) != 2 || groups[0].Key != "b" || groups[0].Requests != 4 || math.Abs(groups[0].Cost-0.7) > 1e-9 {
+		t.Errorf("GroupBy() = %+v, want model b first with 4 requests and cost 0.7", groups)
+	}
+	if got := FilterUser(records, 1); len(got) != 2 {
+		t.Errorf("FilterUser() = %+v, want 2 records", got)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("WriteCSV() wrote %d lines, want header and 3 rows", len(lines))
+	}
+	if lines[0] != "day,user_id,chat_id,model,requests,prompt_tokens,completion_tokens,cost" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[2] != "2024-05-01,2,2,b,3,30,15,0.500000" {
+		t.Errorf("row = %q", lines[2])
+	}
+}
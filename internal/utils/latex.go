@@ -0,0 +1,518 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// latexSymbols maps LaTeX commands to their Unicode equivalents
+var latexSymbols = map[string]string{
+	// Greek lowercase
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "φ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+
+	// Greek uppercase
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+
+	// Big operators
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭", "oint": "∮",
+
+	// Binary operators and relations
+	"cdot": "·", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗", "circ": "∘",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "wedge": "∧", "land": "∧",
+	"vee": "∨", "lor": "∨", "neg": "¬", "lnot": "¬", "oplus": "⊕", "otimes": "⊗",
+
+	// Arrows
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⇒", "iff": "⇔",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓",
+
+	// Miscellaneous symbols
+	"infty": "∞", "partial": "∂", "nabla": "∇", "forall": "∀", "exists": "∃",
+	"emptyset": "∅", "varnothing": "∅", "angle": "∠", "perp": "⊥", "parallel": "∥",
+	"prime": "′", "degree": "°", "hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+	"ldots": "…", "cdots": "⋯", "dots": "…", "vdots": "⋮", "ddots": "⋱",
+	"therefore": "∴", "because": "∵", "langle": "⟨", "rangle": "⟩",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+
+	// Function names
+	"sin": "sin", "cos": "cos", "tan": "tan", "cot": "cot", "sec": "sec", "csc": "csc",
+	"arcsin": "arcsin", "arccos": "arccos", "arctan": "arctan", "sinh": "sinh", "cosh": "cosh",
+	"tanh": "tanh", "log": "log", "ln": "ln", "lg": "lg", "exp": "exp", "lim": "lim",
+	"max": "max", "min": "min", "sup": "sup", "inf": "inf", "det": "det", "gcd": "gcd",
+	"deg": "deg", "dim": "dim", "mod": "mod", "bmod": "mod",
+
+	// Spacing
+	"quad": " ", "qquad": "  ", ",": " ", ";": " ", ":": " ", "!": "", " ": " ",
+
+	// Escaped characters
+	"{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "#": "#", "_": "_", "|": "‖",
+}
+
+// latexTextCommands are commands whose single argument is rendered as-is
+var latexTextCommands = map[string]bool{
+	"text": true, "textrm": true, "textbf": true, "textit": true, "mathrm": true,
+	"mathbf": true, "mathit": true, "mathsf": true, "operatorname": true, "boldsymbol": true,
+}
+
+// latexBlackboard maps \mathbb letters to double-struck Unicode letters
+var latexBlackboard = map[rune]string{
+	'N': "ℕ", 'Z': "ℤ", 'Q': "ℚ", 'R': "ℝ", 'C': "ℂ", 'P': "ℙ", 'H': "ℍ",
+}
+
+// superscripts maps characters to their Unicode superscript forms
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼', '(': '⁽', ')': '⁾',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ',
+	'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ',
+	't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ',
+	'A': 'ᴬ', 'B': 'ᴮ', 'D': 'ᴰ', 'E': 'ᴱ', 'G': 'ᴳ', 'H': 'ᴴ', 'I': 'ᴵ', 'J': 'ᴶ', 'K': 'ᴷ',
+	'L': 'ᴸ', 'M': 'ᴹ', 'N': 'ᴺ', 'O': 'ᴼ', 'P': 'ᴾ', 'R': 'ᴿ', 'T': 'ᵀ', 'U': 'ᵁ', 'V': 'ⱽ', 'W': 'ᵂ',
+	'∘': '°', '′': '′', '*': '*', 'θ': 'ᶿ', 'β': 'ᵝ', 'γ': 'ᵞ', 'δ': 'ᵟ', 'φ': 'ᵠ', 'χ': 'ᵡ',
+}
+
+// subscripts maps characters to their Unicode subscript forms
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '−': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ',
+	'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+	'β': 'ᵦ', 'γ': 'ᵧ', 'ρ': 'ᵨ', 'φ': 'ᵩ', 'χ': 'ᵪ',
+}
+
+// ConvertLaTeXToUnicode converts inline ($...$, \(...\)) and display ($$...$$, \[...\])
+// LaTeX math into readable Unicode. Expressions that cannot be converted are
+// rendered as a code span. Code blocks and inline code are left untouched.
+func ConvertLaTeXToUnicode(text string) string {
+	var result strings.Builder
+	i := 0
+
+	for i < len(text) {
+		rest := text[i:]
+
+		// Copy code blocks and inline code verbatim
+		if strings.HasPrefix(rest, "```") {
+			end := strings.Index(rest[3:], "```")
+			if end == -1 {
+				result.WriteString(rest)
+				break
+			}
+			result.WriteString(rest[:end+6])
+			i += end + 6
+			continue
+		}
+		if rest[0] == '`' {
+			end := strings.IndexByte(rest[1:], '`')
+			if end == -1 {
+				result.WriteString(rest)
+				break
+			}
+			result.WriteString(rest[:end+2])
+			i += end + 2
+			continue
+		}
+
+		// An escaped dollar sign that would otherwise open math is a literal
+		// dollar; elsewhere the backslash is part of the text, e.g. a regex
+		if strings.HasPrefix(rest, "\\$") {
+			if opensMath(rest[1:]) {
+				result.WriteString("$")
+			} else {
+				result.WriteString("\\$")
+			}
+			i += 2
+			continue
+		}
+
+		// Display math: $$...$$ and \[...\]
+		if expr, n, ok := findDelimited(rest, "$$", "$$"); ok {
+			result.WriteString(renderMath(expr))
+			i += n
+			continue
+		}
+		if expr, n, ok := findDelimited(rest, "\\[", "\\]"); ok {
+			result.WriteString(renderMath(expr))
+			i += n
+			continue
+		}
+
+		// Inline math: \(...\) and $...$
+		if expr, n, ok := findDelimited(rest, "\\(", "\\)"); ok {
+			result.WriteString(renderMath(expr))
+			i += n
+			continue
+		}
+		if expr, n, ok := findInlineDollar(rest); ok {
+			result.WriteString(renderMath(expr))
+			i += n
+			continue
+		}
+
+		result.WriteByte(text[i])
+		i++
+	}
+
+	return result.String()
+}
+
+// opensMath reports whether text starts with dollar math
+func opensMath(text string) bool {
+	if _, _, ok := findDelimited(text, "$$", "$$"); ok {
+		return true
+	}
+	_, _, ok := findInlineDollar(text)
+	return ok
+}
+
+// findDelimited returns the expression between open and close if text starts with open
+func findDelimited(text, open, close string) (string, int, bool) {
+	if !strings.HasPrefix(text, open) {
+		return "", 0, false
+	}
+	end := strings.Index(text[len(open):], close)
+	if end <= 0 {
+		return "", 0, false
+	}
+	expr := text[len(open) : len(open)+end]
+	return expr, len(open) + end + len(close), true
+}
+
+// findInlineDollar finds $...$ math using the Pandoc rules: the opening $ must be
+// followed by a non-space character, and the closing $ must be preceded by a
+// non-space character and not followed by a digit. This keeps prices like
+// "$5 and $10" intact.
+func findInlineDollar(text string) (string, int, bool) {
+	if len(text) < 3 || text[0] != '$' || isSpaceByte(text[1]) || text[1] == '$' {
+		return "", 0, false
+	}
+
+	for j := 2; j < len(text); j++ {
+		switch text[j] {
+		case '\n':
+			return "", 0, false
+		case '$':
+			if text[j-1] == '\\' || isSpaceByte(text[j-1]) {
+				continue
+			}
+			if j+1 < len(text) && text[j+1] >= '0' && text[j+1] <= '9' {
+				continue
+			}
+			return text[1:j], j + 1, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// renderMath converts a single math expression, falling back to a code span
+func renderMath(expr string) string {
+	trimmed := strings.TrimSpace(expr)
+	if converted, ok := convertMath(trimmed); ok {
+		return strings.TrimSpace(converted)
+	}
+	// Telegram Markdown cannot escape a backtick inside a code span, so the
+	// LaTeX opening quote is replaced with its typographic equivalent
+	return "`" + strings.ReplaceAll(trimmed, "`", "‘") + "`"
+}
+
+// convertMath converts a LaTeX math expression to Unicode. It reports false if
+// the expression contains constructs that have no readable Unicode form.
+func convertMath(expr string) (string, bool) {
+	p := &latexParser{input: []rune(expr)}
+	out, ok := p.parse(false)
+	if !ok || p.pos < len(p.input) {
+		return "", false
+	}
+	return out, true
+}
+
+// latexParser is a small recursive descent parser over a math expression
+type latexParser struct {
+	input []rune
+	pos   int
+}
+
+// parse converts tokens until the end of input, or until a closing brace if inGroup is set
+func (p *latexParser) parse(inGroup bool) (string, bool) {
+	var out strings.Builder
+
+	for p.pos < len(p.input) {
+		r := p.input[p.pos]
+
+		switch {
+		case r == '}':
+			if !inGroup {
+				return "", false
+			}
+			return out.String(), true
+		case r == '{':
+			p.pos++
+			group, ok := p.parse(true)
+			if !ok || !p.consume('}') {
+				return "", false
+			}
+			out.WriteString(group)
+		case r == '^' || r == '_':
+			p.pos++
+			arg, ok := p.argument()
+			if !ok {
+				return "", false
+			}
+			table := superscripts
+			if r == '_' {
+				table = subscripts
+			}
+			mapped, ok := mapRunes(arg, table)
+			if !ok {
+				return "", false
+			}
+			out.WriteString(mapped)
+		case r == '\\':
+			converted, ok := p.command()
+			if !ok {
+				return "", false
+			}
+			out.WriteString(converted)
+		case r == '&':
+			return "", false
+		default:
+			out.WriteRune(r)
+			p.pos++
+		}
+	}
+
+	if inGroup {
+		return "", false
+	}
+	return out.String(), true
+}
+
+// argument reads a single command argument: a braced group, a command or one character
+func (p *latexParser) argument() (string, bool) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return "", false
+	}
+
+	switch r := p.input[p.pos]; r {
+	case '{':
+		p.pos++
+		group, ok := p.parse(true)
+		if !ok || !p.consume('}') {
+			return "", false
+		}
+		return group, true
+	case '\\':
+		return p.command()
+	case '}', '^', '_':
+		return "", false
+	default:
+		p.pos++
+		return string(r), true
+	}
+}
+
+// command converts a backslash command starting at the current position
+func (p *latexParser) command() (string, bool) {
+	p.pos++ // skip backslash
+	if p.pos >= len(p.input) {
+		return "", false
+	}
+
+	// Single non-letter commands like \, \{ and \\
+	if !unicode.IsLetter(p.input[p.pos]) {
+		name := string(p.input[p.pos])
+		p.pos++
+		if name == "\\" {
+			return "\n", true
+		}
+		symbol, ok := latexSymbols[name]
+		return symbol, ok
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(p.input[p.pos]) {
+		p.pos++
+	}
+	name := string(p.input[start:p.pos])
+
+	switch {
+	case name == "frac" || name == "dfrac" || name == "tfrac":
+		num, ok := p.argument()
+		if !ok {
+			return "", false
+		}
+		den, ok := p.argument()
+		if !ok {
+			return "", false
+		}
+		return wrapOperand(num) + "⁄" + wrapOperand(den), true
+	case name == "sqrt":
+		return p.sqrt()
+	case name == "left" || name == "right":
+		// The delimiter itself is rendered by the caller; "." means no delimiter
+		p.skipSpaces()
+		if p.pos < len(p.input) && p.input[p.pos] == '.' {
+			p.pos++
+		}
+		return "", true
+	case name == "mathbb":
+		arg, ok := p.argument()
+		if !ok {
+			return "", false
+		}
+		var out strings.Builder
+		for _, r := range arg {
+			letter, ok := latexBlackboard[r]
+			if !ok {
+				return "", false
+			}
+			out.WriteString(letter)
+		}
+		return out.String(), true
+	case latexTextCommands[name]:
+		return p.argument()
+	}
+
+	symbol, ok := latexSymbols[name]
+	if !ok {
+		return "", false
+	}
+
+	// Keep function names separated from their arguments: \sin\theta -> sin θ
+	if len(symbol) > 1 && unicode.IsLetter([]rune(symbol)[0]) && p.pos < len(p.input) &&
+		(unicode.IsLetter(p.input[p.pos]) || p.input[p.pos] == '\\') {
+		return symbol + " ", true
+	}
+	return symbol, true
+}
+
+// sqrt converts \sqrt{x} and \sqrt[n]{x}
+func (p *latexParser) sqrt() (string, bool) {
+	root := "√"
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '[' {
+		end := p.pos + 1
+		for end < len(p.input) && p.input[end] != ']' {
+			end++
+		}
+		if end >= len(p.input) {
+			return "", false
+		}
+		switch strings.TrimSpace(string(p.input[p.pos+1 : end])) {
+		case "2":
+		case "3":
+			root = "∛"
+		case "4":
+			root = "∜"
+		default:
+			return "", false
+		}
+		p.pos = end + 1
+	}
+
+	arg, ok := p.argument()
+	if !ok {
+		return "", false
+	}
+	return root + wrapOperand(arg), true
+}
+
+// consume skips the expected rune and reports whether it was present
+func (p *latexParser) consume(r rune) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skipSpaces advances past whitespace
+func (p *latexParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// wrapOperand parenthesizes compound operands of fractions and roots
+func wrapOperand(s string) string {
+	s = strings.TrimSpace(s)
+	if len([]rune(s)) <= 1 || isAtom(s) {
+		return s
+	}
+	return "(" + s + ")"
+}
+
+// closingParen returns the index of the bracket closing the one s starts with, or -1
+func closingParen(s string) int {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isAtom reports whether s is a single number, word or already bracketed term
+func isAtom(s string) bool {
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		return closingParen(s) == len(s)-1
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && !isScriptRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isScriptRune reports whether r is a Unicode superscript or subscript character
+func isScriptRune(r rune) bool {
+	for _, v := range superscripts {
+		if v == r {
+			return true
+		}
+	}
+	for _, v := range subscripts {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}
+
+// mapRunes maps every non-space rune of s through table
+func mapRunes(s string, table map[rune]rune) (string, bool) {
+	var out strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		mapped, ok := table[r]
+		if !ok {
+			return "", false
+		}
+		out.WriteRune(mapped)
+	}
+	return out.String(), out.Len() > 0
+}
+
+// isSpaceByte reports whether b is an ASCII whitespace character
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestConvertLaTeXToUnicode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Superscript",
+			input:    "Area is $x^2$",
+			expected: "Area is x²",
+		},
+		{
+			name:     "Braced superscript",
+			input:    "$e^{-x} + 1$",
+			expected: "e⁻ˣ + 1",
+		},
+		{
+			name:     "Subscripts",
+			input:    "$a_1 + a_{n+1}$",
+			expected: "a₁ + aₙ₊₁",
+		},
+		{
+			name:     "Greek letters",
+			input:    "$\\alpha + \\beta = \\Gamma$",
+			expected: "α + β = Γ",
+		},
+		{
+			name:     "Fraction",
+			input:    "Half is $\\frac{1}{2}$",
+			expected: "Half is 1⁄2",
+		},
+		{
+			name:     "Compound fraction",
+			input:    "$\\frac{a+b}{c}$",
+			expected: "(a+b)⁄c",
+		},
+		{
+			name:     "Square root",
+			input:    "$\\sqrt{x+1}$ and $\\sqrt{2}$",
+			expected: "√(x+1) and √2",
+		},
+		{
+			name:     "Cube root",
+			input:    "$\\sqrt[3]{8} = 2$",
+			expected: "∛8 = 2",
+		},
+		{
+			name:     "Sum with limits",
+			input:    "$$\\sum_{i=1}^{n} i = \\frac{n(n+1)}{2}$$",
+			expected: "∑ᵢ₌₁ⁿ i = (n(n+1))⁄2",
+		},
+		{
+			name:     "Integral",
+			input:    "\\[\\int_0^1 x^2 \\, dx = \\frac{1}{3}\\]",
+			expected: "∫₀¹ x²   dx = 1⁄3",
+		},
+		{
+			name:     "Parenthesis delimiters",
+			input:    "where \\(x \\leq y\\)",
+			expected: "where x ≤ y",
+		},
+		{
+			name:     "Functions and operators",
+			input:    "$\\sin\\theta \\cdot \\cos\\theta$",
+			expected: "sin θ · cos θ",
+		},
+		{
+			name:     "Text and blackboard bold",
+			input:    "$x \\in \\mathbb{R}, \\text{ for all } x$",
+			expected: "x ∈ ℝ,  for all  x",
+		},
+		{
+			name:     "Left and right delimiters",
+			input:    "$\\left( a \\times b \\right)$",
+			expected: "( a × b )",
+		},
+		{
+			name:     "Degrees",
+			input:    "$90^\\circ$",
+			expected: "90°",
+		},
+		{
+			name:     "Unconvertible superscript falls back to code span",
+			input:    "$e^{i\\pi}$",
+			expected: "`e^{i\\pi}`",
+		},
+		{
+			name:     "Bracketed operands",
+			input:    "$\\frac{(a)+(b)}{(c+d)}$",
+			expected: "((a)+(b))⁄(c+d)",
+		},
+		{
+			name:     "Backticks in a code span",
+			input:    "$\\text{``quoted''} \\in \\mathcal{X}$",
+			expected: "`\\text{‘‘quoted''} \\in \\mathcal{X}`",
+		},
+		{
+			name:     "Unknown command falls back to code span",
+			input:    "$\\begin{matrix} a & b \\end{matrix}$",
+			expected: "`\\begin{matrix} a & b \\end{matrix}`",
+		},
+		{
+			name:     "Prices are not math",
+			input:    "It costs $5 and $10",
+			expected: "It costs $5 and $10",
+		},
+		{
+			name:     "Escaped math delimiter",
+			input:    "Type \\$x$ to get x",
+			expected: "Type $x$ to get x",
+		},
+		{
+			name:     "Escaped dollar in prose",
+			input:    "Price \\$5, grep 'end\\$' and sed 's/\\$HOME//'",
+			expected: "Price \\$5, grep 'end\\$' and sed 's/\\$HOME//'",
+		},
+		{
+			name:     "Inline code is untouched",
+			input:    "Use `$x^2$` literally",
+			expected: "Use `$x^2$` literally",
+		},
+		{
+			name:     "Code block is untouched",
+			input:    "```\necho $HOME$PATH\n```",
+			expected: "```\necho $HOME$PATH\n```",
+		},
+		{
+			name:     "Plain text",
+			input:    "No math here",
+			expected: "No math here",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ConvertLaTeXToUnicode(tt.input)
+			if result != tt.expected {
+				t.Errorf("ConvertLaTeXToUnicode() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
@@ -7,7 +7,10 @@ import (
 
 // ConvertMarkdownToTelegram converts Markdown formatting to Telegram's format
 func ConvertMarkdownToTelegram(text string) string {
-	// Convert headers first
+	// Convert LaTeX math to Unicode before Markdown markers are rewritten
+	text = ConvertLaTeXToUnicode(text)
+
+	// Convert headers
 	text = convertHeaders(text)
 
 	// Convert code blocks (before other formatting)